@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"redditwordcloud/internal/config"
 	"redditwordcloud/internal/health"
 	"redditwordcloud/internal/mongodb"
@@ -45,10 +46,12 @@ func main() {
 
 	redditRep := reddit.NewRepository(mdbc, nrc)
 	redditSvc := reddit.NewService(cfg.RedditConfig, redditRep)
+	// Watched threads are polled for as long as the server runs.
+	redditSvc.StartWatches(context.Background())
 	redditHandler := reddit.NewHandler(redditSvc)
 
 	healthHandler := health.NewHandler()
 
-	router.InitRouter(healthHandler, redditHandler, nrc)
+	router.InitRouter(healthHandler, redditHandler, nrc, cfg.APIKey)
 	router.Start("0.0.0.0:8080")
 }
@@ -24,6 +24,9 @@ type Config struct {
 	MongoDBConfig  mongodb.MongoDBConfig
 	RedditConfig   reddit.RedditConfig     `envPrefix:"REDDIT_"`
 	NewRelicConfig newrelic.NewRelicConfig `envPrefix:"NEW_RELIC_"`
+	// APIKey authenticates the requests to the reprocess and watch routes, which are closed
+	// when it is not set.
+	APIKey string `env:"API_KEY"`
 }
 
 const (
@@ -14,6 +14,16 @@ type MongoDBConfig struct {
 	ConnectionString string `env:"MONGODB_CONNECTION_STRING,required"`
 	CollectionName   string `env:"WORDS_COLLECTION_NAME,required"`
 	DatabaseName     string `env:"DATABASE_NAME,required"`
+	// CommentsCollectionName is the collection of the comments counted per thread.
+	CommentsCollectionName string `env:"COMMENTS_COLLECTION_NAME" envDefault:"comments"`
+	// BackgroundCollectionName is the collection of the document frequencies of words across threads.
+	BackgroundCollectionName string `env:"BACKGROUND_COLLECTION_NAME" envDefault:"background"`
+	// WatchesCollectionName is the collection of the threads polled by watch mode.
+	WatchesCollectionName string `env:"WATCHES_COLLECTION_NAME" envDefault:"watches"`
+	// CloudsCollectionName is the collection of the clouds of threads counted with query options.
+	CloudsCollectionName string `env:"CLOUDS_COLLECTION_NAME" envDefault:"clouds"`
+	// PhrasesCollectionName is the collection of the phrase models of threads.
+	PhrasesCollectionName string `env:"PHRASES_COLLECTION_NAME" envDefault:"phrases"`
 }
 
 type MongoDBClient struct {
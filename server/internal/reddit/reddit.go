@@ -2,16 +2,168 @@ package reddit
 
 import (
 	"context"
+	"errors"
+	"redditwordcloud/pkg/cooccurrence"
+	"redditwordcloud/pkg/topics"
+	"time"
 
 	"github.com/newrelic/go-agent/v3/newrelic"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// ErrThreadNotFound is returned when a thread has no documents in the repository.
+var ErrThreadNotFound = errors.New("thread not found")
+
+// ErrCommentNotFound is returned when a comment is not stored in its thread.
+var ErrCommentNotFound = errors.New("comment not found")
+
+// ErrWatchNotFound is returned when a thread is not watched.
+var ErrWatchNotFound = errors.New("watch not found")
+
+// ErrTooManyWatches is returned when watching a thread would exceed the most active watches.
+var ErrTooManyWatches = errors.New("too many watches")
+
+// ErrInvalidCursor is returned for a pagination cursor that was not issued by the service.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// WordDocument is the cloud of a set of comments. Threads are stored under
+// their scid, r/{subreddit}/comments/{id}, user histories under u/{username}.
 type WordDocument struct {
 	ID                    primitive.ObjectID `bson:"_id"`
 	SubredditAndCommentId string             `bson:"scid,omitempty"`
 	Words                 map[string]int     `bson:"words"`
 	LastUpdated           primitive.DateTime `bson:"last_updated"`
+	LastCrawled           primitive.DateTime `bson:"last_crawled,omitempty"`
+	ThreadCreated         primitive.DateTime `bson:"thread_created,omitempty"`
+	Post                  *PostDocument      `bson:"post,omitempty"`
+	// Excluded counts the comments left out of Words, by the reason they were flagged.
+	Excluded map[string]int `bson:"excluded,omitempty"`
+	// Languages counts the comments counted in Words, by the language they were detected in.
+	Languages map[string]int `bson:"languages,omitempty"`
+	// Facets count the emoji, mentions, subreddit references and link domains of the comments counted in Words.
+	Facets Facets `bson:"facets,omitempty"`
+	// InBackground is set once Words are counted in the background document frequencies.
+	InBackground bool `bson:"in_background,omitempty"`
+	// Batches are the crawl batches counted in Words whose comments may still be
+	// marked pending, see PendingCount.
+	Batches map[string]bool `bson:"batches,omitempty"`
+}
+
+// CloudDocument is the cloud of the comments of a thread selected and counted
+// by query options, such as a filter, stored under the thread's scid and the
+// fingerprint of the options. It is valid until the thread is crawled or
+// reprocessed again.
+type CloudDocument struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty"`
+	Scid        string             `bson:"scid"`
+	Fingerprint string             `bson:"fingerprint"`
+	Words       map[string]int     `bson:"words"`
+	Facets      Facets             `bson:"facets,omitempty"`
+	// LastCrawled and LastUpdated are those of the thread's WordDocument the cloud was counted at.
+	LastCrawled primitive.DateTime `bson:"last_crawled"`
+	LastUpdated primitive.DateTime `bson:"last_updated"`
+}
+
+// PhrasesDocument is the phrase model of the comments of a thread, trained
+// once per crawl and stored under the thread's scid. Its words are kept in
+// arrays, as they may contain dots.
+type PhrasesDocument struct {
+	ID   primitive.ObjectID `bson:"_id,omitempty"`
+	Scid string             `bson:"scid"`
+	// Casing is the most frequent capitalized form of the words of the comments.
+	Casing []PhraseForm `bson:"casing"`
+	// Proper are the words capitalized in most of their occurrences.
+	Proper []string `bson:"proper"`
+	// Names are the names of several words and their most frequent form.
+	Names []PhraseForm `bson:"names"`
+	// Collocations are the pairs of words kept together, joined by a space.
+	Collocations []string `bson:"collocations"`
+	MaxLength    int      `bson:"max_length"`
+	// Tokenizer is the tokenizerVersion the comments were split with.
+	Tokenizer int `bson:"tokenizer"`
+	// LastCrawled and LastUpdated are those of the thread's WordDocument the model was trained at.
+	LastCrawled primitive.DateTime `bson:"last_crawled"`
+	LastUpdated primitive.DateTime `bson:"last_updated"`
+}
+
+// PhraseForm is a lower-cased word or name and the form it is shown in.
+type PhraseForm struct {
+	Key  string `bson:"key"`
+	Form string `bson:"form"`
+}
+
+// PostDocument is the submission of a thread. Its words are kept apart from
+// the comments' and only counted in the cloud on request.
+type PostDocument struct {
+	Title    string         `bson:"title" json:"title"`
+	Selftext string         `bson:"selftext" json:"selftext"`
+	Words    map[string]int `bson:"words" json:"words"`
+}
+
+// CommentDocument records a comment counted in the WordDocument of its thread,
+// so later crawls only count new comments and reconcile edited ones, and the
+// word map can be rebuilt without fetching the thread again.
+type CommentDocument struct {
+	ID                    primitive.ObjectID `bson:"_id,omitempty"`
+	SubredditAndCommentId string             `bson:"scid"`
+	CommentId             string             `bson:"comment_id"`
+	ParentId              string             `bson:"parent_id"`
+	Author                string             `bson:"author"`
+	Score                 int                `bson:"score"`
+	Permalink             string             `bson:"permalink"`
+	Subreddit             string             `bson:"subreddit,omitempty"`
+	Depth                 int                `bson:"depth"`
+	Distinguished         string             `bson:"distinguished,omitempty"`
+	Stickied              bool               `bson:"stickied,omitempty"`
+	CreatedUTC            primitive.DateTime `bson:"created_utc"`
+	Body                  string             `bson:"body"`
+	BodyHash              string             `bson:"body_hash"`
+	Words                 map[string]int     `bson:"words"`
+	// Tokenizer is the tokenizerVersion that counted Words, 0 for comments
+	// counted before versions were recorded.
+	Tokenizer int `bson:"tokenizer,omitempty"`
+	// Flag is why the comment is excluded from the cloud, empty if it is counted.
+	Flag string `bson:"flag,omitempty"`
+	// Sentiment is the compound sentiment of the body, nil for comments stored before it was scored.
+	Sentiment *float64 `bson:"sentiment,omitempty"`
+	// Language is the language the body was detected in, empty for comments stored before it was detected.
+	Language string `bson:"language,omitempty"`
+	// Facets are the entities of the body left out of Words, nil for comments stored before they were extracted.
+	Facets Facets `bson:"facets,omitempty"`
+	// Pending is set while the change of the comment is not known to be counted
+	// in the WordDocument of its thread.
+	Pending *PendingCount `bson:"pending,omitempty"`
+}
+
+// PendingCount is what a comment counted for in the cloud of its thread
+// before the crawl batch that changed it. Comments are written before the
+// words of their batch; a comment whose batch is missing from the Batches of
+// the WordDocument counts for its PendingCount, and is counted again.
+type PendingCount struct {
+	Batch    string         `bson:"batch"`
+	Words    map[string]int `bson:"words,omitempty"`
+	Flag     string         `bson:"flag,omitempty"`
+	Language string         `bson:"language,omitempty"`
+	Facets   Facets         `bson:"facets,omitempty"`
+}
+
+// WatchDocument is a watched thread, crawled again and again until it expires.
+type WatchDocument struct {
+	ID                    primitive.ObjectID `bson:"_id,omitempty"`
+	SubredditAndCommentId string             `bson:"scid"`
+	Subreddit             string             `bson:"subreddit"`
+	CommentId             string             `bson:"comment_id"`
+	Created               primitive.DateTime `bson:"created"`
+	Expires               primitive.DateTime `bson:"expires"`
+	// Interval is the time between two polls, adapted to the activity of the thread.
+	Interval   time.Duration      `bson:"interval"`
+	NextPoll   primitive.DateTime `bson:"next_poll"`
+	LastPolled primitive.DateTime `bson:"last_polled,omitempty"`
+	Polls      int                `bson:"polls"`
+	// Comments is the number of comments of the thread stored at the last poll.
+	Comments int `bson:"comments"`
+	// Failures counts the polls in a row the thread could not be crawled.
+	Failures int `bson:"failures,omitempty"`
 }
 
 type GetRedditThreadWordsByThreadIDReq struct {
@@ -20,28 +172,522 @@ type GetRedditThreadWordsByThreadIDReq struct {
 
 type GetRedditThreadWordsByLinkReq struct {
 	Link string `json:"link" binding:"required,ValidateLink"`
+	// MaxAge is the oldest crawl, in seconds, the caller accepts. Older clouds are re-crawled.
+	MaxAge *int `json:"maxAge,omitempty" binding:"omitempty,min=0"`
+	// ForceRefresh re-crawls the thread regardless of the cached cloud's age.
+	ForceRefresh bool `json:"forceRefresh,omitempty"`
+	// IncludePost counts the post's title and selftext in the cloud, PostWeight times.
+	IncludePost bool `json:"includePost,omitempty"`
+	PostWeight  int  `json:"postWeight,omitempty" binding:"omitempty,min=1,max=100"`
+	// Filter selects the comments counted, e.g. "score >= 10 && depth == 0". See reddit_filter.go.
+	Filter string `json:"filter,omitempty" binding:"omitempty,max=1024,ValidateFilter"`
+	// IncludeFlagged counts the comments flagged as bots or spam, excluded by default.
+	IncludeFlagged bool `json:"includeFlagged,omitempty"`
+	// Language keeps the comments detected in a language, an ISO 639-1 code or "und".
+	Language string `json:"language,omitempty" binding:"omitempty,ValidateLanguage"`
+	// RemoveStopWords and Stem apply the stop words and the stemmer of the
+	// language of each comment to its words.
+	RemoveStopWords bool `json:"removeStopWords,omitempty"`
+	Stem            bool `json:"stem,omitempty"`
+	// Phrases counts names, in their usual case, and collocations as single
+	// terms, such as "Apple" apart from "apple" and "New York".
+	Phrases bool `json:"phrases,omitempty"`
+	// WordListQuery returns the words as a sorted, paginated List instead of the Words map.
+	WordListQuery
+	// Scoring ranks the words by count, or by how distinctive they are of the
+	// thread against the Background, with TF-IDF or log-likelihood.
+	Scoring    string `json:"scoring,omitempty" binding:"omitempty,oneof=count tfidf loglikelihood"`
+	Background string `json:"background,omitempty" binding:"omitempty,oneof=subreddit global"`
+	// IncludeSentiment adds the sentiment of the thread and of its words to the response.
+	IncludeSentiment bool `json:"includeSentiment,omitempty"`
+	// withStats computes the wordStats of the response, for exports.
+	withStats bool
 }
 
-type GetRedditThreadWordsRes struct {
-	Link    string `json:"link"`
-	Words   map[string]int
+type GetRedditSubredditWordsReq struct {
+	Subreddit string `json:"subreddit" binding:"required,ValidateSubreddit"`
+	Listing   string `json:"listing,omitempty" binding:"omitempty,oneof=hot top new"`
+	// Time is the window of the top listing.
+	Time string `json:"time,omitempty" binding:"omitempty,oneof=hour day week month year all"`
+	// Limit is the number of posts aggregated.
+	Limit        int  `json:"limit,omitempty" binding:"omitempty,min=1,max=100"`
+	MaxAge       *int `json:"maxAge,omitempty" binding:"omitempty,min=0"`
+	ForceRefresh bool `json:"forceRefresh,omitempty"`
+	// WordListQuery returns the words as a sorted, paginated List instead of the Words map.
+	WordListQuery
+	// withStats lists every word in the Words map, for exports.
+	withStats bool
+}
+
+type GetRedditUserWordsReq struct {
+	Username string `json:"username" binding:"required,ValidateUsername"`
+	// Subreddit limits the cloud to the comments made in a subreddit.
+	Subreddit string `json:"subreddit,omitempty" binding:"omitempty,ValidateSubreddit"`
+	// From and To limit the cloud to the comments made in a date range.
+	From         *time.Time `json:"from,omitempty"`
+	To           *time.Time `json:"to,omitempty"`
+	MaxAge       *int       `json:"maxAge,omitempty" binding:"omitempty,min=0"`
+	ForceRefresh bool       `json:"forceRefresh,omitempty"`
+	// WordListQuery returns the words as a sorted, paginated List instead of the Words map.
+	WordListQuery
+	// withStats computes the wordStats of the response, for exports.
+	withStats bool
+}
+
+type GetRedditUserWordsRes struct {
+	Username  string     `json:"username"`
+	Subreddit string     `json:"subreddit,omitempty"`
+	From      *time.Time `json:"from,omitempty"`
+	To        *time.Time `json:"to,omitempty"`
+	Words     map[string]int
+	// List replaces Words when the request has a WordListQuery.
+	List *WordList `json:"list,omitempty"`
+	// VocabularySize is the number of distinct words of the cloud, TotalTokens their occurrences.
+	VocabularySize int `json:"vocabularySize"`
+	TotalTokens    int `json:"totalTokens"`
+	Success        bool
+	Stale          bool       `json:"stale"`
+	Refreshing     bool       `json:"refreshing"`
+	LastCrawled    *time.Time `json:"lastCrawled,omitempty"`
+	// Facets rank the emoji, mentioned users, referenced subreddits and linked domains of the comments.
+	Facets map[string][]RankedWord `json:"facets,omitempty"`
+	wordStats
+}
+
+type GetRedditSearchWordsReq struct {
+	Query string `json:"query" binding:"required,max=512"`
+	// Subreddit limits the search to a subreddit.
+	Subreddit string `json:"subreddit,omitempty" binding:"omitempty,ValidateSubreddit"`
+	Sort      string `json:"sort,omitempty" binding:"omitempty,oneof=relevance top new"`
+	Time      string `json:"time,omitempty" binding:"omitempty,oneof=hour day week month year all"`
+	// Limit is the number of posts aggregated.
+	Limit int `json:"limit,omitempty" binding:"omitempty,min=1,max=100"`
+	// IncludeComments crawls the comments of the posts found, not only their titles and selftexts.
+	IncludeComments bool `json:"includeComments,omitempty"`
+	MaxAge          *int `json:"maxAge,omitempty" binding:"omitempty,min=0"`
+	ForceRefresh    bool `json:"forceRefresh,omitempty"`
+	// WordListQuery returns the words as a sorted, paginated List instead of the Words map.
+	WordListQuery
+	// withStats lists every word in the Words map, for exports.
+	withStats bool
+}
+
+type GetRedditSearchWordsRes struct {
+	Query     string `json:"query"`
+	Subreddit string `json:"subreddit,omitempty"`
+	Sort      string `json:"sort"`
+	Time      string `json:"time"`
+	Words     map[string]int
+	// List replaces Words when the request has a WordListQuery.
+	List *WordList `json:"list,omitempty"`
+	// VocabularySize is the number of distinct words of the cloud, TotalTokens their occurrences.
+	VocabularySize int         `json:"vocabularySize"`
+	TotalTokens    int         `json:"totalTokens"`
+	Posts          []PostWords `json:"posts"`
+	Success        bool
+	Refreshing     bool `json:"refreshing"`
+	// Facets rank the emoji, mentioned users, referenced subreddits and linked domains of the posts, and
+	// of their comments when they are included.
+	Facets map[string][]RankedWord `json:"facets,omitempty"`
+}
+
+// PostWords is the cloud of one post of an aggregate cloud.
+type PostWords struct {
+	Link      string `json:"link"`
+	Title     string `json:"title"`
+	Permalink string `json:"permalink"`
+	Score     int    `json:"score"`
+	// Words is the cloud of the post's comments.
+	Words map[string]int `json:"words,omitempty"`
+	// TextWords is the cloud of the post's title and selftext, when they are counted.
+	TextWords map[string]int `json:"textWords,omitempty"`
+	// Refreshing is set when the post is being crawled, its cloud may be incomplete or missing.
+	Refreshing bool `json:"refreshing"`
+}
+
+type GetRedditSubredditWordsRes struct {
+	Subreddit string `json:"subreddit"`
+	Listing   string `json:"listing"`
+	Time      string `json:"time"`
+	Words     map[string]int
+	// List replaces Words when the request has a WordListQuery.
+	List *WordList `json:"list,omitempty"`
+	// VocabularySize is the number of distinct words of the cloud, TotalTokens their occurrences.
+	VocabularySize int         `json:"vocabularySize"`
+	TotalTokens    int         `json:"totalTokens"`
+	Posts          []PostWords `json:"posts"`
+	Success        bool
+	Refreshing     bool `json:"refreshing"`
+	// Facets rank the emoji, mentioned users, referenced subreddits and linked domains of the comments of the posts.
+	Facets map[string][]RankedWord `json:"facets,omitempty"`
+}
+
+type ReprocessRedditThreadReq struct {
+	Scid string `uri:"scid" binding:"required"`
+}
+
+type GetRedditThreadWordContextsReq struct {
+	Scid string `uri:"scid" binding:"required"`
+	Word string `uri:"word" binding:"required"`
+	// Window is the number of words shown on each side of the match.
+	Window   int    `form:"window" binding:"omitempty,min=1,max=50"`
+	Sort     string `form:"sort" binding:"omitempty,oneof=score created"`
+	Order    string `form:"order" binding:"omitempty,oneof=asc desc"`
+	Page     int    `form:"page" binding:"omitempty,min=1"`
+	PageSize int    `form:"pageSize" binding:"omitempty,min=1,max=100"`
+	// Phrases matches Word as a term of the clouds counted with phrases, a
+	// name in its usual case or a collocation.
+	Phrases bool `form:"phrases"`
+	// IncludeFlagged matches the comments flagged as bots or spam, excluded by default like in clouds.
+	IncludeFlagged bool `form:"includeFlagged"`
+}
+
+// WordContext is one occurrence of a word in a comment, with the words around it.
+type WordContext struct {
+	CommentId string    `json:"commentId"`
+	Before    string    `json:"before"`
+	Match     string    `json:"match"`
+	After     string    `json:"after"`
+	Permalink string    `json:"permalink"`
+	Score     int       `json:"score"`
+	Author    string    `json:"author"`
+	Created   time.Time `json:"created"`
+}
+
+type GetRedditThreadWordContextsRes struct {
+	Link     string        `json:"link"`
+	Word     string        `json:"word"`
+	Contexts []WordContext `json:"contexts"`
+	Total    int           `json:"total"`
+	Page     int           `json:"page"`
+	PageSize int           `json:"pageSize"`
+	Success  bool
+}
+
+// ThreadCloudQuery selects the comments of a stored thread counted in a cloud.
+type ThreadCloudQuery struct {
+	Filter string `form:"filter" binding:"omitempty,max=1024,ValidateFilter"`
+	// IncludeFlagged counts the comments flagged as bots or spam, excluded by default.
+	IncludeFlagged bool `form:"includeFlagged"`
+	// Language keeps the comments detected in a language, an ISO 639-1 code or "und".
+	Language        string `form:"language" binding:"omitempty,ValidateLanguage"`
+	RemoveStopWords bool   `form:"removeStopWords"`
+	Stem            bool   `form:"stem"`
+	// Phrases counts names, in their usual case, and collocations as single terms.
+	Phrases bool `form:"phrases"`
+}
+
+type GetRedditThreadCloudReq struct {
+	Scid string `uri:"scid" binding:"required"`
+	ThreadCloudQuery
+	Width       int     `form:"width" binding:"omitempty,min=100,max=4000"`
+	Height      int     `form:"height" binding:"omitempty,min=100,max=4000"`
+	MinFontSize float64 `form:"minFontSize" binding:"omitempty,min=1,max=400"`
+	MaxFontSize float64 `form:"maxFontSize" binding:"omitempty,min=1,max=400"`
+	Scale       string  `form:"scale" binding:"omitempty,oneof=linear log sqrt"`
+	Rotation    string  `form:"rotation" binding:"omitempty,oneof=none orthogonal mixed"`
+	Palette     string  `form:"palette" binding:"omitempty,oneof=reddit category10 viridis pastel monochrome"`
+	// Seed makes the layout reproducible, the same seed renders the same cloud.
+	Seed     int64 `form:"seed"`
+	MaxWords int   `form:"maxWords" binding:"omitempty,min=1,max=1000"`
+	// Background is a hex color, URL-encoded as %23rrggbb, or "transparent".
+	Background string `form:"background" binding:"omitempty,hexcolor|eq=transparent"`
+	// Padding is the space kept around every word, in pixels.
+	Padding *int `form:"padding" binding:"omitempty,min=0,max=50"`
+	// Mask fills a shape with the words: a built-in shape or the icon of the thread's subreddit.
+	Mask string `form:"mask" binding:"omitempty,oneof=snoo circle subreddit"`
+	// ColorBy colors the words from the palette, or from negative to positive sentiment.
+	ColorBy string `form:"colorBy" binding:"omitempty,oneof=palette sentiment"`
+}
+
+// GraphQuery selects the words of a co-occurrence graph and its format.
+type GraphQuery struct {
+	// Level is the unit words co-occur in, a comment or a sentence.
+	Level string `form:"level" binding:"omitempty,oneof=comment sentence"`
+	// Top keeps the words appearing in the most units.
+	Top int `form:"top" binding:"omitempty,min=2,max=500"`
+	// MinCount is the fewest units two words must appear together in to be joined.
+	MinCount int    `form:"minCount" binding:"omitempty,min=1"`
+	MaxEdges int    `form:"maxEdges" binding:"omitempty,min=1,max=10000"`
+	Format   string `form:"format" binding:"omitempty,oneof=json graphml gexf"`
+}
+
+type GetRedditThreadGraphReq struct {
+	Scid string `uri:"scid" binding:"required"`
+	ThreadCloudQuery
+	GraphQuery
+}
+
+type GetRedditSubredditGraphReq struct {
+	Subreddit string `uri:"subreddit" binding:"required,ValidateSubreddit"`
+	Listing   string `form:"listing" binding:"omitempty,oneof=hot top new"`
+	Time      string `form:"time" binding:"omitempty,oneof=hour day week month year all"`
+	Limit     int    `form:"limit" binding:"omitempty,min=1,max=100"`
+	ThreadCloudQuery
+	GraphQuery
+}
+
+type GetRedditThreadTopicsReq struct {
+	Scid string `uri:"scid" binding:"required"`
+	ThreadCloudQuery
+	// Topics is the number of topics modeled.
+	Topics int `form:"k" binding:"omitempty,min=2,max=50"`
+	// Seed makes the model reproducible, the same seed finds the same topics.
+	Seed       int64 `form:"seed"`
+	Iterations int   `form:"iterations" binding:"omitempty,min=10,max=2000"`
+	TopWords   int   `form:"topWords" binding:"omitempty,min=1,max=100"`
+}
+
+// CommentTopic is the dominant topic of a comment.
+type CommentTopic struct {
+	CommentId string `json:"commentId"`
+	topics.Assignment
+}
+
+type GetRedditThreadTopicsRes struct {
+	Link     string         `json:"link"`
+	Topics   []topics.Topic `json:"topics"`
+	Comments []CommentTopic `json:"comments"`
+	// Vocabulary is the number of distinct words modeled.
+	Vocabulary int `json:"vocabulary"`
+	Success    bool
+}
+
+type GetRedditSubthreadWordsReq struct {
+	Scid      string `uri:"scid" binding:"required"`
+	CommentId string `uri:"commentId" binding:"required,alphanum"`
+	ThreadCloudQuery
+}
+
+type GetRedditThreadBranchesReq struct {
+	Scid string `uri:"scid" binding:"required"`
+	ThreadCloudQuery
+	// TopWords is the number of words listed for each branch.
+	TopWords int    `form:"topWords" binding:"omitempty,min=1,max=100"`
+	Sort     string `form:"sort" binding:"omitempty,oneof=size score created"`
+}
+
+// Subthread is a comment and all the replies below it.
+type Subthread struct {
+	CommentId string    `json:"commentId"`
+	Author    string    `json:"author"`
+	Score     int       `json:"score"`
+	Permalink string    `json:"permalink"`
+	Created   time.Time `json:"created"`
+	// Comments is the number of comments of the subthread, its root included,
+	// Counted the number counted in its cloud.
+	Comments int `json:"comments"`
+	Counted  int `json:"counted"`
+	// Depth is the number of levels of replies below the root.
+	Depth       int `json:"depth"`
+	TotalTokens int `json:"totalTokens"`
+}
+
+type GetRedditSubthreadWordsRes struct {
+	Link string `json:"link"`
+	Subthread
+	Words          map[string]int
+	VocabularySize int `json:"vocabularySize"`
+	// Facets rank the emoji, mentioned users, referenced subreddits and linked domains of the subthread.
+	Facets  map[string][]RankedWord `json:"facets,omitempty"`
+	Success bool
+}
+
+// Branch is the subthread of a top-level comment.
+type Branch struct {
+	Subthread
+	// Words are the most frequent words of the branch.
+	Words []RankedWord `json:"words"`
+}
+
+type GetRedditThreadBranchesRes struct {
+	Link     string   `json:"link"`
+	Branches []Branch `json:"branches"`
+	Success  bool
+}
+
+type GetRedditThreadTimeseriesReq struct {
+	Scid string `uri:"scid" binding:"required"`
+	ThreadCloudQuery
+	// Words are the comma-separated words a series is returned for, the most
+	// frequent words of the thread by default.
+	Words string `form:"words" binding:"omitempty,max=1024"`
+	// Interval is the width of a bucket, a duration such as 15m or 2h, chosen
+	// from the span of the thread by default.
+	Interval string `form:"interval" binding:"omitempty,ValidateInterval"`
+	// Baseline is the number of buckets before the latest one its words are
+	// compared against to rank the trending words.
+	Baseline int `form:"baseline" binding:"omitempty,min=1,max=100"`
+	// Top is the number of trending words.
+	Top int `form:"top" binding:"omitempty,min=1,max=100"`
+}
+
+// TimeBucket counts the comments created in an interval and their words.
+type TimeBucket struct {
+	Start    time.Time `json:"start"`
+	Comments int       `json:"comments"`
+	Tokens   int       `json:"tokens"`
+}
+
+// WordSeries is the count of a word in every bucket of a time series.
+type WordSeries struct {
+	Word   string `json:"word"`
+	Counts []int  `json:"counts"`
+	Total  int    `json:"total"`
+}
+
+// TrendingWord is a word of the latest bucket, ranked by how much more
+// frequent it is than in the baseline buckets before it. Count is its count
+// in the latest bucket.
+type TrendingWord struct {
+	RankedWord
+	Baseline int `json:"baseline"`
+}
+
+type GetRedditThreadTimeseriesRes struct {
+	Link string `json:"link"`
+	// Interval is the width of the buckets, wider than requested when the
+	// thread spans too many intervals.
+	Interval string         `json:"interval"`
+	Buckets  []TimeBucket   `json:"buckets"`
+	Series   []WordSeries   `json:"series"`
+	Trending []TrendingWord `json:"trending"`
+	Success  bool
+}
+
+type WatchRedditThreadReq struct {
+	Link string `json:"link" binding:"required,ValidateLink"`
+	// Duration is how long the thread is watched, in seconds, up to the
+	// longest watch configured. Watching a watched thread extends its watch.
+	Duration int `json:"duration,omitempty" binding:"omitempty,min=60"`
+}
+
+type UnwatchRedditThreadReq struct {
+	Scid string `uri:"scid" binding:"required"`
+}
+
+// Watch is a watched thread.
+type Watch struct {
+	Link    string    `json:"link"`
+	Created time.Time `json:"created"`
+	Expires time.Time `json:"expires"`
+	// Interval is the time between two polls, in seconds.
+	Interval   int        `json:"interval"`
+	NextPoll   time.Time  `json:"nextPoll"`
+	LastPolled *time.Time `json:"lastPolled,omitempty"`
+	Polls      int        `json:"polls"`
+	Comments   int        `json:"comments"`
+}
+
+type WatchRedditThreadRes struct {
+	Watch
+	Success bool
+}
+
+type GetRedditWatchesRes struct {
+	Watches []Watch `json:"watches"`
 	Success bool
 }
 
+// GetRedditGraphRes is the co-occurrence graph of a thread or a subreddit.
+type GetRedditGraphRes struct {
+	Link      string              `json:"link,omitempty"`
+	Subreddit string              `json:"subreddit,omitempty"`
+	Level     string              `json:"level"`
+	Graph     *cooccurrence.Graph `json:"graph"`
+	Success   bool
+	// Refreshing is set when posts of the subreddit are being crawled and left out of the graph.
+	Refreshing bool `json:"refreshing,omitempty"`
+}
+
+type GetRedditThreadWordsRes struct {
+	Link  string         `json:"link"`
+	Words map[string]int `json:"words,omitempty"`
+	// List replaces Words when the request has a WordListQuery.
+	List *WordList `json:"list,omitempty"`
+	// Scores are the scores of the words when they are scored other than by count.
+	Scores    map[string]float64 `json:"scores,omitempty"`
+	Sentiment *ThreadSentiment   `json:"sentiment,omitempty"`
+	// VocabularySize is the number of distinct words of the cloud, TotalTokens their occurrences.
+	VocabularySize int `json:"vocabularySize"`
+	TotalTokens    int `json:"totalTokens"`
+	Success        bool
+	// Stale is set when a cached cloud past its freshness is returned while it is refreshed.
+	Stale bool `json:"stale"`
+	// Refreshing is set when a crawl of the thread is underway.
+	Refreshing  bool       `json:"refreshing"`
+	LastCrawled *time.Time `json:"lastCrawled,omitempty"`
+	// Post is the thread's submission, once it has been crawled.
+	Post *PostDocument `json:"post,omitempty"`
+	// Filter is the canonical form of the filter the cloud was built with.
+	Filter string `json:"filter,omitempty"`
+	// Excluded counts the comments left out of the cloud, by reason.
+	Excluded map[string]int `json:"excluded,omitempty"`
+	// Moderation reports the deleted and removed comments of the thread.
+	Moderation *ModerationStats `json:"moderation,omitempty"`
+	// Languages counts the comments of the thread by the language they were detected in, the
+	// languages a cloud can be restricted to.
+	Languages map[string]int `json:"languages,omitempty"`
+	// Facets rank the emoji, mentioned users, referenced subreddits and linked
+	// domains of the comments of the cloud, kept apart from Words.
+	Facets map[string][]RankedWord `json:"facets,omitempty"`
+	wordStats
+}
+
 type Repository interface {
 	InsertWords(ctx context.Context, words map[string]int, scid string) (*WordDocument, error)
 	GetWordsFromLink(ctx context.Context, scid string) (*WordDocument, error)
-	Upsert(ctx context.Context, words map[string]int, link string) error
+	Upsert(ctx context.Context, words map[string]int, excluded map[string]int, languages map[string]int, facets Facets, link string, batch string) error
+	ClearPending(ctx context.Context, scid string, batch string) error
+	MarkCrawled(ctx context.Context, scid string, crawledAt time.Time, threadCreated time.Time) error
+	GetComments(ctx context.Context, scid string) ([]CommentDocument, error)
+	GetCommentsWithWord(ctx context.Context, scid string, word string) ([]CommentDocument, error)
+	CountComments(ctx context.Context, scid string) (int, error)
+	GetBackground(ctx context.Context, scope string, words []string) (*Background, error)
+	UpdateBackground(ctx context.Context, scid string, before map[string]int) error
+	UpsertComments(ctx context.Context, comments []CommentDocument) error
+	SetWords(ctx context.Context, words map[string]int, excluded map[string]int, languages map[string]int, facets Facets, scid string) error
+	SetPost(ctx context.Context, scid string, post PostDocument) error
+	GetCloud(ctx context.Context, scid string, fingerprint string) (*CloudDocument, error)
+	SaveCloud(ctx context.Context, cloud *CloudDocument) error
+	GetPhrases(ctx context.Context, scid string) (*PhrasesDocument, error)
+	SavePhrases(ctx context.Context, doc *PhrasesDocument) error
+	SaveWatch(ctx context.Context, watch *WatchDocument) error
+	UpdateWatch(ctx context.Context, watch *WatchDocument) error
+	GetWatches(ctx context.Context) ([]WatchDocument, error)
+	GetDueWatches(ctx context.Context, now time.Time) ([]WatchDocument, error)
+	DeleteWatch(ctx context.Context, scid string) (*WatchDocument, error)
 }
 
 type RedditConfig struct {
-	ID       string `env:"CLIENT_ID,required"`
-	Secret   string `env:"CLIENT_SECRET,required"`
-	Username string `env:"USERNAME,required"`
-	Password string `env:"PASSWORD,required"`
+	ID       string          `env:"CLIENT_ID,required"`
+	Secret   string          `env:"CLIENT_SECRET,required"`
+	Username string          `env:"USERNAME,required"`
+	Password string          `env:"PASSWORD,required"`
+	Cache    FreshnessConfig `envPrefix:"CACHE_"`
+	Bots     BotConfig       `envPrefix:"BOTS_"`
+	Watch    WatchConfig     `envPrefix:"WATCH_"`
 }
 
 type Service interface {
 	GetRedditThreadWordsByThreadID(c context.Context, req *GetRedditThreadWordsByThreadIDReq) (*GetRedditThreadWordsRes, error)
 	GetRedditThreadWordsByLink(c context.Context, req *GetRedditThreadWordsByLinkReq, txn *newrelic.Transaction) (*GetRedditThreadWordsRes, error)
+	GetRedditSubredditWords(c context.Context, req *GetRedditSubredditWordsReq) (*GetRedditSubredditWordsRes, error)
+	GetRedditUserWords(c context.Context, req *GetRedditUserWordsReq) (*GetRedditUserWordsRes, error)
+	GetRedditSearchWords(c context.Context, req *GetRedditSearchWordsReq) (*GetRedditSearchWordsRes, error)
+	ReprocessRedditThread(c context.Context, req *ReprocessRedditThreadReq) (*GetRedditThreadWordsRes, error)
+	GetRedditThreadWordContexts(c context.Context, req *GetRedditThreadWordContextsReq) (*GetRedditThreadWordContextsRes, error)
+	GetRedditThreadCloudSVG(c context.Context, req *GetRedditThreadCloudReq) ([]byte, error)
+	GetRedditThreadCloudPNG(c context.Context, req *GetRedditThreadCloudReq) ([]byte, error)
+	GetRedditThreadGraph(c context.Context, req *GetRedditThreadGraphReq) (*GetRedditGraphRes, error)
+	GetRedditSubredditGraph(c context.Context, req *GetRedditSubredditGraphReq) (*GetRedditGraphRes, error)
+	GetRedditThreadTopics(c context.Context, req *GetRedditThreadTopicsReq) (*GetRedditThreadTopicsRes, error)
+	GetRedditSubthreadWords(c context.Context, req *GetRedditSubthreadWordsReq) (*GetRedditSubthreadWordsRes, error)
+	GetRedditThreadBranches(c context.Context, req *GetRedditThreadBranchesReq) (*GetRedditThreadBranchesRes, error)
+	GetRedditThreadTimeseries(c context.Context, req *GetRedditThreadTimeseriesReq) (*GetRedditThreadTimeseriesRes, error)
+	WatchRedditThread(c context.Context, req *WatchRedditThreadReq) (*WatchRedditThreadRes, error)
+	UnwatchRedditThread(c context.Context, req *UnwatchRedditThreadReq) (*WatchRedditThreadRes, error)
+	GetRedditWatches(c context.Context) (*GetRedditWatchesRes, error)
+	// StartWatches polls the watched threads in the background until ctx is done.
+	StartWatches(ctx context.Context)
 }
@@ -0,0 +1,287 @@
+package reddit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"redditwordcloud/pkg/util"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+)
+
+/*
+The background is the document frequency of every word across the stored
+threads, a thread being a document, kept for each subreddit and for all of
+them. It is updated once with the change of the words of a thread at the end
+of every crawl and reprocess, and lets clouds rank words by how distinctive they are of a
+thread rather than by how frequent they are.
+*/
+
+const (
+	// globalScope is the scope of the background of all subreddits, the scope
+	// of the background of a subreddit is its lower-cased "r/name".
+	globalScope = "*"
+	// backgroundTotalsWord holds the totals of a scope: its number of documents
+	// and of tokens. Tokenization never produces an empty word.
+	backgroundTotalsWord = ""
+)
+
+// Scoring modes of the words of a cloud.
+const (
+	scoringCount         = "count"
+	scoringTFIDF         = "tfidf"
+	scoringLogLikelihood = "loglikelihood"
+)
+
+// Scopes of the background words are scored against.
+const (
+	backgroundSubreddit = "subreddit"
+	backgroundGlobal    = "global"
+)
+
+// BackgroundDocument counts the threads of a scope containing a word, and the
+// occurrences of the word across them.
+type BackgroundDocument struct {
+	Scope             string `bson:"scope"`
+	Word              string `bson:"word"`
+	DocumentFrequency int    `bson:"df"`
+	TermFrequency     int    `bson:"tf"`
+}
+
+// Background is the part of the background of a scope for a set of words.
+type Background struct {
+	Documents         int
+	Tokens            int
+	DocumentFrequency map[string]int
+	TermFrequency     map[string]int
+}
+
+// backgroundScopes returns the scopes a WordDocument counts in, none for
+// the documents that are not threads.
+func backgroundScopes(scid string) []string {
+	parts := strings.SplitN(scid, "/", 3)
+
+	if parts[0] != "r" || len(parts) < 3 || strings.Contains(scid, "#") {
+		return nil
+	}
+
+	return []string{globalScope, strings.ToLower(parts[0] + "/" + parts[1])}
+}
+
+// backgroundDelta is the change of the document and term frequencies of a word.
+type backgroundDelta struct{ df, tf int }
+
+// backgroundDeltas returns the changes to the background of a thread whose
+// words went from before to after, the totals under backgroundTotalsWord.
+func backgroundDeltas(before map[string]int, after map[string]int) map[string]backgroundDelta {
+	deltas := make(map[string]backgroundDelta)
+
+	for word, count := range after {
+		d := backgroundDelta{tf: count - before[word]}
+		if before[word] <= 0 {
+			d.df = 1
+		}
+		deltas[word] = d
+	}
+
+	for word, count := range before {
+		if after[word] <= 0 && count > 0 {
+			deltas[word] = backgroundDelta{df: -1, tf: -count}
+		}
+	}
+
+	totals := backgroundDelta{tf: countTokens(after) - countTokens(before)}
+	switch {
+	case len(before) == 0 && len(after) != 0:
+		totals.df = 1
+	case len(before) != 0 && len(after) == 0:
+		totals.df = -1
+	}
+	deltas[backgroundTotalsWord] = totals
+
+	for word, d := range deltas {
+		if d == (backgroundDelta{}) {
+			delete(deltas, word)
+		}
+	}
+
+	return deltas
+}
+
+// UpdateBackground applies the change of the words of scid from before to
+// the words stored now to the background. It is called once the words of a
+// crawl or reprocess are written, outside of the writes of their batches;
+// scid being crawled or reprocessed once at a time, the updates of a thread
+// do not interleave. Documents not yet in the background, such as those
+// stored before it existed, are added whole.
+func (r *repository) UpdateBackground(ctx context.Context, scid string, before map[string]int) error {
+	scopes := backgroundScopes(scid)
+
+	if len(scopes) == 0 {
+		return nil
+	}
+
+	defer r.nrc.Client.StartTransaction(fmt.Sprintf("%s: UpdateBackground", scid)).End()
+	wordDoc, err := r.GetWordsFromLink(ctx, scid)
+
+	if err != nil || wordDoc == nil {
+		return err
+	}
+
+	if !wordDoc.InBackground {
+		before = nil
+	}
+
+	deltas := backgroundDeltas(before, wordDoc.Words)
+
+	models := make([]mongo.WriteModel, 0, len(deltas)*len(scopes))
+	for _, scope := range scopes {
+		for word, d := range deltas {
+			models = append(models, mongo.NewUpdateOneModel().
+				SetFilter(bson.D{{Key: "scope", Value: scope}, {Key: "word", Value: word}}).
+				SetUpdate(bson.M{"$inc": bson.M{"df": d.df, "tf": d.tf}}).
+				SetUpsert(true))
+		}
+	}
+
+	if len(models) != 0 {
+		if _, err := r.backgroundCollection.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false)); err != nil {
+			zap.S().Errorf("Error updating background of %s in MongoDb: %w", scid, err)
+			return fmt.Errorf("could not update background: %w", err)
+		}
+	}
+
+	if !wordDoc.InBackground {
+		filter := bson.D{{Key: "scid", Value: scid}}
+		if _, err := r.wordsCollection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"in_background": true}}); err != nil {
+			zap.S().Errorf("Error marking WordDocument %s as in the background in MongoDb: %w", scid, err)
+			return fmt.Errorf("could not mark in background: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (r *repository) GetBackground(ctx context.Context, scope string, words []string) (*Background, error) {
+	defer r.nrc.Client.StartTransaction(fmt.Sprintf("%s: GetBackground", scope)).End()
+
+	bg := &Background{
+		DocumentFrequency: make(map[string]int, len(words)),
+		TermFrequency:     make(map[string]int, len(words)),
+	}
+
+	for _, chunk := range util.ChunkStringSlice(append(words, backgroundTotalsWord), 1000) {
+		filter := bson.D{{Key: "scope", Value: scope}, {Key: "word", Value: bson.M{"$in": chunk}}}
+		cursor, err := r.backgroundCollection.Find(ctx, filter)
+
+		if err != nil {
+			zap.S().Errorf("Error getting background of %s from MongoDb: %w", scope, err)
+			return nil, err
+		}
+
+		var docs []BackgroundDocument
+
+		if err := cursor.All(ctx, &docs); err != nil {
+			zap.S().Errorf("Error decoding background of %s from MongoDb: %w", scope, err)
+			return nil, err
+		}
+
+		for _, doc := range docs {
+			if doc.Word == backgroundTotalsWord {
+				bg.Documents, bg.Tokens = doc.DocumentFrequency, doc.TermFrequency
+				continue
+			}
+			bg.DocumentFrequency[doc.Word] = doc.DocumentFrequency
+			bg.TermFrequency[doc.Word] = doc.TermFrequency
+		}
+	}
+
+	return bg, nil
+}
+
+// scoreWords scores the words of the cloud of scid against the background
+// of scope, subreddit or global.
+func (svc *service) scoreWords(c context.Context, scid string, words map[string]int, scoring string, scope string) (map[string]float64, error) {
+	scopes := backgroundScopes(scid)
+
+	if len(scopes) == 0 {
+		return nil, fmt.Errorf("%s has no background", scid)
+	}
+
+	backgroundScope := scopes[1]
+	if scope == backgroundGlobal {
+		backgroundScope = globalScope
+	}
+
+	list := make([]string, 0, len(words))
+	for word := range words {
+		list = append(list, word)
+	}
+
+	bg, err := svc.Repository.GetBackground(c, backgroundScope, list)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not get background %s: %w", backgroundScope, err)
+	}
+
+	if scoring == scoringLogLikelihood {
+		return logLikelihoods(words, bg), nil
+	}
+	return tfidf(words, bg), nil
+}
+
+// tfidf scores words by their count times their smoothed inverse document
+// frequency in the background.
+func tfidf(words map[string]int, bg *Background) map[string]float64 {
+	scores := make(map[string]float64, len(words))
+
+	for word, count := range words {
+		idf := math.Log(float64(bg.Documents+1)/float64(bg.DocumentFrequency[word]+1)) + 1
+		scores[word] = float64(count) * idf
+	}
+
+	return scores
+}
+
+// logLikelihoods scores words by Dunning's log-likelihood of their counts in
+// the cloud against their counts in the rest of the background, negated for
+// the words less frequent in the cloud than in the rest of the background.
+func logLikelihoods(words map[string]int, bg *Background) map[string]float64 {
+	scores := make(map[string]float64, len(words))
+	tokens := float64(countTokens(words))
+	// The cloud is part of the background, the rest is compared against.
+	rest := math.Max(float64(bg.Tokens)-tokens, 0)
+
+	for word, count := range words {
+		a := float64(count)
+		b := math.Max(float64(bg.TermFrequency[word])-a, 0)
+
+		if rest == 0 {
+			scores[word] = 0
+			continue
+		}
+
+		e1 := tokens * (a + b) / (tokens + rest)
+		e2 := rest * (a + b) / (tokens + rest)
+
+		g2 := 0.0
+		if a > 0 {
+			g2 += a * math.Log(a/e1)
+		}
+		if b > 0 {
+			g2 += b * math.Log(b/e2)
+		}
+		g2 *= 2
+
+		if a/tokens < b/rest {
+			g2 = -g2
+		}
+		scores[word] = g2
+	}
+
+	return scores
+}
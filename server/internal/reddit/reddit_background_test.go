@@ -0,0 +1,126 @@
+package reddit
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestBackgroundDeltas(t *testing.T) {
+	tests := []struct {
+		name   string
+		before map[string]int
+		after  map[string]int
+		want   map[string]backgroundDelta
+	}{
+		{
+			name:  "new thread",
+			after: map[string]int{"go": 2, "rust": 1},
+			want: map[string]backgroundDelta{
+				"go":                 {df: 1, tf: 2},
+				"rust":               {df: 1, tf: 1},
+				backgroundTotalsWord: {df: 1, tf: 3},
+			},
+		},
+		{
+			name:   "unchanged thread",
+			before: map[string]int{"go": 2},
+			after:  map[string]int{"go": 2},
+			want:   map[string]backgroundDelta{},
+		},
+		{
+			name:   "words added and removed",
+			before: map[string]int{"go": 2, "rust": 1},
+			after:  map[string]int{"go": 3, "zig": 1},
+			want: map[string]backgroundDelta{
+				"go":                 {tf: 1},
+				"rust":               {df: -1, tf: -1},
+				"zig":                {df: 1, tf: 1},
+				backgroundTotalsWord: {tf: 1},
+			},
+		},
+		{
+			name:   "thread emptied",
+			before: map[string]int{"go": 2},
+			after:  map[string]int{},
+			want: map[string]backgroundDelta{
+				"go":                 {df: -1, tf: -2},
+				backgroundTotalsWord: {df: -1, tf: -2},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := backgroundDeltas(tt.before, tt.after); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("backgroundDeltas() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTFIDF(t *testing.T) {
+	bg := &Background{Documents: 9, DocumentFrequency: map[string]int{"common": 4}}
+
+	tests := []struct {
+		word string
+		want float64
+	}{
+		{word: "rare", want: 2 * (math.Log(10) + 1)},
+		{word: "common", want: math.Log(2) + 1},
+	}
+
+	scores := tfidf(map[string]int{"rare": 2, "common": 1}, bg)
+
+	for _, tt := range tests {
+		t.Run(tt.word, func(t *testing.T) {
+			if got := scores[tt.word]; math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("tfidf[%s] = %v, want %v", tt.word, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLogLikelihoods(t *testing.T) {
+	words := map[string]int{"distinct": 10, "even": 10}
+
+	tests := []struct {
+		name string
+		bg   *Background
+		word string
+		want float64
+	}{
+		{
+			name: "word only in the cloud",
+			bg:   &Background{Tokens: 120, TermFrequency: map[string]int{"distinct": 10, "even": 60}},
+			word: "distinct",
+			want: 20 * math.Log(6),
+		},
+		{
+			name: "word as frequent as in the rest",
+			bg:   &Background{Tokens: 120, TermFrequency: map[string]int{"distinct": 10, "even": 60}},
+			word: "even",
+			want: 0,
+		},
+		{
+			name: "word less frequent than in the rest",
+			bg:   &Background{Tokens: 120, TermFrequency: map[string]int{"distinct": 10, "even": 110}},
+			word: "even",
+			want: -5.2795593265196334,
+		},
+		{
+			name: "background of the cloud alone",
+			bg:   &Background{Tokens: 20, TermFrequency: map[string]int{"distinct": 10, "even": 10}},
+			word: "distinct",
+			want: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := logLikelihoods(words, tt.bg)[tt.word]; math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("logLikelihoods[%s] = %v, want %v", tt.word, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package reddit
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Reasons a comment is flagged and excluded from the cloud.
+const (
+	flagBotAuthor   = "bot_author"
+	flagBotTemplate = "bot_template"
+	flagDuplicate   = "duplicate"
+)
+
+// BotConfig configures the detection of bot and spam comments.
+type BotConfig struct {
+	// Authors are the accounts whose comments are always flagged, compared case-insensitively.
+	Authors []string `env:"AUTHORS" envSeparator:"," envDefault:"AutoModerator"`
+	// MinDuplicateLength is the shortest body flagged when repeated, so that
+	// short replies such as "this" or "thanks" are not.
+	MinDuplicateLength int `env:"MIN_DUPLICATE_LENGTH" envDefault:"30"`
+}
+
+// botTemplates match the boilerplate bots sign their comments with.
+var botTemplates = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)\bi(?:'|’)?m a bot\b`),
+	regexp.MustCompile(`(?i)\bi am a bot\b`),
+	regexp.MustCompile(`(?i)\bthis action was performed automatically\b`),
+	regexp.MustCompile(`(?i)\bbeep,? boop\b`),
+	regexp.MustCompile(`(?i)\bcontact the moderators of this subreddit\b`),
+}
+
+// botDetector flags the comments of known bots, comments matching a bot
+// template and copies of a comment body in the same thread. The original of
+// a body is its earliest comment, by creation and then by id, whatever order
+// the comments are crawled in.
+type botDetector struct {
+	authors            map[string]bool
+	minDuplicateLength int
+	mu                 sync.Mutex
+	// originals are the earliest comments seen with each body, by body hash.
+	originals map[string]CommentDocument
+	// displaced are the comments counted as originals until an earlier copy
+	// was seen, keyed by comment id.
+	displaced map[string]CommentDocument
+}
+
+// newBotDetector returns a detector for a thread whose comments counted by
+// earlier crawls are counted, so their bodies are the originals of later copies.
+func newBotDetector(cfg BotConfig, counted []CommentDocument) *botDetector {
+	d := &botDetector{
+		authors:            make(map[string]bool, len(cfg.Authors)),
+		minDuplicateLength: cfg.MinDuplicateLength,
+		originals:          make(map[string]CommentDocument),
+		displaced:          make(map[string]CommentDocument),
+	}
+
+	for _, author := range cfg.Authors {
+		d.authors[strings.ToLower(strings.TrimSpace(author))] = true
+	}
+
+	for _, comment := range counted {
+		if comment.Flag == "" && d.duplicable(comment.Body) {
+			if original, ok := d.originals[comment.BodyHash]; !ok || commentBefore(&comment, &original) {
+				d.originals[comment.BodyHash] = comment
+			}
+		}
+	}
+
+	return d
+}
+
+func (d *botDetector) duplicable(body string) bool {
+	return len(body) >= d.minDuplicateLength && !isDeletedBody(body)
+}
+
+// commentBefore reports whether a was created before b, the earliest id first
+// among comments created the same second.
+func commentBefore(a, b *CommentDocument) bool {
+	if a.CreatedUTC != b.CreatedUTC {
+		return a.CreatedUTC < b.CreatedUTC
+	}
+	return a.CommentId < b.CommentId
+}
+
+// flag returns why comment should be excluded from the cloud, or "" if it should not.
+func (d *botDetector) flag(comment *CommentDocument) string {
+	if d.authors[strings.ToLower(comment.Author)] {
+		return flagBotAuthor
+	}
+
+	for _, template := range botTemplates {
+		if template.MatchString(comment.Body) {
+			return flagBotTemplate
+		}
+	}
+
+	if !d.duplicable(comment.Body) {
+		return ""
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	original, ok := d.originals[comment.BodyHash]
+	if ok && original.CommentId != comment.CommentId && commentBefore(&original, comment) {
+		return flagDuplicate
+	}
+	if ok && original.CommentId != comment.CommentId {
+		d.displaced[original.CommentId] = original
+	}
+	delete(d.displaced, comment.CommentId)
+	d.originals[comment.BodyHash] = *comment
+
+	return ""
+}
+
+// record keeps the version of a comment recorded by the crawl if it is the
+// original of its body, so a displaced original is recorded as it was last seen.
+func (d *botDetector) record(comment CommentDocument) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if original, ok := d.originals[comment.BodyHash]; ok && original.CommentId == comment.CommentId {
+		d.originals[comment.BodyHash] = comment
+	}
+	if _, ok := d.displaced[comment.CommentId]; ok {
+		d.displaced[comment.CommentId] = comment
+	}
+}
+
+// forget drops a comment whose body changed from the originals of its old body.
+func (d *botDetector) forget(prev CommentDocument) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if original, ok := d.originals[prev.BodyHash]; ok && original.CommentId == prev.CommentId {
+		delete(d.originals, prev.BodyHash)
+	}
+	delete(d.displaced, prev.CommentId)
+}
+
+// takeDisplaced returns the displaced originals and forgets them.
+func (d *botDetector) takeDisplaced() map[string]CommentDocument {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	displaced := d.displaced
+	d.displaced = make(map[string]CommentDocument)
+	return displaced
+}
@@ -0,0 +1,114 @@
+package reddit
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const copiedBody = "This is a long enough comment to be flagged when copied."
+
+// botComment returns a comment created at the given second.
+func botComment(id string, author string, body string, second int) CommentDocument {
+	return CommentDocument{
+		CommentId:  id,
+		Author:     author,
+		Body:       body,
+		BodyHash:   bodyHash(body),
+		CreatedUTC: primitive.NewDateTimeFromTime(time.Unix(int64(1700000000+second), 0)),
+	}
+}
+
+func TestBotDetectorFlag(t *testing.T) {
+	tests := []struct {
+		name string
+		// comments are flagged in order.
+		comments      []CommentDocument
+		want          []string
+		wantDisplaced []string
+	}{
+		{
+			name:     "bot author",
+			comments: []CommentDocument{botComment("a", "automoderator", "hello", 0)},
+			want:     []string{flagBotAuthor},
+		},
+		{
+			name:     "bot template",
+			comments: []CommentDocument{botComment("a", "someone", "Beep boop, I'm a bot", 0)},
+			want:     []string{flagBotTemplate},
+		},
+		{
+			name:     "short copies",
+			comments: []CommentDocument{botComment("a", "x", "this", 0), botComment("b", "y", "this", 1)},
+			want:     []string{"", ""},
+		},
+		{
+			name:     "copy seen after its original",
+			comments: []CommentDocument{botComment("a", "x", copiedBody, 0), botComment("b", "y", copiedBody, 1)},
+			want:     []string{"", flagDuplicate},
+		},
+		{
+			name:          "original seen after its copy",
+			comments:      []CommentDocument{botComment("b", "y", copiedBody, 1), botComment("a", "x", copiedBody, 0)},
+			want:          []string{"", ""},
+			wantDisplaced: []string{"b"},
+		},
+		{
+			name:          "copies created the same second",
+			comments:      []CommentDocument{botComment("b", "y", copiedBody, 0), botComment("a", "x", copiedBody, 0), botComment("c", "z", copiedBody, 0)},
+			want:          []string{"", "", flagDuplicate},
+			wantDisplaced: []string{"b"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newBotDetector(BotConfig{Authors: []string{"AutoModerator"}, MinDuplicateLength: 30}, nil)
+			for i := range tt.comments {
+				if got := d.flag(&tt.comments[i]); got != tt.want[i] {
+					t.Errorf("flag(%s) = %q, want %q", tt.comments[i].CommentId, got, tt.want[i])
+				}
+			}
+
+			var displaced []string
+			for id := range d.takeDisplaced() {
+				displaced = append(displaced, id)
+			}
+			if !reflect.DeepEqual(displaced, tt.wantDisplaced) {
+				t.Errorf("displaced = %v, want %v", displaced, tt.wantDisplaced)
+			}
+		})
+	}
+}
+
+func TestCrawlDuplicates(t *testing.T) {
+	counted := storedComment("late", copiedBody)
+	counted.CreatedUTC = primitive.NewDateTimeFromTime(time.Unix(1700000100, 0))
+
+	cr := newCrawl("r/test/comments/t", nil, []CommentDocument{counted}, nil, BotConfig{MinDuplicateLength: 30})
+	b := newBatch()
+	cr.count(&RedditRepliesObject{Id: "late", Body: copiedBody, CreatedUTC: 1700000100}, b)
+	// An earlier copy of the body, such as one behind a "load more" link.
+	cr.count(&RedditRepliesObject{Id: "early", Body: copiedBody, CreatedUTC: 1700000000}, b)
+
+	if early, _ := b.comments.Get("early"); early.Flag != "" {
+		t.Errorf("earliest copy flagged %q", early.Flag)
+	}
+
+	d := cr.duplicates()
+
+	late, ok := d.comments.Get("late")
+	if !ok || late.Flag != flagDuplicate || late.Pending == nil {
+		t.Fatalf("displaced original = %+v, want a pending duplicate", late)
+	}
+	if want := map[string]int{flagDuplicate: 1}; !reflect.DeepEqual(nonZero(d.excluded.Items()), want) {
+		t.Errorf("excluded = %v, want %v", d.excluded.Items(), want)
+	}
+	for word, count := range counted.Words {
+		if got, _ := d.words.Get(word); got != -count {
+			t.Errorf("delta of %q = %d, want %d", word, got, -count)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package reddit
+
+import (
+	"container/list"
+	"sync"
+)
+
+// lruCache is a bounded in-memory cache of what is computed from stored
+// documents, such as rendered clouds. Keys hold everything a value is
+// computed from, so values are never stale, only evicted: the least
+// recently used first once the cost of the values passes maxCost.
+type lruCache[V any] struct {
+	mu      sync.Mutex
+	maxCost int
+	cost    func(V) int
+	total   int
+	// order holds the entries, the most recently used first.
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type lruEntry[V any] struct {
+	key   string
+	value V
+	cost  int
+}
+
+// newLRUCache returns a cache of values costing cost each, at most maxCost in total.
+func newLRUCache[V any](maxCost int, cost func(V) int) *lruCache[V] {
+	return &lruCache[V]{
+		maxCost: maxCost,
+		cost:    cost,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+func (c *lruCache[V]) Get(key string) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		var zero V
+		return zero, false
+	}
+
+	c.order.MoveToFront(e)
+	return e.Value.(*lruEntry[V]).value, true
+}
+
+// Set caches value under key. Values costing more than the whole cache are not cached.
+func (c *lruCache[V]) Set(key string, value V) {
+	cost := c.cost(value)
+	if cost > c.maxCost {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.entries[key]; ok {
+		c.remove(e)
+	}
+
+	c.entries[key] = c.order.PushFront(&lruEntry[V]{key: key, value: value, cost: cost})
+	c.total += cost
+
+	for c.total > c.maxCost {
+		c.remove(c.order.Back())
+	}
+}
+
+func (c *lruCache[V]) remove(e *list.Element) {
+	entry := c.order.Remove(e).(*lruEntry[V])
+	delete(c.entries, entry.key)
+	c.total -= entry.cost
+}
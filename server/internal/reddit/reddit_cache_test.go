@@ -0,0 +1,41 @@
+package reddit
+
+import "testing"
+
+func TestLRUCache(t *testing.T) {
+	cache := newLRUCache(10, func(v string) int { return len(v) })
+
+	cache.Set("a", "aaaa")
+	cache.Set("b", "bbbb")
+	// a is used more recently than b, b is evicted first.
+	cache.Get("a")
+	cache.Set("c", "cccc")
+	// Larger than the whole cache, not cached.
+	cache.Set("d", "ddddddddddd")
+	// Replacing a value replaces its cost.
+	cache.Set("c", "cc")
+
+	tests := []struct {
+		key  string
+		want string
+		ok   bool
+	}{
+		{"a", "aaaa", true},
+		{"b", "", false},
+		{"c", "cc", true},
+		{"d", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			got, ok := cache.Get(tt.key)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("Get(%q) = %q, %v, want %q, %v", tt.key, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+
+	if cache.total != 6 {
+		t.Errorf("total = %d, want 6", cache.total)
+	}
+}
@@ -0,0 +1,171 @@
+package reddit
+
+import (
+	"context"
+	"fmt"
+	"redditwordcloud/pkg/phrases"
+	"sort"
+	"strings"
+)
+
+const (
+	defaultContextWindow   = 10
+	defaultContextPageSize = 20
+	redditBaseURL          = "https://www.reddit.com"
+)
+
+// GetRedditThreadWordContexts returns the occurrences of a word in the stored
+// comments of a thread, each with the words surrounding it.
+func (svc *service) GetRedditThreadWordContexts(c context.Context, req *GetRedditThreadWordContextsReq) (*GetRedditThreadWordContextsRes, error) {
+	// Names keep their case when phrases are matched, other words never do.
+	word := strings.ToLower(req.Word)
+	if req.Phrases {
+		word = strings.Join(strings.Fields(req.Word), " ")
+	}
+
+	window := req.Window
+	if window == 0 {
+		window = defaultContextWindow
+	}
+
+	page := req.Page
+	if page == 0 {
+		page = 1
+	}
+
+	pageSize := req.PageSize
+	if pageSize == 0 {
+		pageSize = defaultContextPageSize
+	}
+
+	wordDoc, err := svc.Repository.GetWordsFromLink(c, req.Scid)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not get words of %s: %w", req.Scid, err)
+	}
+
+	if wordDoc == nil {
+		return nil, fmt.Errorf("could not get contexts of %s: %w", req.Scid, ErrThreadNotFound)
+	}
+
+	// A term is looked up by its first word, the comments of a name or a
+	// collocation contain all of its words.
+	first, _, _ := strings.Cut(strings.ToLower(word), " ")
+
+	var comments []CommentDocument
+	var model *phrases.Model
+	if req.Phrases {
+		comments, err = svc.Repository.GetComments(c, req.Scid)
+	} else {
+		comments, err = svc.Repository.GetCommentsWithWord(c, req.Scid, first)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("could not get comments of %s: %w", req.Scid, err)
+	}
+
+	if req.Phrases {
+		if model, err = svc.phraseModel(c, wordDoc, comments); err != nil {
+			return nil, err
+		}
+	}
+
+	var matching []CommentDocument
+	for _, comment := range comments {
+		if comment.Flag != "" && !req.IncludeFlagged {
+			continue
+		}
+		if comment.Words[first] > 0 {
+			matching = append(matching, comment)
+		}
+	}
+
+	sortComments(matching, req.Sort, req.Order)
+
+	contexts := []WordContext{}
+	for _, comment := range matching {
+		contexts = append(contexts, wordContexts(comment, word, window, model)...)
+	}
+
+	total := len(contexts)
+	start := min((page-1)*pageSize, total)
+	end := min(start+pageSize, total)
+
+	return &GetRedditThreadWordContextsRes{
+		Link:     req.Scid,
+		Word:     word,
+		Contexts: contexts[start:end],
+		Total:    total,
+		Page:     page,
+		PageSize: pageSize,
+		Success:  true,
+	}, nil
+}
+
+// sortComments orders comments by score (the default) or creation time, in
+// descending order unless order is "asc".
+func sortComments(comments []CommentDocument, by, order string) {
+	less := func(i, j int) bool {
+		if by == "created" {
+			return comments[i].CreatedUTC < comments[j].CreatedUTC
+		}
+		return comments[i].Score < comments[j].Score
+	}
+
+	if order == "asc" {
+		sort.SliceStable(comments, less)
+		return
+	}
+
+	sort.SliceStable(comments, func(i, j int) bool { return less(j, i) })
+}
+
+// wordContexts finds the occurrences of word in the body of comment. The
+// body is tokenized once as it is when counted, a match is a token in its
+// original case and its context the tokens around it. With a phrase model, a
+// match is a term of the model, the name or collocation it spans.
+func wordContexts(comment CommentDocument, word string, window int, model *phrases.Model) []WordContext {
+	var tokens []string
+	var spans []phrases.Span
+	if model == nil {
+		tokens = tokenizeCased(comment.Body)
+		for i, token := range tokens {
+			if strings.ToLower(token) == word {
+				spans = append(spans, phrases.Span{Term: word, Start: i, End: i + 1})
+			}
+		}
+	} else {
+		for _, sentence := range commentSentences(comment.Body) {
+			for _, span := range model.Spans(sentence) {
+				if span.Term == word {
+					spans = append(spans, phrases.Span{Term: word, Start: len(tokens) + span.Start, End: len(tokens) + span.End})
+				}
+			}
+			tokens = append(tokens, sentence...)
+		}
+	}
+
+	var contexts []WordContext
+	for _, span := range spans {
+		contexts = append(contexts, WordContext{
+			CommentId: comment.CommentId,
+			Before:    strings.Join(tokens[max(span.Start-window, 0):span.Start], " "),
+			Match:     strings.Join(tokens[span.Start:span.End], " "),
+			After:     strings.Join(tokens[span.End:min(span.End+window, len(tokens))], " "),
+			Permalink: commentPermalink(comment),
+			Score:     comment.Score,
+			Author:    comment.Author,
+			Created:   comment.CreatedUTC.Time(),
+		})
+	}
+
+	return contexts
+}
+
+func commentPermalink(comment CommentDocument) string {
+	if comment.Permalink != "" {
+		return redditBaseURL + comment.Permalink
+	}
+	// Comments recorded before permalinks were stored, reddit accepts any slug.
+	return fmt.Sprintf("%s/%s/_/%s/", redditBaseURL, comment.SubredditAndCommentId, comment.CommentId)
+}
@@ -0,0 +1,151 @@
+package reddit
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestWordContexts(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		word string
+		want []WordContext
+	}{
+		{
+			name: "every case of the word",
+			body: "Go is great, I love go and GO.",
+			word: "go",
+			want: []WordContext{
+				{Before: "", Match: "Go", After: "is great"},
+				{Before: "I love", Match: "go", After: "and GO"},
+				{Before: "go and", Match: "GO", After: ""},
+			},
+		},
+		{
+			name: "html entities are unescaped",
+			body: "salt &amp; pepper",
+			word: "pepper",
+			want: []WordContext{{Before: "salt", Match: "pepper"}},
+		},
+		{
+			name: "no occurrence",
+			body: "nothing to see",
+			word: "go",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := wordContexts(CommentDocument{CommentId: "c", Body: tt.body}, tt.word, 2, nil)
+			if len(got) != len(tt.want) {
+				t.Fatalf("wordContexts() = %+v, want %d contexts", got, len(tt.want))
+			}
+			for i, want := range tt.want {
+				if got[i].Before != want.Before || got[i].Match != want.Match || got[i].After != want.After {
+					t.Errorf("context %d = %q %q %q, want %q %q %q", i, got[i].Before, got[i].Match, got[i].After, want.Before, want.Match, want.After)
+				}
+			}
+		})
+	}
+}
+
+func TestWordContextsPhrases(t *testing.T) {
+	model := trainPhrases(&WordDocument{Languages: map[string]int{"en": 3}}, phraseComments)
+
+	tests := []struct {
+		name string
+		body string
+		word string
+		want []WordContext
+	}{
+		{
+			name: "name",
+			body: "I moved to New York. New York is big",
+			word: "New York",
+			want: []WordContext{
+				{Before: "moved to", Match: "New York", After: "New York"},
+				{Before: "New York", Match: "New York", After: "is big"},
+			},
+		},
+		{
+			name: "collocation starting a sentence",
+			body: "Machine learning is fun",
+			word: "machine learning",
+			want: []WordContext{{Before: "", Match: "Machine learning", After: "is fun"}},
+		},
+		{
+			name: "word of a name",
+			body: "I moved to New York",
+			word: "new",
+		},
+		{
+			name: "word of a collocation",
+			body: "I study machine learning",
+			word: "learning",
+		},
+		{
+			name: "word outside of a name",
+			body: "my new bike",
+			word: "new",
+			want: []WordContext{{Before: "my", Match: "new", After: "bike"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := wordContexts(CommentDocument{CommentId: "c", Body: tt.body}, tt.word, 2, model)
+			if len(got) != len(tt.want) {
+				t.Fatalf("wordContexts() = %+v, want %d contexts", got, len(tt.want))
+			}
+			for i, want := range tt.want {
+				if got[i].Before != want.Before || got[i].Match != want.Match || got[i].After != want.After {
+					t.Errorf("context %d = %q %q %q, want %q %q %q", i, got[i].Before, got[i].Match, got[i].After, want.Before, want.Match, want.After)
+				}
+			}
+		})
+	}
+}
+
+func TestGetRedditThreadWordContextsFlagged(t *testing.T) {
+	scid := "r/test/comments/t"
+	repo := newFakeRepository()
+	repo.addWords(WordDocument{SubredditAndCommentId: scid, Words: map[string]int{"rules": 1}})
+	repo.addComments(
+		CommentDocument{SubredditAndCommentId: scid, CommentId: "a", Body: "read the rules first", Words: map[string]int{"rules": 1}},
+		CommentDocument{SubredditAndCommentId: scid, CommentId: "b", Body: "please follow the rules", Words: map[string]int{"rules": 1}, Flag: flagBotAuthor},
+		CommentDocument{SubredditAndCommentId: scid, CommentId: "c", Body: "read the rules first", Words: map[string]int{"rules": 1}, Flag: flagDuplicate},
+	)
+	svc := newTestService(repo, nil)
+
+	tests := []struct {
+		name           string
+		includeFlagged bool
+		want           []string
+	}{
+		{name: "flagged comments excluded", want: []string{"a"}},
+		{name: "flagged comments included", includeFlagged: true, want: []string{"a", "b", "c"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &GetRedditThreadWordContextsReq{Scid: scid, Word: "rules", Sort: "created", Order: "asc", IncludeFlagged: tt.includeFlagged}
+			res, err := svc.GetRedditThreadWordContexts(context.Background(), req)
+			if err != nil {
+				t.Fatalf("GetRedditThreadWordContexts() error = %v", err)
+			}
+
+			var got []string
+			for _, wc := range res.Contexts {
+				got = append(got, wc.CommentId)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("contexts of comments %v, want %v", got, tt.want)
+			}
+			if res.Total != len(tt.want) {
+				t.Errorf("total = %d, want %d", res.Total, len(tt.want))
+			}
+		})
+	}
+}
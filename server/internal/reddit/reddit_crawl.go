@@ -0,0 +1,336 @@
+package reddit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"html"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	cmap "github.com/orcaman/concurrent-map/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	deletedBody = "[deleted]"
+	removedBody = "[removed]"
+)
+
+// crawl is the state of one crawl of a thread, or of another set of comments
+// such as a user's history. Comments that were already counted in the
+// WordDocument by an earlier crawl are only counted again when their body
+// changed, in which case their old words are subtracted.
+type crawl struct {
+	// link is the thread crawled, nil for comments that do not form a thread.
+	link *Link
+	// scid is the key of the crawl's documents in the repository.
+	scid string
+	// counted holds the comments counted by earlier crawls, keyed by comment id.
+	// It is only read once the crawl has started.
+	counted map[string]CommentDocument
+	// seen holds the ids of the comments visited by this crawl, so a comment
+	// returned by several requests is counted once.
+	seen cmap.ConcurrentMap[string, bool]
+	// failed is set when part of the thread could not be fetched.
+	failed atomic.Bool
+	bots   *botDetector
+	// cloud is counted along the crawl for the options of the request that started it, if any.
+	cloud *crawlCloud
+	// words are the words of scid when the crawl started, the background is
+	// updated with their change once the crawl ends.
+	words map[string]int
+}
+
+// newCrawl starts a crawl of the comments counted under scid. applied are
+// the Batches of their WordDocument.
+func newCrawl(scid string, link *Link, counted []CommentDocument, applied map[string]bool, bots BotConfig) *crawl {
+	cr := &crawl{
+		link:    link,
+		scid:    scid,
+		counted: make(map[string]CommentDocument, len(counted)),
+		seen:    cmap.New[bool](),
+		bots:    newBotDetector(bots, counted),
+	}
+
+	for _, comment := range counted {
+		if p := comment.Pending; p != nil && !applied[p.Batch] {
+			// The words of the batch that changed the comment were never written, it
+			// still counts for what it did before and is counted again.
+			comment.Words = p.Words
+			comment.Flag = p.Flag
+			comment.Language = p.Language
+			comment.Facets = p.Facets
+			comment.BodyHash = ""
+		}
+		cr.counted[comment.CommentId] = comment
+	}
+
+	return cr
+}
+
+// appliedBatches returns the batches of the comments counted under scid that
+// are marked pending although their words were written.
+func (cr *crawl) appliedBatches(applied map[string]bool) []string {
+	var batches []string
+	seen := make(map[string]bool)
+	for _, comment := range cr.counted {
+		if p := comment.Pending; p != nil && applied[p.Batch] && !seen[p.Batch] {
+			seen[p.Batch] = true
+			batches = append(batches, p.Batch)
+		}
+	}
+	return batches
+}
+
+// batch accumulates the word, exclusion, language and facet deltas and the
+// comment records of one listing, which are written to the repository together.
+type batch struct {
+	// id marks the comments changed by the batch as pending until its words are written.
+	id        string
+	words     cmap.ConcurrentMap[string, int]
+	excluded  cmap.ConcurrentMap[string, int]
+	languages cmap.ConcurrentMap[string, int]
+	// facets are keyed by facetDelta.
+	facets   cmap.ConcurrentMap[string, int]
+	comments cmap.ConcurrentMap[string, CommentDocument]
+}
+
+func newBatch() *batch {
+	return &batch{
+		id:        primitive.NewObjectID().Hex(),
+		words:     cmap.New[int](),
+		excluded:  cmap.New[int](),
+		languages: cmap.New[int](),
+		facets:    cmap.New[int](),
+		comments:  cmap.New[CommentDocument](),
+	}
+}
+
+func sumInt(exist bool, valueInMap int, newValue int) int {
+	return valueInMap + newValue
+}
+
+func (b *batch) addWords(words map[string]int, sign int) {
+	for word, count := range words {
+		b.words.Upsert(word, sign*count, sumInt)
+	}
+}
+
+// add adds (sign 1) or removes (sign -1) the contribution of comment to the
+// cloud: its words, language and facets, or its exclusion if it is flagged.
+func (b *batch) add(comment CommentDocument, sign int) {
+	if comment.Flag != "" {
+		b.excluded.Upsert(comment.Flag, sign, sumInt)
+		return
+	}
+	b.addWords(comment.Words, sign)
+	for kind, entities := range comment.Facets {
+		for entity, count := range entities {
+			b.facets.Upsert(facetDelta(kind, entity), sign*count, sumInt)
+		}
+	}
+	if comment.Language != "" {
+		b.languages.Upsert(comment.Language, sign, sumInt)
+	}
+}
+
+// pending returns the PendingCount of a comment changed by b that counted
+// for prev, empty if it was not counted before.
+func (b *batch) pending(prev CommentDocument) *PendingCount {
+	return &PendingCount{
+		Batch:    b.id,
+		Words:    prev.Words,
+		Flag:     prev.Flag,
+		Language: prev.Language,
+		Facets:   prev.Facets,
+	}
+}
+
+// count adds the words of comment to b, reconciling it with the version of the
+// comment counted by an earlier crawl.
+func (cr *crawl) count(comment *RedditRepliesObject, b *batch) {
+	if !cr.seen.SetIfAbsent(comment.Id, true) {
+		return
+	}
+
+	doc := newCommentDocument(cr.scid, comment, nil)
+	prev, known := cr.counted[comment.Id]
+
+	// The comment is recorded again even if unchanged, to keep its score
+	// current. Comments counted by an earlier tokenizer are counted again, with
+	// the language and facets of the comments counted before they were detected.
+	if known && prev.BodyHash == doc.BodyHash && prev.Tokenizer == tokenizerVersion {
+		doc.Words = prev.Words
+		doc.Flag = prev.Flag
+		doc.Sentiment = prev.Sentiment
+		doc.Language = prev.Language
+		doc.Facets = prev.Facets
+		b.comments.Set(comment.Id, doc)
+		cr.bots.record(doc)
+		cr.cloud.add(&doc, 1)
+		return
+	}
+
+	// Deleted and removed comments keep no words, whether or not they were counted before.
+	if known {
+		cr.bots.forget(prev)
+	}
+
+	if doc.Flag = deletionFlag(comment.Body); doc.Flag == "" {
+		doc.Words = countWords(tokenize(comment.Body))
+		doc.Flag = cr.bots.flag(&doc)
+		doc.Sentiment = scoreSentiment(comment.Body)
+		doc.Language = detectLanguage(comment.Body)
+		doc.Facets = extractFacets(comment.Body)
+	}
+
+	doc.Pending = b.pending(prev)
+	b.add(doc, 1)
+	b.add(prev, -1)
+	b.comments.Set(comment.Id, doc)
+	cr.bots.record(doc)
+	cr.cloud.add(&doc, 1)
+}
+
+func newCommentDocument(scid string, comment *RedditRepliesObject, words map[string]int) CommentDocument {
+	return CommentDocument{
+		SubredditAndCommentId: scid,
+		CommentId:             comment.Id,
+		ParentId:              comment.ParentId,
+		Author:                comment.Author,
+		Score:                 comment.Score,
+		Permalink:             comment.Permalink,
+		Subreddit:             comment.Subreddit,
+		Depth:                 comment.Depth,
+		Distinguished:         comment.Distinguished,
+		Stickied:              comment.Stickied,
+		CreatedUTC:            primitive.NewDateTimeFromTime(time.Unix(int64(comment.CreatedUTC), 0)),
+		Body:                  comment.Body,
+		BodyHash:              bodyHash(comment.Body),
+		Words:                 words,
+		Tokenizer:             tokenizerVersion,
+	}
+}
+
+// vanished returns the comments counted by earlier crawls that this crawl
+// did not see, as removals to apply to the thread's WordDocument. It must
+// only be called once the crawl has completed without failures.
+func (cr *crawl) vanished() *batch {
+	b := newBatch()
+
+	for id, prev := range cr.counted {
+		if cr.seen.Has(id) || prev.Body == deletedBody {
+			continue
+		}
+
+		b.add(prev, -1)
+
+		prev.Pending = b.pending(prev)
+		prev.Body = deletedBody
+		prev.BodyHash = bodyHash(deletedBody)
+		prev.Words = nil
+		prev.Flag = flagDeleted
+		prev.Language = ""
+		prev.Facets = nil
+
+		b.add(prev, 1)
+		b.comments.Set(id, prev)
+	}
+
+	return b
+}
+
+// duplicates returns the comments counted as the original of their body
+// until the crawl saw an earlier copy, flagged as duplicates. Like vanished,
+// it must only be called once the crawl has completed without failures.
+func (cr *crawl) duplicates() *batch {
+	b := newBatch()
+
+	for id, prev := range cr.bots.takeDisplaced() {
+		// Originals the crawl did not see are subtracted by vanished.
+		if !cr.seen.Has(id) {
+			continue
+		}
+
+		doc := prev
+		doc.Pending = b.pending(prev)
+		doc.Flag = flagDuplicate
+
+		b.add(prev, -1)
+		b.add(doc, 1)
+		b.comments.Set(id, doc)
+		cr.cloud.add(&prev, -1)
+		cr.cloud.add(&doc, 1)
+	}
+
+	return b
+}
+
+// tokenizerVersion is the version of tokenize and of the language and facet
+// detection of comments. Changing how a body is counted requires bumping it:
+// crawls count the stored comments of an earlier version again, and threads
+// that are not crawled again must be reprocessed.
+const tokenizerVersion = 1
+
+// tokenize splits a comment body into the lower-cased words counted in a cloud.
+func tokenize(body string) []string {
+	tokens := tokenizeCased(body)
+	for i, token := range tokens {
+		tokens[i] = strings.ToLower(token)
+	}
+	return tokens
+}
+
+// tokenizeCased splits a comment body into words in their original case.
+func tokenizeCased(body string) []string {
+	return tokenizeText(plainText(body))
+}
+
+// plainText returns a comment body unescaped and without its entities.
+func plainText(body string) string {
+	return stripEntities(html.UnescapeString(body))
+}
+
+// tokenizeText splits the plainText of a comment body, or part of it, into
+// words in their original case.
+func tokenizeText(text string) []string {
+	cleanedBody := cleanBody(strconv.QuoteToGraphic(text))
+
+	var tokens []string
+	for _, word := range strings.Split(cleanedBody, " ") {
+		if word == "" {
+			continue
+		}
+		tokens = append(tokens, word)
+	}
+
+	return tokens
+}
+
+func countWords(tokens []string) map[string]int {
+	words := make(map[string]int)
+	for _, token := range tokens {
+		words[token]++
+	}
+	return words
+}
+
+// weightWords returns words with every count multiplied by weight.
+func weightWords(words map[string]int, weight int) map[string]int {
+	weighted := make(map[string]int, len(words))
+	for word, count := range words {
+		weighted[word] = count * weight
+	}
+	return weighted
+}
+
+func isDeletedBody(body string) bool {
+	return deletionFlag(body) != ""
+}
+
+func bodyHash(body string) string {
+	sum := sha256.Sum256([]byte(body))
+	return hex.EncodeToString(sum[:16])
+}
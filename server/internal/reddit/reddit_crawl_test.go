@@ -0,0 +1,237 @@
+package reddit
+
+import (
+	"reflect"
+	"testing"
+)
+
+var testLink = &Link{Subreddit: "r/test", CommentId: "t"}
+
+// storedComment returns comment id of the thread as counted by an earlier crawl.
+func storedComment(id string, body string) CommentDocument {
+	doc := newCommentDocument(testLink.scid(), &RedditRepliesObject{Id: id, Body: body}, countWords(tokenize(body)))
+	doc.Language = detectLanguage(body)
+	doc.Facets = extractFacets(body)
+	return doc
+}
+
+// nonZero returns the deltas of m that change anything.
+func nonZero(m map[string]int) map[string]int {
+	out := make(map[string]int)
+	for key, value := range m {
+		if value != 0 {
+			out[key] = value
+		}
+	}
+	return out
+}
+
+func TestCrawlCount(t *testing.T) {
+	pending := storedComment("p", "go is great")
+	pending.Pending = &PendingCount{Batch: "lost", Words: map[string]int{"rust": 1}, Language: "en"}
+
+	applied := storedComment("a", "go is great")
+	applied.Pending = &PendingCount{Batch: "written", Words: map[string]int{"rust": 1}, Language: "en"}
+
+	tests := []struct {
+		name        string
+		counted     []CommentDocument
+		comment     RedditRepliesObject
+		wantWords   map[string]int
+		wantExclude map[string]int
+		wantPending bool
+	}{
+		{
+			name:        "new comment",
+			comment:     RedditRepliesObject{Id: "n", Body: "hello hello world"},
+			wantWords:   map[string]int{"hello": 2, "world": 1},
+			wantExclude: map[string]int{},
+			wantPending: true,
+		},
+		{
+			name:        "unchanged comment",
+			counted:     []CommentDocument{storedComment("u", "hello world")},
+			comment:     RedditRepliesObject{Id: "u", Body: "hello world", Score: 10},
+			wantWords:   map[string]int{},
+			wantExclude: map[string]int{},
+		},
+		{
+			name:        "edited comment",
+			counted:     []CommentDocument{storedComment("e", "hello world")},
+			comment:     RedditRepliesObject{Id: "e", Body: "hello there"},
+			wantWords:   map[string]int{"world": -1, "there": 1},
+			wantExclude: map[string]int{},
+			wantPending: true,
+		},
+		{
+			name:        "deleted comment",
+			counted:     []CommentDocument{storedComment("d", "hello world")},
+			comment:     RedditRepliesObject{Id: "d", Body: deletedBody},
+			wantWords:   map[string]int{"hello": -1, "world": -1},
+			wantExclude: map[string]int{flagDeleted: 1},
+			wantPending: true,
+		},
+		{
+			name:        "removed comment",
+			counted:     []CommentDocument{storedComment("r", "hello world")},
+			comment:     RedditRepliesObject{Id: "r", Body: removedBody},
+			wantWords:   map[string]int{"hello": -1, "world": -1},
+			wantExclude: map[string]int{flagRemoved: 1},
+			wantPending: true,
+		},
+		{
+			name:        "pending comment whose words were not written",
+			counted:     []CommentDocument{pending},
+			comment:     RedditRepliesObject{Id: "p", Body: "go is great"},
+			wantWords:   map[string]int{"rust": -1, "go": 1, "is": 1, "great": 1},
+			wantExclude: map[string]int{},
+			wantPending: true,
+		},
+		{
+			name:        "pending comment whose words were written",
+			counted:     []CommentDocument{applied},
+			comment:     RedditRepliesObject{Id: "a", Body: "go is great"},
+			wantWords:   map[string]int{},
+			wantExclude: map[string]int{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cr := newCrawl(testLink.scid(), testLink, tt.counted, map[string]bool{"written": true}, BotConfig{MinDuplicateLength: 30})
+			b := newBatch()
+			cr.count(&tt.comment, b)
+
+			if got := nonZero(b.words.Items()); !reflect.DeepEqual(got, tt.wantWords) {
+				t.Errorf("words = %v, want %v", got, tt.wantWords)
+			}
+			if got := nonZero(b.excluded.Items()); !reflect.DeepEqual(got, tt.wantExclude) {
+				t.Errorf("excluded = %v, want %v", got, tt.wantExclude)
+			}
+
+			doc, ok := b.comments.Get(tt.comment.Id)
+			if !ok {
+				t.Fatalf("comment %s was not recorded", tt.comment.Id)
+			}
+			if got := doc.Pending != nil; got != tt.wantPending {
+				t.Errorf("pending = %v, want %v", got, tt.wantPending)
+			}
+			if doc.Pending != nil && doc.Pending.Batch != b.id {
+				t.Errorf("pending batch = %q, want %q", doc.Pending.Batch, b.id)
+			}
+
+			// A comment returned twice in a crawl is counted once.
+			again := newBatch()
+			cr.count(&tt.comment, again)
+			if again.comments.Count() != 0 {
+				t.Errorf("comment counted twice")
+			}
+		})
+	}
+}
+
+func TestCrawlVanished(t *testing.T) {
+	counted := []CommentDocument{
+		storedComment("seen", "still here"),
+		storedComment("gone", "hello world"),
+		storedComment("deleted", deletedBody),
+	}
+	cr := newCrawl(testLink.scid(), testLink, counted, nil, BotConfig{})
+	cr.count(&RedditRepliesObject{Id: "seen", Body: "still here"}, newBatch())
+
+	b := cr.vanished()
+
+	if want := map[string]int{"hello": -1, "world": -1}; !reflect.DeepEqual(nonZero(b.words.Items()), want) {
+		t.Errorf("words = %v, want %v", b.words.Items(), want)
+	}
+	if want := map[string]int{flagDeleted: 1}; !reflect.DeepEqual(nonZero(b.excluded.Items()), want) {
+		t.Errorf("excluded = %v, want %v", b.excluded.Items(), want)
+	}
+	if ids := b.comments.Keys(); !reflect.DeepEqual(ids, []string{"gone"}) {
+		t.Fatalf("vanished comments = %v, want [gone]", ids)
+	}
+
+	gone, _ := b.comments.Get("gone")
+	if gone.Body != deletedBody || gone.Words != nil || gone.Pending == nil {
+		t.Errorf("vanished comment = %+v, want a pending deleted comment without words", gone)
+	}
+}
+
+func TestCrawlAppliedBatches(t *testing.T) {
+	a := storedComment("a", "one")
+	a.Pending = &PendingCount{Batch: "written"}
+	b := storedComment("b", "two")
+	b.Pending = &PendingCount{Batch: "written"}
+	c := storedComment("c", "three")
+	c.Pending = &PendingCount{Batch: "lost"}
+
+	applied := map[string]bool{"written": true}
+	cr := newCrawl(testLink.scid(), testLink, []CommentDocument{a, b, c}, applied, BotConfig{})
+
+	if got := cr.appliedBatches(applied); !reflect.DeepEqual(got, []string{"written"}) {
+		t.Errorf("appliedBatches() = %v, want [written]", got)
+	}
+}
+
+func TestCrawlCountStale(t *testing.T) {
+	body := "Honestly the new update made the game so much worse for everyone https://example.com/patch"
+
+	legacy := storedComment("l", body)
+	legacy.Tokenizer = 0
+	legacy.Language = ""
+	legacy.Facets = nil
+	legacy.Words = map[string]int{"Honestly": 1}
+
+	current := storedComment("c", body)
+
+	tests := []struct {
+		name          string
+		counted       CommentDocument
+		wantPending   bool
+		wantLanguages map[string]int
+		wantFacets    map[string]int
+	}{
+		{
+			name:          "comment counted by an earlier tokenizer",
+			counted:       legacy,
+			wantPending:   true,
+			wantLanguages: map[string]int{"en": 1},
+			wantFacets:    map[string]int{facetDelta(facetDomains, "example.com"): 1},
+		},
+		{name: "comment counted by the current tokenizer", counted: current, wantLanguages: map[string]int{}, wantFacets: map[string]int{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cr := newCrawl("r/test/comments/t", nil, []CommentDocument{tt.counted}, nil, BotConfig{})
+			b := newBatch()
+			cr.count(&RedditRepliesObject{Id: tt.counted.CommentId, Body: body}, b)
+
+			doc, _ := b.comments.Get(tt.counted.CommentId)
+			if got := doc.Pending != nil; got != tt.wantPending {
+				t.Errorf("pending = %v, want %v", got, tt.wantPending)
+			}
+			if doc.Tokenizer != tokenizerVersion {
+				t.Errorf("tokenizer = %d, want %d", doc.Tokenizer, tokenizerVersion)
+			}
+			if doc.Language != "en" {
+				t.Errorf("language = %q, want en", doc.Language)
+			}
+			if !reflect.DeepEqual(doc.Words, countWords(tokenize(body))) {
+				t.Errorf("words = %v, want the words of the current tokenizer", doc.Words)
+			}
+			if got := nonZero(b.languages.Items()); !reflect.DeepEqual(got, tt.wantLanguages) {
+				t.Errorf("languages = %v, want %v", got, tt.wantLanguages)
+			}
+			if got := nonZero(b.facets.Items()); !reflect.DeepEqual(got, tt.wantFacets) {
+				t.Errorf("facets = %v, want %v", got, tt.wantFacets)
+			}
+			if !reflect.DeepEqual(doc.Facets, Facets{facetDomains: {"example.com": 1}}) {
+				t.Errorf("comment facets = %v, want the domain of its link", doc.Facets)
+			}
+			if tt.wantPending && b.words.Items()["Honestly"] != -1 {
+				t.Errorf("words of the earlier tokenizer were not subtracted: %v", b.words.Items())
+			}
+		})
+	}
+}
@@ -0,0 +1,253 @@
+package reddit
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"redditwordcloud/pkg/util"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/parquet-go/parquet-go"
+	"go.uber.org/zap"
+)
+
+// Formats the words endpoints respond in, chosen with ?format= or the Accept header.
+const (
+	formatJSON    = "json"
+	formatCSV     = "csv"
+	formatTSV     = "tsv"
+	formatNDJSON  = "ndjson"
+	formatParquet = "parquet"
+)
+
+var exportContentTypes = map[string]string{
+	formatCSV:     "text/csv",
+	formatTSV:     "text/tab-separated-values",
+	formatNDJSON:  "application/x-ndjson",
+	formatParquet: "application/vnd.apache.parquet",
+}
+
+// exportFlushRows is the number of rows written between flushes of a streamed export.
+const exportFlushRows = 1000
+
+type ExportFormatQuery struct {
+	Format string `form:"format" binding:"omitempty,oneof=json csv tsv ndjson parquet"`
+}
+
+// wordStats are the statistics of the words of a cloud that are only
+// exported, computed when the request asks for them.
+type wordStats struct {
+	// counts are the unweighted counts of the words, nil when they are the cloud's.
+	counts map[string]int
+	// documentFrequencies count the comments, or posts, containing each word.
+	documentFrequencies map[string]int
+}
+
+// WordRow is a word of an exported cloud.
+type WordRow struct {
+	Rank              int    `json:"rank" parquet:"rank"`
+	Word              string `json:"word" parquet:"word"`
+	Count             int    `json:"count" parquet:"count"`
+	WeightedCount     int    `json:"weightedCount" parquet:"weighted_count"`
+	DocumentFrequency int    `json:"documentFrequency" parquet:"document_frequency"`
+}
+
+var wordRowHeader = []string{"rank", "word", "count", "weighted_count", "document_frequency"}
+
+func (row WordRow) record() []string {
+	return []string{
+		strconv.Itoa(row.Rank),
+		row.Word,
+		strconv.Itoa(row.Count),
+		strconv.Itoa(row.WeightedCount),
+		strconv.Itoa(row.DocumentFrequency),
+	}
+}
+
+// exportFormat returns the format of the response to c, from the format query
+// parameter or else the Accept header, JSON when neither asks for an export.
+func exportFormat(c *gin.Context) (string, error) {
+	var query ExportFormatQuery
+
+	if err := c.ShouldBindQuery(&query); err != nil {
+		return "", err
+	}
+
+	if query.Format != "" {
+		return query.Format, nil
+	}
+
+	accepted := c.NegotiateFormat(binding.MIMEJSON, exportContentTypes[formatCSV], exportContentTypes[formatTSV],
+		exportContentTypes[formatNDJSON], exportContentTypes[formatParquet])
+
+	for format, contentType := range exportContentTypes {
+		if accepted == contentType {
+			return format, nil
+		}
+	}
+
+	return formatJSON, nil
+}
+
+// wordRows returns the rows of the words of a cloud, ranked by their weighted
+// count. counts are the unweighted counts, nil when they are the weighted ones.
+func wordRows(weighted map[string]int, stats wordStats) []WordRow {
+	ranked := rankWords(weighted, nil)
+	rows := make([]WordRow, 0, len(ranked))
+
+	for _, w := range ranked {
+		row := WordRow{Rank: w.Rank, Word: w.Word, Count: w.Count, WeightedCount: w.Count, DocumentFrequency: stats.documentFrequencies[w.Word]}
+		if stats.counts != nil {
+			row.Count = stats.counts[w.Word]
+		}
+		rows = append(rows, row)
+	}
+
+	return rows
+}
+
+// postWordStats returns the word stats of a cloud aggregated from posts, whose
+// documents are the posts.
+func postWordStats(posts []PostWords) wordStats {
+	df := make(map[string]int)
+
+	for _, post := range posts {
+		for word := range util.CombineMaps(post.Words, post.TextWords) {
+			df[word]++
+		}
+	}
+
+	return wordStats{documentFrequencies: df}
+}
+
+// threadWordStats returns the document frequencies of the words of the cloud
+// of a thread built with opts, whose documents are its comments and, if it is
+// included, its post.
+func (svc *service) threadWordStats(c context.Context, wordDoc *WordDocument, opts cloudOptions, includePost bool) (wordStats, error) {
+	scid := wordDoc.SubredditAndCommentId
+	comments, err := svc.Repository.GetComments(c, scid)
+
+	if err != nil {
+		return wordStats{}, fmt.Errorf("could not get comments of %s: %w", scid, err)
+	}
+
+	env := threadFilterEnv(wordDoc, comments)
+	if opts, err = svc.withPhrases(c, opts, wordDoc, comments); err != nil {
+		return wordStats{}, err
+	}
+	stats := wordStats{documentFrequencies: make(map[string]int)}
+
+	for i := range comments {
+		if !opts.counts(&comments[i], env) {
+			continue
+		}
+		for word := range opts.words(&comments[i]) {
+			stats.documentFrequencies[word]++
+		}
+	}
+
+	if post := wordDoc.Post; includePost && post != nil {
+		for word := range opts.postWords(post) {
+			stats.documentFrequencies[word]++
+		}
+	}
+
+	return stats, nil
+}
+
+// exportRetryAfter is how long, in seconds, clients are told to wait before
+// exporting a cloud that is being crawled for the first time.
+const exportRetryAfter = 30
+
+// writeExportNotReady answers the export of a cloud that has not been
+// crawled yet. The crawl is started by the request, the export is available
+// once it completes.
+func writeExportNotReady(c *gin.Context, link string) {
+	c.Header("Retry-After", strconv.Itoa(exportRetryAfter))
+	c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("%s is being crawled, retry once the crawl completes", link), "refreshing": true})
+}
+
+// writeExport streams rows to the response in format, flushing as it goes so
+// large clouds are not buffered whole.
+func writeExport(c *gin.Context, format string, rows []WordRow) {
+	c.Header("Content-Type", exportContentTypes[format])
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"words.%s\"", format))
+	c.Status(http.StatusOK)
+
+	var err error
+	switch format {
+	case formatCSV, formatTSV:
+		err = writeDelimited(c, format, rows)
+	case formatNDJSON:
+		err = writeNDJSON(c, rows)
+	case formatParquet:
+		err = writeParquet(c, rows)
+	}
+
+	// The status is sent, a failure can only cut the export short.
+	if err != nil {
+		zap.S().Errorf("Could not write %s export: %w", format, err)
+	}
+}
+
+func writeDelimited(c *gin.Context, format string, rows []WordRow) error {
+	w := csv.NewWriter(c.Writer)
+	if format == formatTSV {
+		w.Comma = '\t'
+	}
+
+	if err := w.Write(wordRowHeader); err != nil {
+		return err
+	}
+
+	for i, row := range rows {
+		if err := w.Write(row.record()); err != nil {
+			return err
+		}
+		if (i+1)%exportFlushRows == 0 {
+			w.Flush()
+			c.Writer.Flush()
+		}
+	}
+
+	w.Flush()
+	return w.Error()
+}
+
+func writeNDJSON(c *gin.Context, rows []WordRow) error {
+	enc := json.NewEncoder(c.Writer)
+
+	for i, row := range rows {
+		if err := enc.Encode(row); err != nil {
+			return err
+		}
+		if (i+1)%exportFlushRows == 0 {
+			c.Writer.Flush()
+		}
+	}
+
+	return nil
+}
+
+// writeParquet writes every exportFlushRows rows as a row group, flushed to
+// the response before the next one is encoded.
+func writeParquet(c *gin.Context, rows []WordRow) error {
+	w := parquet.NewGenericWriter[WordRow](c.Writer)
+
+	for start := 0; start < len(rows); start += exportFlushRows {
+		end := min(start+exportFlushRows, len(rows))
+		if _, err := w.Write(rows[start:end]); err != nil {
+			return err
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		c.Writer.Flush()
+	}
+
+	return w.Close()
+}
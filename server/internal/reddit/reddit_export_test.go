@@ -0,0 +1,151 @@
+package reddit
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/parquet-go/parquet-go"
+)
+
+func TestWordRows(t *testing.T) {
+	weighted := map[string]int{"go": 6, "rust": 2, "zig": 2}
+
+	tests := []struct {
+		name  string
+		stats wordStats
+		want  []WordRow
+	}{
+		{
+			name:  "unweighted",
+			stats: wordStats{documentFrequencies: map[string]int{"go": 3, "rust": 1}},
+			want: []WordRow{
+				{Rank: 1, Word: "go", Count: 6, WeightedCount: 6, DocumentFrequency: 3},
+				{Rank: 2, Word: "rust", Count: 2, WeightedCount: 2, DocumentFrequency: 1},
+				{Rank: 3, Word: "zig", Count: 2, WeightedCount: 2},
+			},
+		},
+		{
+			name:  "weighted",
+			stats: wordStats{counts: map[string]int{"go": 4, "rust": 2, "zig": 1}},
+			want: []WordRow{
+				{Rank: 1, Word: "go", Count: 4, WeightedCount: 6},
+				{Rank: 2, Word: "rust", Count: 2, WeightedCount: 2},
+				{Rank: 3, Word: "zig", Count: 1, WeightedCount: 2},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := wordRows(weighted, tt.stats); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("wordRows() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// testRows returns n rows.
+func testRows(n int) []WordRow {
+	rows := make([]WordRow, n)
+	for i := range rows {
+		rows[i] = WordRow{Rank: i + 1, Word: fmt.Sprintf("word%d", i), Count: n - i, WeightedCount: n - i, DocumentFrequency: 1}
+	}
+	return rows
+}
+
+func TestWriteExport(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	rows := testRows(2*exportFlushRows + 1)
+
+	tests := []struct {
+		format string
+		decode func(t *testing.T, body []byte) []WordRow
+	}{
+		{formatCSV, func(t *testing.T, body []byte) []WordRow { return decodeDelimited(t, body, ',') }},
+		{formatTSV, func(t *testing.T, body []byte) []WordRow { return decodeDelimited(t, body, '\t') }},
+		{formatNDJSON, func(t *testing.T, body []byte) []WordRow {
+			var got []WordRow
+			dec := json.NewDecoder(bytes.NewReader(body))
+			for dec.More() {
+				var row WordRow
+				if err := dec.Decode(&row); err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, row)
+			}
+			return got
+		}},
+		{formatParquet, func(t *testing.T, body []byte) []WordRow {
+			got, err := parquet.Read[WordRow](bytes.NewReader(body), int64(len(body)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			return got
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+
+			writeExport(c, tt.format, rows)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200", w.Code)
+			}
+			if got := w.Header().Get("Content-Type"); got != exportContentTypes[tt.format] {
+				t.Errorf("content type = %q, want %q", got, exportContentTypes[tt.format])
+			}
+			if got := tt.decode(t, w.Body.Bytes()); !reflect.DeepEqual(got, rows) {
+				t.Errorf("decoded %d rows, want the %d rows written", len(got), len(rows))
+			}
+		})
+	}
+}
+
+func decodeDelimited(t *testing.T, body []byte, comma rune) []WordRow {
+	r := csv.NewReader(bytes.NewReader(body))
+	r.Comma = comma
+	records, err := r.ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(records[0], wordRowHeader) {
+		t.Fatalf("header = %v, want %v", records[0], wordRowHeader)
+	}
+
+	rows := make([]WordRow, 0, len(records)-1)
+	for _, record := range records[1:] {
+		var row WordRow
+		if _, err := fmt.Sscan(strings.Join([]string{record[0], record[2], record[3], record[4]}, " "), &row.Rank, &row.Count, &row.WeightedCount, &row.DocumentFrequency); err != nil {
+			t.Fatal(err)
+		}
+		row.Word = record[1]
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+func TestWriteExportNotReady(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	writeExportNotReady(c, "r/test/comments/t")
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want 404", w.Code)
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Errorf("no Retry-After header")
+	}
+}
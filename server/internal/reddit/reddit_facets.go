@@ -0,0 +1,288 @@
+package reddit
+
+import (
+	"html"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+)
+
+// Kinds of the entities of comments counted apart from their words.
+const (
+	facetEmoji      = "emoji"
+	facetUsers      = "users"
+	facetSubreddits = "subreddits"
+	facetDomains    = "domains"
+)
+
+// maxFacetEntries is the number of entities of each kind returned with a cloud.
+const maxFacetEntries = 25
+
+// Facets count the entities of comments that are not words, by kind: emoji,
+// mentioned users as u/name, referenced subreddits as r/name and the domains
+// of links. They are stored as a FacetCount array of each kind, the entities
+// such as domains containing dots that keys of stored documents cannot.
+type Facets map[string]map[string]int
+
+// FacetCount is the count of an entity of stored Facets.
+type FacetCount struct {
+	Entity string `bson:"entity"`
+	Count  int    `bson:"count"`
+}
+
+// MarshalBSONValue stores f as a FacetCount array of each kind, sorted by entity.
+func (f Facets) MarshalBSONValue() (bsontype.Type, []byte, error) {
+	stored := make(map[string][]FacetCount, len(f))
+	for kind, entities := range f {
+		counts := make([]FacetCount, 0, len(entities))
+		for entity, count := range entities {
+			counts = append(counts, FacetCount{Entity: entity, Count: count})
+		}
+		sort.Slice(counts, func(i, j int) bool { return counts[i].Entity < counts[j].Entity })
+		stored[kind] = counts
+	}
+	return bson.MarshalValue(stored)
+}
+
+// UnmarshalBSONValue reads Facets stored as FacetCount arrays, or as the
+// maps of entities they were stored as before.
+func (f *Facets) UnmarshalBSONValue(t bsontype.Type, data []byte) error {
+	if t == bsontype.Null {
+		*f = nil
+		return nil
+	}
+
+	var kinds map[string]bson.RawValue
+	if err := (bson.RawValue{Type: t, Value: data}).Unmarshal(&kinds); err != nil {
+		return err
+	}
+
+	facets := make(Facets, len(kinds))
+	for kind, value := range kinds {
+		if value.Type == bsontype.EmbeddedDocument {
+			var entities map[string]int
+			if err := value.Unmarshal(&entities); err != nil {
+				return err
+			}
+			for entity, count := range entities {
+				facets.add(kind, entity, count)
+			}
+			continue
+		}
+
+		var counts []FacetCount
+		if err := value.Unmarshal(&counts); err != nil {
+			return err
+		}
+		for _, c := range counts {
+			facets.add(kind, c.Entity, c.Count)
+		}
+	}
+
+	*f = facets
+	return nil
+}
+
+var (
+	// The parentheses around the target of a markdown link go with it.
+	linkPattern = regexp.MustCompile(`(?i)(?:\]\()?\b(?:https?://|www\.)[^\s<>()\[\]"']+\)?`)
+	// A reference starts a word, optionally after a slash: u/name, /r/name.
+	referencePattern = regexp.MustCompile(`(?i)(^|[^\w/])/?([ur])/([a-z0-9_-]{2,21})`)
+)
+
+// extractFacets returns the entities of a comment body.
+func extractFacets(body string) Facets {
+	facets := make(Facets)
+	splitEntities(html.UnescapeString(body), facets)
+	return facets
+}
+
+// stripEntities returns body without its entities, which tokenize would
+// otherwise mangle into words.
+func stripEntities(body string) string {
+	return splitEntities(body, nil)
+}
+
+// splitEntities removes the entities of body, counting them in facets if it is not nil.
+func splitEntities(body string, facets Facets) string {
+	// Links go first, so the subreddits in their paths are not counted as references.
+	body = linkPattern.ReplaceAllStringFunc(body, func(link string) string {
+		if domain := linkDomain(link); domain != "" {
+			facets.add(facetDomains, domain, 1)
+		}
+		// The punctuation after a link ends its sentence rather than the link.
+		return " " + link[len(strings.TrimRight(link, ".,;:!?")):]
+	})
+
+	body = referencePattern.ReplaceAllStringFunc(body, func(reference string) string {
+		m := referencePattern.FindStringSubmatch(reference)
+		kind := facetUsers
+		if strings.EqualFold(m[2], "r") {
+			kind = facetSubreddits
+		}
+		facets.add(kind, strings.ToLower(m[2]+"/"+m[3]), 1)
+		return m[1] + " "
+	})
+
+	var b strings.Builder
+	for i := 0; i < len(body); {
+		if n := emojiLength(body[i:]); n > 0 {
+			facets.add(facetEmoji, body[i:i+n], 1)
+			b.WriteByte(' ')
+			i += n
+			continue
+		}
+		_, size := utf8.DecodeRuneInString(body[i:])
+		b.WriteString(body[i : i+size])
+		i += size
+	}
+
+	return b.String()
+}
+
+// linkDomain returns the domain of a link, without its www. prefix.
+func linkDomain(link string) string {
+	link = strings.TrimRight(strings.TrimPrefix(link, "]("), ".,;:!?*)")
+	if !strings.Contains(link, "://") {
+		link = "http://" + link
+	}
+
+	u, err := url.Parse(link)
+
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+}
+
+// emojiLength returns the length in bytes of the emoji s starts with, 0 if
+// it does not start with one. An emoji is a pictograph followed by its
+// variation selectors, skin tones and the pictographs it is joined to, a flag
+// made of two regional indicators, or a keycap.
+func emojiLength(s string) int {
+	r, size := utf8.DecodeRuneInString(s)
+
+	if isRegionalIndicator(r) {
+		if next, n := utf8.DecodeRuneInString(s[size:]); isRegionalIndicator(next) {
+			return size + n
+		}
+		return size
+	}
+
+	if (r == '#' || r == '*' || (r >= '0' && r <= '9')) && strings.HasPrefix(s[size:], "\uFE0F\u20E3") {
+		return size + len("\uFE0F\u20E3")
+	}
+
+	if !isPictograph(r) {
+		return 0
+	}
+
+	for size < len(s) {
+		next, n := utf8.DecodeRuneInString(s[size:])
+		switch {
+		case next == '\uFE0F' || (next >= 0x1F3FB && next <= 0x1F3FF):
+			size += n
+		case next == '\u200D':
+			joined, m := utf8.DecodeRuneInString(s[size+n:])
+			if !isPictograph(joined) {
+				return size
+			}
+			size += n + m
+		default:
+			return size
+		}
+	}
+
+	return size
+}
+
+func isRegionalIndicator(r rune) bool {
+	return r >= 0x1F1E6 && r <= 0x1F1FF
+}
+
+func isPictograph(r rune) bool {
+	switch {
+	case r >= 0x1F300 && r <= 0x1FAFF, // Pictographs, emoticons, transport, supplemental symbols
+		r >= 0x1F000 && r <= 0x1F2FF, // Mahjong, domino and playing cards, enclosed characters
+		r >= 0x2600 && r <= 0x27BF,   // Miscellaneous symbols, dingbats
+		r >= 0x2B00 && r <= 0x2BFF,   // Stars, arrows and squares
+		r >= 0x231A && r <= 0x23FF,   // Watch, hourglass and media controls
+		r == 0x2764:
+		return true
+	}
+	return false
+}
+
+// add adds count to the entity of kind. It does nothing on nil facets.
+func (f Facets) add(kind string, entity string, count int) {
+	if f == nil {
+		return
+	}
+	if f[kind] == nil {
+		f[kind] = make(map[string]int)
+	}
+	f[kind][entity] += count
+}
+
+// addAll adds the counts of other multiplied by sign.
+func (f Facets) addAll(other Facets, sign int) {
+	for kind, entities := range other {
+		for entity, count := range entities {
+			f.add(kind, entity, sign*count)
+		}
+	}
+}
+
+// prune drops the entities no longer counted after reconciled comments
+// subtracted their old counts.
+func (f Facets) prune() {
+	for kind, entities := range f {
+		for entity, count := range entities {
+			if count <= 0 {
+				delete(entities, entity)
+			}
+		}
+		if len(entities) == 0 {
+			delete(f, kind)
+		}
+	}
+}
+
+// ranked returns the most frequent entities of each kind.
+func (f Facets) ranked() map[string][]RankedWord {
+	if len(f) == 0 {
+		return nil
+	}
+
+	ranked := make(map[string][]RankedWord, len(f))
+	for kind, entities := range f {
+		entries := rankWords(entities, nil)
+		if len(entries) > maxFacetEntries {
+			entries = entries[:maxFacetEntries]
+		}
+		ranked[kind] = entries
+	}
+
+	return ranked
+}
+
+// facetDelta encodes the entity of kind as a key of the facets of a batch.
+func facetDelta(kind string, entity string) string {
+	return kind + "/" + entity
+}
+
+// facetsOfDeltas decodes the facets of a batch.
+func facetsOfDeltas(deltas map[string]int) Facets {
+	facets := make(Facets)
+	for key, count := range deltas {
+		kind, entity, _ := strings.Cut(key, "/")
+		facets.add(kind, entity, count)
+	}
+	return facets
+}
@@ -0,0 +1,190 @@
+package reddit
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestEmojiLength(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		want int
+	}{
+		{name: "not an emoji", s: "hello", want: 0},
+		{name: "empty", s: "", want: 0},
+		{name: "pictograph", s: "😂 lol", want: len("😂")},
+		{name: "variation selector", s: "❤️!", want: len("❤️")},
+		{name: "skin tone", s: "👍🏽 yes", want: len("👍🏽")},
+		{name: "joined pictographs", s: "👩‍💻 code", want: len("👩‍💻")},
+		{name: "joiner without a pictograph", s: "👩‍x", want: len("👩")},
+		{name: "flag", s: "🇫🇷 France", want: len("🇫🇷")},
+		{name: "lone regional indicator", s: "🇫 x", want: len("🇫")},
+		{name: "keycap", s: "1️⃣ first", want: len("1️⃣")},
+		{name: "digit", s: "1 first", want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := emojiLength(tt.s); got != tt.want {
+				t.Errorf("emojiLength(%q) = %d, want %d", tt.s, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitEntities(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantBody   string
+		wantFacets Facets
+	}{
+		{
+			name:       "no entities",
+			body:       "just words here",
+			wantBody:   "just words here",
+			wantFacets: Facets{},
+		},
+		{
+			name:       "link",
+			body:       "see https://www.Example.com/r/golang/page for more",
+			wantBody:   "see   for more",
+			wantFacets: Facets{facetDomains: {"example.com": 1}},
+		},
+		{
+			name:       "link ending a sentence",
+			body:       "see https://go.dev/doc. Then",
+			wantBody:   "see  . Then",
+			wantFacets: Facets{facetDomains: {"go.dev": 1}},
+		},
+		{
+			name:       "markdown link",
+			body:       "[docs](https://go.dev/doc).",
+			wantBody:   "[docs .",
+			wantFacets: Facets{facetDomains: {"go.dev": 1}},
+		},
+		{
+			name:       "references",
+			body:       "ask u/Someone in /r/golang or r/Go",
+			wantBody:   "ask   in   or  ",
+			wantFacets: Facets{facetUsers: {"u/someone": 1}, facetSubreddits: {"r/golang": 1, "r/go": 1}},
+		},
+		{
+			name:       "reference inside a word",
+			body:       "either/or and/r/golang",
+			wantBody:   "either/or and/r/golang",
+			wantFacets: Facets{},
+		},
+		{
+			name:       "emoji",
+			body:       "great😂😂 job 👍🏽",
+			wantBody:   "great   job  ",
+			wantFacets: Facets{facetEmoji: {"😂": 2, "👍🏽": 1}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			facets := make(Facets)
+			if got := splitEntities(tt.body, facets); got != tt.wantBody {
+				t.Errorf("splitEntities() body = %q, want %q", got, tt.wantBody)
+			}
+			if !reflect.DeepEqual(facets, tt.wantFacets) {
+				t.Errorf("splitEntities() facets = %v, want %v", facets, tt.wantFacets)
+			}
+			if got := stripEntities(tt.body); got != tt.wantBody {
+				t.Errorf("stripEntities() = %q, want %q", got, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestFacetsBSON(t *testing.T) {
+	type doc struct {
+		Facets Facets `bson:"facets,omitempty"`
+	}
+
+	tests := []struct {
+		name   string
+		stored bson.M
+		want   Facets
+	}{
+		{
+			name: "entity arrays",
+			stored: bson.M{"facets": bson.M{
+				facetDomains: bson.A{bson.M{"entity": "example.com", "count": 2}},
+				facetEmoji:   bson.A{bson.M{"entity": "😂", "count": 1}},
+			}},
+			want: Facets{facetDomains: {"example.com": 2}, facetEmoji: {"😂": 1}},
+		},
+		{
+			name:   "maps of entities stored before arrays",
+			stored: bson.M{"facets": bson.M{facetUsers: bson.M{"u/someone": 3}}},
+			want:   Facets{facetUsers: {"u/someone": 3}},
+		},
+		{
+			name:   "no facets",
+			stored: bson.M{},
+			want:   nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := bson.Marshal(tt.stored)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got doc
+			if err := bson.Unmarshal(data, &got); err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+			if !reflect.DeepEqual(got.Facets, tt.want) {
+				t.Errorf("Unmarshal() facets = %v, want %v", got.Facets, tt.want)
+			}
+		})
+	}
+
+	t.Run("round trip", func(t *testing.T) {
+		facets := Facets{facetDomains: {"example.com": 2, "go.dev": 1}, facetSubreddits: {"r/golang": 1}}
+
+		data, err := bson.Marshal(doc{Facets: facets})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var raw bson.M
+		if err := bson.Unmarshal(data, &raw); err != nil {
+			t.Fatal(err)
+		}
+		domains, ok := raw["facets"].(bson.M)[facetDomains].(bson.A)
+		if !ok || len(domains) != 2 {
+			t.Fatalf("stored domains = %v, want an array of 2 entities", raw["facets"])
+		}
+		if key := domains[0].(bson.M)["entity"]; key != "example.com" {
+			t.Errorf("first stored domain = %v, want example.com", key)
+		}
+
+		var got doc
+		if err := bson.Unmarshal(data, &got); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got.Facets, facets) {
+			t.Errorf("round trip facets = %v, want %v", got.Facets, facets)
+		}
+	})
+}
+
+func TestExtractFacets(t *testing.T) {
+	body := strings.Join([]string{"&lt;3 ❤️", "https://example.com/a", "https://example.com/b"}, " ")
+	want := Facets{facetEmoji: {"❤️": 1}, facetDomains: {"example.com": 2}}
+
+	if got := extractFacets(body); !reflect.DeepEqual(got, want) {
+		t.Errorf("extractFacets() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,639 @@
+package reddit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"redditwordcloud/pkg/phrases"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/go-playground/validator/v10"
+	cmap "github.com/orcaman/concurrent-map/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+)
+
+/*
+Comment filters select the comments counted in a cloud. A filter is a boolean
+expression over the metadata of a comment:
+
+	score >= 10 && depth == 0
+	author != "AutoModerator" && !(author =~ "bot$")
+	age <= 2h || stickied == true
+
+Fields:
+	- score (number), the comment's score.
+	- depth (number), 0 for top-level comments.
+	- age (duration), the time between the thread's creation and the comment.
+	- author (string), compared case-insensitively.
+	- distinguished (string), "moderator", "admin" or "" for regular comments.
+	- stickied (bool).
+
+Operators are == != < <= > >= between a field and a value, =~ and !~ to match
+a string field against a regular expression, && || ! and parentheses.
+*/
+
+// commentFilter is a parsed filter expression.
+type commentFilter struct {
+	expr filterExpr
+}
+
+// filterEnv is what a filter needs to know about a thread besides a comment.
+type filterEnv struct {
+	threadCreated time.Time
+}
+
+type filterExpr interface {
+	match(comment *CommentDocument, env filterEnv) bool
+	String() string
+}
+
+func ValidateFilter(fl validator.FieldLevel) bool {
+	_, err := parseFilter(fl.Field().String())
+	return err == nil
+}
+
+// parseFilter parses a filter expression. An empty expression returns a nil
+// filter, which matches every comment.
+func parseFilter(s string) (*commentFilter, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+
+	tokens, err := lexFilter(s)
+
+	if err != nil {
+		return nil, err
+	}
+
+	p := &filterParser{tokens: tokens}
+	expr, err := p.parseOr()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if p.pos != len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q in filter", p.tokens[p.pos].text)
+	}
+
+	return &commentFilter{expr: expr}, nil
+}
+
+func (f *commentFilter) match(comment *CommentDocument, env filterEnv) bool {
+	return f == nil || f.expr.match(comment, env)
+}
+
+// String returns the canonical form of the filter, equal for filters that
+// only differ in spacing, parentheses or the case of author names.
+func (f *commentFilter) String() string {
+	if f == nil {
+		return ""
+	}
+	return f.expr.String()
+}
+
+// cloudOptions select the comments of a thread counted in a cloud, and how
+// their words are counted, when they differ from the default of every word of
+// every comment that is not flagged.
+type cloudOptions struct {
+	filter *commentFilter
+	// includeFlagged counts the comments flagged as bots or spam.
+	includeFlagged bool
+	// language keeps the comments detected in a language.
+	language string
+	// removeStopWords and stem apply the stages of the language of each comment.
+	removeStopWords bool
+	stem            bool
+	// phrases counts names and collocations as single terms, found by the
+	// model of the thread set by withPhrases.
+	phrases bool
+	model   *phrases.Model
+}
+
+func (opts cloudOptions) isDefault() bool {
+	return opts.filter == nil && !opts.includeFlagged && opts.language == "" && !opts.removeStopWords && !opts.stem && !opts.phrases
+}
+
+func (opts cloudOptions) counts(comment *CommentDocument, env filterEnv) bool {
+	return (opts.includeFlagged || comment.Flag == "") &&
+		(opts.language == "" || commentLanguage(comment) == opts.language) &&
+		opts.filter.match(comment, env)
+}
+
+// fingerprint identifies the options in the key of the clouds built with them.
+func (opts cloudOptions) fingerprint() string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s|flagged=%t|language=%s|stopwords=%t|stem=%t|phrases=%t",
+		opts.filter, opts.includeFlagged, opts.language, opts.removeStopWords, opts.stem, opts.phrases)))
+	return hex.EncodeToString(sum[:8])
+}
+
+type andExpr struct{ left, right filterExpr }
+
+func (e andExpr) match(comment *CommentDocument, env filterEnv) bool {
+	return e.left.match(comment, env) && e.right.match(comment, env)
+}
+
+func (e andExpr) String() string { return fmt.Sprintf("(%s && %s)", e.left, e.right) }
+
+type orExpr struct{ left, right filterExpr }
+
+func (e orExpr) match(comment *CommentDocument, env filterEnv) bool {
+	return e.left.match(comment, env) || e.right.match(comment, env)
+}
+
+func (e orExpr) String() string { return fmt.Sprintf("(%s || %s)", e.left, e.right) }
+
+type notExpr struct{ expr filterExpr }
+
+func (e notExpr) match(comment *CommentDocument, env filterEnv) bool {
+	return !e.expr.match(comment, env)
+}
+
+func (e notExpr) String() string { return fmt.Sprintf("!%s", e.expr) }
+
+// comparison compares a field of a comment with a value of the field's type.
+type comparison struct {
+	field string
+	op    string
+	num   int64
+	str   string
+	re    *regexp.Regexp
+}
+
+func (e comparison) match(comment *CommentDocument, env filterEnv) bool {
+	switch e.field {
+	case "score":
+		return compareNumbers(int64(comment.Score), e.op, e.num)
+	case "depth":
+		return compareNumbers(int64(comment.Depth), e.op, e.num)
+	case "age":
+		return compareNumbers(int64(comment.CreatedUTC.Time().Sub(env.threadCreated)), e.op, e.num)
+	case "stickied":
+		return compareNumbers(boolToInt(comment.Stickied), e.op, e.num)
+	case "author":
+		return compareStrings(strings.ToLower(comment.Author), e.op, e.str, e.re)
+	default:
+		return compareStrings(comment.Distinguished, e.op, e.str, e.re)
+	}
+}
+
+func (e comparison) String() string {
+	switch {
+	case e.re != nil:
+		return fmt.Sprintf("%s %s %s", e.field, e.op, strconv.Quote(e.re.String()))
+	case e.field == "age":
+		return fmt.Sprintf("%s %s %s", e.field, e.op, time.Duration(e.num))
+	case e.field == "stickied":
+		return fmt.Sprintf("%s %s %t", e.field, e.op, e.num == 1)
+	case e.field == "author" || e.field == "distinguished":
+		return fmt.Sprintf("%s %s %s", e.field, e.op, strconv.Quote(e.str))
+	default:
+		return fmt.Sprintf("%s %s %d", e.field, e.op, e.num)
+	}
+}
+
+func compareNumbers(a int64, op string, b int64) bool {
+	switch op {
+	case "==":
+		return a == b
+	case "!=":
+		return a != b
+	case "<":
+		return a < b
+	case "<=":
+		return a <= b
+	case ">":
+		return a > b
+	default:
+		return a >= b
+	}
+}
+
+func compareStrings(a string, op string, b string, re *regexp.Regexp) bool {
+	switch op {
+	case "==":
+		return a == b
+	case "!=":
+		return a != b
+	case "=~":
+		return re.MatchString(a)
+	default:
+		return !re.MatchString(a)
+	}
+}
+
+func boolToInt(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+type filterTokenKind int
+
+const (
+	filterOperator filterTokenKind = iota
+	filterWord
+	filterString
+)
+
+type filterToken struct {
+	kind filterTokenKind
+	text string
+}
+
+var filterOperators = []string{"&&", "||", "==", "!=", "<=", ">=", "=~", "!~", "<", ">", "!", "(", ")"}
+
+func lexFilter(s string) ([]filterToken, error) {
+	var tokens []filterToken
+
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+
+		if unicode.IsSpace(r) {
+			i += size
+			continue
+		}
+
+		if r == '"' {
+			end := i + 1
+			for end < len(s) && s[end] != '"' {
+				if s[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(s) {
+				return nil, fmt.Errorf("unterminated string in filter")
+			}
+			str, err := strconv.Unquote(s[i : end+1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid string %s in filter", s[i:end+1])
+			}
+			tokens = append(tokens, filterToken{kind: filterString, text: str})
+			i = end + 1
+			continue
+		}
+
+		operator := ""
+		for _, op := range filterOperators {
+			if strings.HasPrefix(s[i:], op) {
+				operator = op
+				break
+			}
+		}
+
+		if operator != "" {
+			tokens = append(tokens, filterToken{kind: filterOperator, text: operator})
+			i += len(operator)
+			continue
+		}
+
+		end := i
+		for end < len(s) {
+			r, size := utf8.DecodeRuneInString(s[end:])
+			if !isFilterWordChar(r) {
+				break
+			}
+			end += size
+		}
+		if end == i {
+			return nil, fmt.Errorf("unexpected %q in filter", r)
+		}
+		tokens = append(tokens, filterToken{kind: filterWord, text: s[i:end]})
+		i = end
+	}
+
+	return tokens, nil
+}
+
+func isFilterWordChar(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '-' || r == '.'
+}
+
+type filterParser struct {
+	tokens []filterToken
+	pos    int
+}
+
+func (p *filterParser) peek(text string) bool {
+	return p.pos < len(p.tokens) && p.tokens[p.pos].kind == filterOperator && p.tokens[p.pos].text == text
+}
+
+func (p *filterParser) next() (filterToken, error) {
+	if p.pos >= len(p.tokens) {
+		return filterToken{}, fmt.Errorf("unexpected end of filter")
+	}
+	p.pos++
+	return p.tokens[p.pos-1], nil
+}
+
+func (p *filterParser) parseOr() (filterExpr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.peek("||") {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orExpr{left, right}
+	}
+
+	return left, nil
+}
+
+func (p *filterParser) parseAnd() (filterExpr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.peek("&&") {
+		p.pos++
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = andExpr{left, right}
+	}
+
+	return left, nil
+}
+
+func (p *filterParser) parseUnary() (filterExpr, error) {
+	if p.peek("!") {
+		p.pos++
+		expr, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notExpr{expr}, nil
+	}
+
+	if p.peek("(") {
+		p.pos++
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.peek(")") {
+			return nil, fmt.Errorf("missing ) in filter")
+		}
+		p.pos++
+		return expr, nil
+	}
+
+	return p.parseComparison()
+}
+
+func (p *filterParser) parseComparison() (filterExpr, error) {
+	field, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+
+	op, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+
+	if field.kind != filterWord {
+		return nil, fmt.Errorf("expected a field in filter, got %q", field.text)
+	}
+
+	if op.kind != filterOperator {
+		return nil, fmt.Errorf("expected an operator after %s in filter, got %q", field.text, op.text)
+	}
+
+	if value.kind == filterOperator {
+		return nil, fmt.Errorf("expected a value after %s %s in filter, got %q", field.text, op.text, value.text)
+	}
+
+	cmp := comparison{field: strings.ToLower(field.text), op: op.text}
+
+	switch cmp.field {
+	case "score", "depth", "age", "stickied":
+		if op.text == "=~" || op.text == "!~" {
+			return nil, fmt.Errorf("%s cannot be matched against a regular expression", cmp.field)
+		}
+		if cmp.num, err = parseFilterNumber(cmp.field, value.text); err != nil {
+			return nil, err
+		}
+	case "author", "distinguished":
+		switch op.text {
+		case "==", "!=":
+			cmp.str = value.text
+			if cmp.field == "author" {
+				cmp.str = strings.ToLower(cmp.str)
+			}
+		case "=~", "!~":
+			pattern := value.text
+			if cmp.field == "author" {
+				pattern = "(?i)" + strings.TrimPrefix(pattern, "(?i)")
+			}
+			if cmp.re, err = regexp.Compile(pattern); err != nil {
+				return nil, fmt.Errorf("invalid regular expression %q in filter: %w", value.text, err)
+			}
+		default:
+			return nil, fmt.Errorf("%s cannot be compared with %s", cmp.field, op.text)
+		}
+	default:
+		return nil, fmt.Errorf("unknown field %q in filter", field.text)
+	}
+
+	return cmp, nil
+}
+
+func parseFilterNumber(field, value string) (int64, error) {
+	switch field {
+	case "age":
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q for age in filter", value)
+		}
+		return int64(d), nil
+	case "stickied":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return 0, fmt.Errorf("invalid boolean %q for stickied in filter", value)
+		}
+		return boolToInt(b), nil
+	default:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid number %q for %s in filter", value, field)
+		}
+		return n, nil
+	}
+}
+
+// derivedWords returns the cloud of the comments of wordDoc's thread selected
+// by opts. It is counted by the crawl requested with opts, or else from the
+// stored comments, and cached under the fingerprint of opts until the thread
+// is crawled again.
+func (svc *service) derivedWords(c context.Context, wordDoc *WordDocument, opts cloudOptions) (*CloudDocument, error) {
+	scid := wordDoc.SubredditAndCommentId
+
+	cached, err := svc.Repository.GetCloud(c, scid, opts.fingerprint())
+
+	if err != nil {
+		return nil, fmt.Errorf("could not get derived words of %s: %w", scid, err)
+	}
+
+	crawling := svc.crawling.Has(scid)
+
+	if cached != nil && cached.LastCrawled == wordDoc.LastCrawled && cached.LastUpdated == wordDoc.LastUpdated && !crawling {
+		return cached, nil
+	}
+
+	comments, err := svc.Repository.GetComments(c, scid)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not get comments of %s: %w", scid, err)
+	}
+
+	env := threadFilterEnv(wordDoc, comments)
+	if opts, err = svc.withPhrases(c, opts, wordDoc, comments); err != nil {
+		return nil, err
+	}
+	words := make(map[string]int)
+	facets := make(Facets)
+	for i := range comments {
+		if !opts.counts(&comments[i], env) {
+			continue
+		}
+		for word, count := range opts.words(&comments[i]) {
+			words[word] += count
+		}
+		facets.addAll(comments[i].Facets, 1)
+	}
+
+	cloud := &CloudDocument{
+		Scid:        scid,
+		Fingerprint: opts.fingerprint(),
+		Words:       words,
+		Facets:      facets,
+		LastCrawled: wordDoc.LastCrawled,
+		LastUpdated: wordDoc.LastUpdated,
+	}
+
+	// A cloud of a partial crawl is not cached, the next request rebuilds it.
+	if !crawling {
+		if err := svc.Repository.SaveCloud(c, cloud); err != nil {
+			zap.S().Errorf("Could not cache derived words of %s: %w", scid, err)
+		}
+	}
+
+	return cloud, nil
+}
+
+// crawlCloud counts the cloud selected by the options of the request that
+// started a crawl as the crawl goes over the comments of the thread, so that
+// it is ready when the crawl completes.
+type crawlCloud struct {
+	opts  cloudOptions
+	env   filterEnv
+	words cmap.ConcurrentMap[string, int]
+	// facets are keyed by facetDelta.
+	facets cmap.ConcurrentMap[string, int]
+}
+
+// newCrawlCloud returns the crawlCloud of opts, nil if the cloud is the
+// thread's WordDocument or cannot be counted comment by comment: phrases are
+// found in all the comments of the thread at once.
+func newCrawlCloud(opts cloudOptions, env filterEnv) *crawlCloud {
+	if opts.isDefault() || opts.phrases {
+		return nil
+	}
+	return &crawlCloud{opts: opts, env: env, words: cmap.New[int](), facets: cmap.New[int]()}
+}
+
+// add adds (sign 1) or removes (sign -1) a comment seen by the crawl to the
+// cloud if the options select it.
+func (cc *crawlCloud) add(comment *CommentDocument, sign int) {
+	if cc == nil || !cc.opts.counts(comment, cc.env) {
+		return
+	}
+	for word, count := range cc.opts.words(comment) {
+		cc.words.Upsert(word, sign*count, sumInt)
+	}
+	for kind, entities := range comment.Facets {
+		for entity, count := range entities {
+			cc.facets.Upsert(facetDelta(kind, entity), sign*count, sumInt)
+		}
+	}
+}
+
+// document returns the cloud of a crawl of scid marked crawled at crawledAt,
+// whose words were last updated at lastUpdated.
+func (cc *crawlCloud) document(scid string, crawledAt time.Time, lastUpdated primitive.DateTime) *CloudDocument {
+	words := make(map[string]int)
+	for word, count := range cc.words.Items() {
+		if count > 0 {
+			words[word] = count
+		}
+	}
+
+	return &CloudDocument{
+		Scid:        scid,
+		Fingerprint: cc.opts.fingerprint(),
+		Words:       words,
+		Facets:      facetsOfDeltas(cc.facets.Items()),
+		LastCrawled: primitive.NewDateTimeFromTime(crawledAt),
+		LastUpdated: lastUpdated,
+	}
+}
+
+// threadFilterEnv returns the filter environment of the thread of wordDoc.
+// Threads crawled before their creation was recorded start at their earliest comment.
+func threadFilterEnv(wordDoc *WordDocument, comments []CommentDocument) filterEnv {
+	if wordDoc.ThreadCreated == 0 {
+		return filterEnv{threadCreated: earliestComment(comments)}
+	}
+	return filterEnv{threadCreated: wordDoc.ThreadCreated.Time()}
+}
+
+func earliestComment(comments []CommentDocument) time.Time {
+	var earliest primitive.DateTime
+	for _, comment := range comments {
+		if earliest == 0 || comment.CreatedUTC < earliest {
+			earliest = comment.CreatedUTC
+		}
+	}
+	return earliest.Time()
+}
+
+// storedWords returns the cloud of the crawled thread of wordDoc selected by
+// opts, from what is stored, without crawling the thread.
+func (svc *service) storedWords(c context.Context, wordDoc *WordDocument, opts cloudOptions) (*WordDocument, error) {
+	if opts.isDefault() {
+		return wordDoc, nil
+	}
+
+	cloud, err := svc.derivedWords(c, wordDoc, opts)
+
+	if err != nil {
+		return nil, err
+	}
+
+	derived := *wordDoc
+	derived.Words = cloud.Words
+	derived.Facets = cloud.Facets
+	return &derived, nil
+}
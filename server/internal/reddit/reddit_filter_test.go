@@ -0,0 +1,217 @@
+package reddit
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestLexFilter(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []filterToken
+		wantErr bool
+	}{
+		{
+			name:  "operators without spaces",
+			input: "score>=10&&!(depth==0)",
+			want: []filterToken{
+				{filterWord, "score"}, {filterOperator, ">="}, {filterWord, "10"}, {filterOperator, "&&"},
+				{filterOperator, "!"}, {filterOperator, "("}, {filterWord, "depth"}, {filterOperator, "=="},
+				{filterWord, "0"}, {filterOperator, ")"},
+			},
+		},
+		{
+			name:  "non-ascii word",
+			input: "author == Zoë",
+			want:  []filterToken{{filterWord, "author"}, {filterOperator, "=="}, {filterWord, "Zoë"}},
+		},
+		{
+			name:  "non-ascii space",
+			input: "score >　1",
+			want:  []filterToken{{filterWord, "score"}, {filterOperator, ">"}, {filterWord, "1"}},
+		},
+		{
+			name:  "string with escaped quote",
+			input: `author == "a\"b"`,
+			want:  []filterToken{{filterWord, "author"}, {filterOperator, "=="}, {filterString, `a"b`}},
+		},
+		{name: "unterminated string", input: `author == "abc`, wantErr: true},
+		{name: "unexpected character", input: "score > 1 § 2", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := lexFilter(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("lexFilter() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("lexFilter() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseFilter(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{name: "empty", input: "  ", want: ""},
+		{name: "and", input: "score >= 10 && depth == 0", want: "(score >= 10 && depth == 0)"},
+		{name: "and binds tighter than or", input: "depth == 0 || score > 1 && stickied == true", want: "(depth == 0 || (score > 1 && stickied == true))"},
+		{name: "parentheses", input: "(depth == 0 || score > 1) && stickied == false", want: "((depth == 0 || score > 1) && stickied == false)"},
+		{name: "authors are lower-cased", input: `author != "AutoModerator" && !(author =~ "bot$")`, want: `(author != "automoderator" && !author =~ "(?i)bot$")`},
+		{name: "durations", input: "age <= 90m", want: "age <= 1h30m0s"},
+		{name: "distinguished", input: "distinguished == moderator", want: `distinguished == "moderator"`},
+		{name: "unknown field", input: "karma > 1", wantErr: true},
+		{name: "missing value", input: "score >", wantErr: true},
+		{name: "missing parenthesis", input: "(score > 1", wantErr: true},
+		{name: "trailing tokens", input: "score > 1 )", wantErr: true},
+		{name: "regular expression on a number", input: `score =~ "1"`, wantErr: true},
+		{name: "ordering strings", input: `author < "a"`, wantErr: true},
+		{name: "invalid number", input: "score > ten", wantErr: true},
+		{name: "invalid duration", input: "age < soon", wantErr: true},
+		{name: "invalid regular expression", input: `author =~ "("`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseFilter(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseFilter() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got.String() != tt.want {
+				t.Errorf("parseFilter().String() = %q, want %q", got.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestCommentFilterMatch(t *testing.T) {
+	threadCreated := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	env := filterEnv{threadCreated: threadCreated}
+	comment := &CommentDocument{
+		Author:     "AutoModerator",
+		Score:      12,
+		Depth:      1,
+		CreatedUTC: primitive.NewDateTimeFromTime(threadCreated.Add(3 * time.Hour)),
+		Stickied:   true,
+	}
+
+	tests := []struct {
+		filter string
+		want   bool
+	}{
+		{"", true},
+		{"score >= 10", true},
+		{"score >= 10 && depth == 0", false},
+		{"score >= 10 && !(depth == 0)", true},
+		{`author == "automoderator"`, true},
+		{`author !~ "moderator$"`, false},
+		{"age <= 2h || stickied == true", true},
+		{"age <= 2h", false},
+		{`distinguished == ""`, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.filter, func(t *testing.T) {
+			filter, err := parseFilter(tt.filter)
+			if err != nil {
+				t.Fatalf("parseFilter() error = %v", err)
+			}
+			if got := filter.match(comment, env); got != tt.want {
+				t.Errorf("match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCrawlCloud(t *testing.T) {
+	filter, err := parseFilter("score >= 10")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cc := newCrawlCloud(cloudOptions{}, filterEnv{}); cc != nil {
+		t.Errorf("newCrawlCloud() of the default options = %v, want nil", cc)
+	}
+
+	cc := newCrawlCloud(cloudOptions{filter: filter}, filterEnv{})
+	comments := []CommentDocument{
+		{CommentId: "a", Score: 20, Words: map[string]int{"go": 2, "rust": 1}},
+		{CommentId: "b", Score: 5, Words: map[string]int{"go": 1}},
+		{CommentId: "c", Score: 30, Words: map[string]int{"go": 1}, Flag: flagDuplicate},
+		{CommentId: "d", Score: 10, Words: map[string]int{"zig": 1}},
+	}
+	for i := range comments {
+		cc.add(&comments[i], 1)
+	}
+
+	crawledAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	updatedAt := primitive.NewDateTimeFromTime(crawledAt.Add(time.Hour))
+	cloud := cc.document("r/test/comments/t", crawledAt, updatedAt)
+
+	if want := map[string]int{"go": 2, "rust": 1, "zig": 1}; !reflect.DeepEqual(cloud.Words, want) {
+		t.Errorf("words = %v, want %v", cloud.Words, want)
+	}
+	if cloud.Fingerprint != (cloudOptions{filter: filter}).fingerprint() {
+		t.Errorf("fingerprint = %s, want the fingerprint of the options", cloud.Fingerprint)
+	}
+	if cloud.LastCrawled != primitive.NewDateTimeFromTime(crawledAt) {
+		t.Errorf("last crawled = %v, want %v", cloud.LastCrawled.Time(), crawledAt)
+	}
+	if cloud.LastUpdated != updatedAt {
+		t.Errorf("last updated = %v, want %v", cloud.LastUpdated.Time(), updatedAt.Time())
+	}
+}
+
+func TestDerivedWordsCache(t *testing.T) {
+	scid := "r/test/comments/t"
+	filter, err := parseFilter("score >= 10")
+	if err != nil {
+		t.Fatalf("parseFilter() error = %v", err)
+	}
+	opts := cloudOptions{filter: filter}
+
+	repo := newFakeRepository()
+	repo.addWords(WordDocument{SubredditAndCommentId: scid, Words: map[string]int{"go": 1, "rust": 1}})
+	repo.addComments(
+		CommentDocument{SubredditAndCommentId: scid, CommentId: "a", Score: 20, Words: map[string]int{"go": 1}},
+		CommentDocument{SubredditAndCommentId: scid, CommentId: "b", Score: 5, Words: map[string]int{"rust": 1}},
+	)
+	svc := newTestService(repo, nil)
+	ctx := context.Background()
+
+	wordDoc, _ := repo.GetWordsFromLink(ctx, scid)
+	cloud, err := svc.derivedWords(ctx, wordDoc, opts)
+	if err != nil {
+		t.Fatalf("derivedWords() error = %v", err)
+	}
+	if want := map[string]int{"go": 1}; !reflect.DeepEqual(cloud.Words, want) {
+		t.Errorf("words = %v, want %v", cloud.Words, want)
+	}
+
+	// A reprocess rewrites the comments and the words, it does not crawl the thread.
+	repo.addComments(CommentDocument{SubredditAndCommentId: scid, CommentId: "a", Score: 20, Words: map[string]int{"golang": 1}})
+
+	if cloud, _ = svc.derivedWords(ctx, wordDoc, opts); !reflect.DeepEqual(cloud.Words, map[string]int{"go": 1}) {
+		t.Errorf("words of an unchanged thread = %v, want the cached cloud", cloud.Words)
+	}
+
+	if err := repo.SetWords(ctx, map[string]int{"golang": 1, "rust": 1}, nil, nil, nil, scid); err != nil {
+		t.Fatalf("SetWords() error = %v", err)
+	}
+	wordDoc, _ = repo.GetWordsFromLink(ctx, scid)
+
+	if cloud, _ = svc.derivedWords(ctx, wordDoc, opts); !reflect.DeepEqual(cloud.Words, map[string]int{"golang": 1}) {
+		t.Errorf("words of a reprocessed thread = %v, want them counted again", cloud.Words)
+	}
+}
@@ -0,0 +1,122 @@
+package reddit
+
+import (
+	"time"
+)
+
+// FreshnessConfig controls how long a cached WordDocument is served before its
+// thread is crawled again. The time-to-live grows with the age of the thread:
+// brand-new threads change every minute, while threads older than a few days
+// rarely change at all.
+type FreshnessConfig struct {
+	// MinTTL is the shortest time a crawl is considered fresh, used for brand-new threads.
+	MinTTL time.Duration `env:"MIN_TTL" envDefault:"1m"`
+	// MaxTTL caps the time-to-live of old threads and is used when the thread age is unknown.
+	MaxTTL time.Duration `env:"MAX_TTL" envDefault:"168h"`
+	// AgeFactor is the fraction of the thread's age (at crawl time) a crawl stays fresh for.
+	AgeFactor float64 `env:"AGE_FACTOR" envDefault:"0.5"`
+	// StaleWhileRevalidate is how long past its TTL a cached cloud may still be
+	// returned while a refresh runs in the background.
+	StaleWhileRevalidate time.Duration `env:"STALE_WHILE_REVALIDATE" envDefault:"24h"`
+	// MaxRefreshes is the most crawls of stale or expired threads run in the
+	// background at once, across requests.
+	MaxRefreshes int `env:"MAX_REFRESHES" envDefault:"8"`
+}
+
+type freshness int
+
+const (
+	// fresh documents are returned as is.
+	fresh freshness = iota
+	// stale documents are returned, and the thread is re-crawled in the background.
+	stale
+	// expired documents must not be returned; the thread is re-crawled.
+	expired
+)
+
+func (f freshness) String() string {
+	switch f {
+	case fresh:
+		return "fresh"
+	case stale:
+		return "stale"
+	default:
+		return "expired"
+	}
+}
+
+// freshnessOptions are the request-level overrides of the freshness policy.
+type freshnessOptions struct {
+	forceRefresh bool
+	maxAge       *time.Duration
+}
+
+func newFreshnessOptions(forceRefresh bool, maxAgeSeconds *int) freshnessOptions {
+	opts := freshnessOptions{forceRefresh: forceRefresh}
+	if maxAgeSeconds != nil {
+		maxAge := time.Duration(*maxAgeSeconds) * time.Second
+		opts.maxAge = &maxAge
+	}
+	return opts
+}
+
+// crawledAt returns when the thread of the document was last crawled.
+func (wordDoc *WordDocument) crawledAt() time.Time {
+	if wordDoc.LastCrawled == 0 {
+		// Documents written before crawls were tracked only have their insertion time.
+		return wordDoc.LastUpdated.Time()
+	}
+	return wordDoc.LastCrawled.Time()
+}
+
+// ttl returns how long a crawl of a thread created at threadCreated, made at
+// crawledAt, is considered fresh.
+func (cfg FreshnessConfig) ttl(threadCreated, crawledAt time.Time) time.Duration {
+	if threadCreated.IsZero() {
+		return cfg.MaxTTL
+	}
+
+	ttl := time.Duration(float64(crawledAt.Sub(threadCreated)) * cfg.AgeFactor)
+
+	if ttl < cfg.MinTTL {
+		return cfg.MinTTL
+	}
+
+	if ttl > cfg.MaxTTL {
+		return cfg.MaxTTL
+	}
+
+	return ttl
+}
+
+// evaluate decides whether wordDoc can be served at now.
+func (cfg FreshnessConfig) evaluate(wordDoc *WordDocument, opts freshnessOptions, now time.Time) freshness {
+	if opts.forceRefresh {
+		return expired
+	}
+
+	crawledAt := wordDoc.crawledAt()
+
+	var threadCreated time.Time
+	if wordDoc.ThreadCreated != 0 {
+		threadCreated = wordDoc.ThreadCreated.Time()
+	}
+
+	age := now.Sub(crawledAt)
+
+	if opts.maxAge != nil && age > *opts.maxAge {
+		return expired
+	}
+
+	ttl := cfg.ttl(threadCreated, crawledAt)
+
+	if age <= ttl {
+		return fresh
+	}
+
+	if age <= ttl+cfg.StaleWhileRevalidate {
+		return stale
+	}
+
+	return expired
+}
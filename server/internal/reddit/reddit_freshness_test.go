@@ -0,0 +1,80 @@
+package reddit
+
+import (
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var testFreshness = FreshnessConfig{
+	MinTTL:               time.Minute,
+	MaxTTL:               168 * time.Hour,
+	AgeFactor:            0.5,
+	StaleWhileRevalidate: 24 * time.Hour,
+}
+
+func TestFreshnessTTL(t *testing.T) {
+	crawledAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name          string
+		threadCreated time.Time
+		want          time.Duration
+	}{
+		{"unknown age", time.Time{}, 168 * time.Hour},
+		{"brand new", crawledAt.Add(-30 * time.Second), time.Minute},
+		{"an hour old", crawledAt.Add(-time.Hour), 30 * time.Minute},
+		{"a day old", crawledAt.Add(-24 * time.Hour), 12 * time.Hour},
+		{"a year old", crawledAt.Add(-365 * 24 * time.Hour), 168 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := testFreshness.ttl(tt.threadCreated, crawledAt); got != tt.want {
+				t.Errorf("ttl() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFreshnessEvaluate(t *testing.T) {
+	crawledAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	// The thread was a day old when crawled, its crawls are fresh for 12 hours.
+	wordDoc := &WordDocument{
+		LastCrawled:   primitive.NewDateTimeFromTime(crawledAt),
+		ThreadCreated: primitive.NewDateTimeFromTime(crawledAt.Add(-24 * time.Hour)),
+	}
+	maxAge := 60
+
+	tests := []struct {
+		name string
+		doc  *WordDocument
+		opts freshnessOptions
+		age  time.Duration
+		want freshness
+	}{
+		{"within ttl", wordDoc, freshnessOptions{}, 11 * time.Hour, fresh},
+		{"at ttl", wordDoc, freshnessOptions{}, 12 * time.Hour, fresh},
+		{"past ttl", wordDoc, freshnessOptions{}, 13 * time.Hour, stale},
+		{"past stale window", wordDoc, freshnessOptions{}, 37 * time.Hour, expired},
+		{"forced refresh", wordDoc, newFreshnessOptions(true, nil), 0, expired},
+		{"older than max age", wordDoc, newFreshnessOptions(false, &maxAge), 2 * time.Minute, expired},
+		{"younger than max age", wordDoc, newFreshnessOptions(false, &maxAge), 30 * time.Second, fresh},
+		{
+			"never crawled, aged from insertion",
+			&WordDocument{LastUpdated: primitive.NewDateTimeFromTime(crawledAt)},
+			freshnessOptions{},
+			169 * time.Hour,
+			stale,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := testFreshness.evaluate(tt.doc, tt.opts, crawledAt.Add(tt.age)); got != tt.want {
+				t.Errorf("evaluate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,216 @@
+package reddit
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"redditwordcloud/pkg/cooccurrence"
+	"redditwordcloud/pkg/language"
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// Units words co-occur in.
+const (
+	graphLevelComment  = "comment"
+	graphLevelSentence = "sentence"
+)
+
+// Formats of a co-occurrence graph besides JSON.
+const (
+	graphFormatGraphML = "graphml"
+	graphFormatGEXF    = "gexf"
+)
+
+var sentenceBoundary = regexp.MustCompile(`[.!?]+(?:\s+|$)|\n+`)
+
+func (q GraphQuery) options() cooccurrence.Options {
+	return cooccurrence.Options{Top: q.Top, MinCount: q.MinCount, MaxEdges: q.MaxEdges}
+}
+
+func (q GraphQuery) level() string {
+	if q.Level == "" {
+		return graphLevelComment
+	}
+	return q.Level
+}
+
+// GetRedditThreadGraph builds the co-occurrence graph of the words of the
+// stored comments of a thread.
+func (svc *service) GetRedditThreadGraph(c context.Context, req *GetRedditThreadGraphReq) (*GetRedditGraphRes, error) {
+	wordDoc, err := svc.Repository.GetWordsFromLink(c, req.Scid)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not get words of %s: %w", req.Scid, err)
+	}
+
+	if wordDoc == nil {
+		return nil, fmt.Errorf("could not get graph of %s: %w", req.Scid, ErrThreadNotFound)
+	}
+
+	units, err := svc.commentUnits(c, wordDoc, req.ThreadCloudQuery, req.level())
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &GetRedditGraphRes{
+		Link:    req.Scid,
+		Level:   req.level(),
+		Graph:   cooccurrence.Build(unitWords(units), req.options()),
+		Success: true,
+	}, nil
+}
+
+// GetRedditSubredditGraph builds the co-occurrence graph of the words of the
+// comments of the posts of a subreddit listing. Like subreddit clouds, posts
+// that were not crawled yet are crawled in the background and left out.
+func (svc *service) GetRedditSubredditGraph(c context.Context, req *GetRedditSubredditGraphReq) (*GetRedditGraphRes, error) {
+	subreddit := subredditPrefix(req.Subreddit)
+	_, _, posts, err := svc.subredditListing(subreddit, req.Listing, req.Time, req.Limit)
+
+	if err != nil {
+		return nil, err
+	}
+
+	_, _, postWords := svc.aggregatePosts(c, subreddit, posts, newFreshnessOptions(false, nil))
+
+	var units []commentUnit
+	refreshing := false
+	for _, pw := range postWords {
+		refreshing = refreshing || pw.Refreshing
+		if pw.Words == nil {
+			continue
+		}
+
+		wordDoc, err := svc.Repository.GetWordsFromLink(c, pw.Link)
+
+		if err != nil || wordDoc == nil {
+			zap.S().Errorf("Could not get words of post %s: %w", pw.Link, err)
+			continue
+		}
+
+		postUnits, err := svc.commentUnits(c, wordDoc, req.ThreadCloudQuery, req.level())
+
+		if err != nil {
+			return nil, err
+		}
+
+		units = append(units, postUnits...)
+	}
+
+	return &GetRedditGraphRes{
+		Subreddit:  subreddit,
+		Level:      req.level(),
+		Graph:      cooccurrence.Build(unitWords(units), req.options()),
+		Success:    true,
+		Refreshing: refreshing,
+	}, nil
+}
+
+// commentUnit is the words of a comment, or of one of its sentences.
+type commentUnit struct {
+	commentId string
+	words     map[string]int
+}
+
+func unitWords(units []commentUnit) []map[string]int {
+	words := make([]map[string]int, len(units))
+	for i, unit := range units {
+		words[i] = unit.words
+	}
+	return words
+}
+
+// commentUnits returns the words of every comment, or of every sentence of
+// every comment, of the thread of wordDoc selected by query. Stop words are
+// always left out, they co-occur with everything and make up no topic.
+func (svc *service) commentUnits(c context.Context, wordDoc *WordDocument, query ThreadCloudQuery, level string) ([]commentUnit, error) {
+	scid := wordDoc.SubredditAndCommentId
+	filter, err := parseFilter(query.Filter)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not parse filter %q: %w", query.Filter, err)
+	}
+
+	comments, err := svc.Repository.GetComments(c, scid)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not get comments of %s: %w", scid, err)
+	}
+
+	opts := query.cloudOptions(filter)
+	opts.removeStopWords = true
+	env := threadFilterEnv(wordDoc, comments)
+	if opts, err = svc.withPhrases(c, opts, wordDoc, comments); err != nil {
+		return nil, err
+	}
+	// Comments too short to be identified are likely in the language of the thread.
+	fallback := dominantLanguage(wordDoc.Languages)
+
+	var units []commentUnit
+	for i := range comments {
+		comment := &comments[i]
+		if !opts.counts(comment, env) || len(comment.Words) == 0 {
+			continue
+		}
+
+		lang := commentLanguage(comment)
+		if lang == language.Undetermined {
+			lang = fallback
+		}
+
+		// Comments recorded before their bodies were stored are a single unit.
+		if level == graphLevelComment || comment.Body == "" {
+			if words := opts.normalize(lang, opts.terms(comment)); len(words) != 0 {
+				units = append(units, commentUnit{comment.CommentId, words})
+			}
+			continue
+		}
+
+		for _, sentence := range commentSentences(comment.Body) {
+			if words := opts.normalize(lang, opts.sentenceTerms(sentence)); len(words) != 0 {
+				units = append(units, commentUnit{comment.CommentId, words})
+			}
+		}
+	}
+
+	return units, nil
+}
+
+// dominantLanguage returns the language most comments were detected in.
+func dominantLanguage(languages map[string]int) string {
+	dominant, most := language.Undetermined, 0
+	for lang, count := range languages {
+		if lang != language.Undetermined && (count > most || count == most && lang < dominant) {
+			dominant, most = lang, count
+		}
+	}
+	return dominant
+}
+
+// writeGraph writes the graph of res in format, JSON by default.
+func writeGraph(c *gin.Context, format string, res *GetRedditGraphRes) {
+	var write func(g *cooccurrence.Graph) error
+
+	switch format {
+	case graphFormatGraphML:
+		c.Header("Content-Type", "application/graphml+xml")
+		write = func(g *cooccurrence.Graph) error { return g.WriteGraphML(c.Writer) }
+	case graphFormatGEXF:
+		c.Header("Content-Type", "application/gexf+xml")
+		write = func(g *cooccurrence.Graph) error { return g.WriteGEXF(c.Writer) }
+	default:
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"graph.%s\"", format))
+	c.Status(http.StatusOK)
+
+	if err := write(res.Graph); err != nil {
+		zap.S().Errorf("Could not write %s graph: %w", format, err)
+	}
+}
@@ -0,0 +1,65 @@
+package reddit
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCommentSentences(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want [][]string
+	}{
+		{
+			name: "sentences",
+			body: "Go is great. Rust is fast! Is Zig next?",
+			want: [][]string{{"Go", "is", "great"}, {"Rust", "is", "fast"}, {"Is", "Zig", "next"}},
+		},
+		{
+			name: "lines",
+			body: "first line\n\nsecond line",
+			want: [][]string{{"first", "line"}, {"second", "line"}},
+		},
+		{
+			name: "links do not end sentences",
+			body: "Read https://go.dev/doc. It helps",
+			want: [][]string{{"Read"}, {"It", "helps"}},
+		},
+		{
+			name: "escaped entities",
+			body: "Tom &amp; Jerry. Done",
+			want: [][]string{{"Tom", "Jerry"}, {"Done"}},
+		},
+		{
+			name: "decimals do not end sentences",
+			body: "It costs 2.5 dollars",
+			want: [][]string{{"It", "costs", "2.5", "dollars"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := commentSentences(tt.body); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("commentSentences() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSentenceTerms(t *testing.T) {
+	body := "Go is great. GO is fast & go is fun"
+
+	var opts cloudOptions
+	total := make(map[string]int)
+	for _, sentence := range commentSentences(body) {
+		for word, count := range opts.sentenceTerms(sentence) {
+			total[word] += count
+		}
+	}
+
+	// The sentences of a comment count the words of the comment.
+	if want := countWords(tokenize(body)); !reflect.DeepEqual(total, want) {
+		t.Errorf("words of the sentences = %v, want %v", total, want)
+	}
+}
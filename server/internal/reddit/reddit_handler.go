@@ -1,6 +1,7 @@
 package reddit
 
 import (
+	"errors"
 	"net/http"
 	"net/url"
 	"redditwordcloud/pkg/retryhttp"
@@ -51,12 +52,30 @@ func (h *Handler) GetRedditThreadWordsByLinkHandler(c *gin.Context) {
 	}
 	segment.End()
 
+	format, err := exportFormat(c)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.withStats = format != formatJSON
+
 	segment = txn.StartSegment("GetRedditThreadWordsByLink Service")
 	res, err := h.Service.GetRedditThreadWordsByLink(c, &req, txn)
 	segment.End()
 
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	if format != formatJSON && res.LastCrawled == nil {
+		writeExportNotReady(c, res.Link)
+		return
+	}
+
+	if format != formatJSON {
+		writeExport(c, format, wordRows(res.Words, res.wordStats))
 		return
 	}
 
@@ -66,3 +85,391 @@ func (h *Handler) GetRedditThreadWordsByLinkHandler(c *gin.Context) {
 	// Write the JSON data to the response body
 	c.JSON(http.StatusOK, res)
 }
+
+// errorStatus returns the HTTP status code of an error returned by the Service.
+func errorStatus(err error) int {
+	if errors.Is(err, ErrThreadNotFound) || errors.Is(err, ErrCommentNotFound) || errors.Is(err, ErrWatchNotFound) {
+		return http.StatusNotFound
+	}
+	if errors.Is(err, ErrInvalidCursor) {
+		return http.StatusBadRequest
+	}
+	if errors.Is(err, ErrTooManyWatches) {
+		return http.StatusTooManyRequests
+	}
+	return http.StatusInternalServerError
+}
+
+func (h *Handler) GetRedditSubredditWordsHandler(c *gin.Context) {
+	var req GetRedditSubredditWordsReq
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	format, err := exportFormat(c)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	req.withStats = format != formatJSON
+
+	res, err := h.Service.GetRedditSubredditWords(c, &req)
+
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	if format != formatJSON {
+		writeExport(c, format, wordRows(res.Words, postWordStats(res.Posts)))
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *Handler) GetRedditUserWordsHandler(c *gin.Context) {
+	var req GetRedditUserWordsReq
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	format, err := exportFormat(c)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.withStats = format != formatJSON
+
+	res, err := h.Service.GetRedditUserWords(c, &req)
+
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	if format != formatJSON && res.LastCrawled == nil {
+		writeExportNotReady(c, userKeyPrefix+res.Username)
+		return
+	}
+
+	if format != formatJSON {
+		writeExport(c, format, wordRows(res.Words, res.wordStats))
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *Handler) GetRedditSearchWordsHandler(c *gin.Context) {
+	var req GetRedditSearchWordsReq
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	format, err := exportFormat(c)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	req.withStats = format != formatJSON
+
+	res, err := h.Service.GetRedditSearchWords(c, &req)
+
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	if format != formatJSON {
+		writeExport(c, format, wordRows(res.Words, postWordStats(res.Posts)))
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *Handler) GetRedditThreadWordContextsHandler(c *gin.Context) {
+	var req GetRedditThreadWordContextsReq
+
+	if err := c.ShouldBindUri(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	res, err := h.Service.GetRedditThreadWordContexts(c, &req)
+
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *Handler) ReprocessRedditThreadHandler(c *gin.Context) {
+	var req ReprocessRedditThreadReq
+
+	if err := c.ShouldBindUri(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	res, err := h.Service.ReprocessRedditThread(c, &req)
+
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *Handler) GetRedditThreadCloudSVGHandler(c *gin.Context) {
+	var req GetRedditThreadCloudReq
+
+	if err := c.ShouldBindUri(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	svg, err := h.Service.GetRedditThreadCloudSVG(c, &req)
+
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Data(http.StatusOK, "image/svg+xml", svg)
+}
+
+func (h *Handler) GetRedditThreadCloudPNGHandler(c *gin.Context) {
+	var req GetRedditThreadCloudReq
+
+	if err := c.ShouldBindUri(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	png, err := h.Service.GetRedditThreadCloudPNG(c, &req)
+
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Data(http.StatusOK, "image/png", png)
+}
+
+func (h *Handler) GetRedditThreadGraphHandler(c *gin.Context) {
+	var req GetRedditThreadGraphReq
+
+	if err := c.ShouldBindUri(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	res, err := h.Service.GetRedditThreadGraph(c, &req)
+
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	writeGraph(c, req.Format, res)
+}
+
+func (h *Handler) GetRedditSubredditGraphHandler(c *gin.Context) {
+	var req GetRedditSubredditGraphReq
+
+	if err := c.ShouldBindUri(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	res, err := h.Service.GetRedditSubredditGraph(c, &req)
+
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	writeGraph(c, req.Format, res)
+}
+
+func (h *Handler) GetRedditThreadTopicsHandler(c *gin.Context) {
+	var req GetRedditThreadTopicsReq
+
+	if err := c.ShouldBindUri(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// The context of the request, unlike c, is done once the client goes away,
+	// which stops fitting the model.
+	res, err := h.Service.GetRedditThreadTopics(c.Request.Context(), &req)
+
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *Handler) GetRedditSubthreadWordsHandler(c *gin.Context) {
+	var req GetRedditSubthreadWordsReq
+
+	if err := c.ShouldBindUri(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	res, err := h.Service.GetRedditSubthreadWords(c, &req)
+
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *Handler) GetRedditThreadBranchesHandler(c *gin.Context) {
+	var req GetRedditThreadBranchesReq
+
+	if err := c.ShouldBindUri(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	res, err := h.Service.GetRedditThreadBranches(c, &req)
+
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *Handler) GetRedditThreadTimeseriesHandler(c *gin.Context) {
+	var req GetRedditThreadTimeseriesReq
+
+	if err := c.ShouldBindUri(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	res, err := h.Service.GetRedditThreadTimeseries(c, &req)
+
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *Handler) WatchRedditThreadHandler(c *gin.Context) {
+	var req WatchRedditThreadReq
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	res, err := h.Service.WatchRedditThread(c, &req)
+
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *Handler) UnwatchRedditThreadHandler(c *gin.Context) {
+	var req UnwatchRedditThreadReq
+
+	if err := c.ShouldBindUri(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	res, err := h.Service.UnwatchRedditThread(c, &req)
+
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *Handler) GetRedditWatchesHandler(c *gin.Context) {
+	res, err := h.Service.GetRedditWatches(c)
+
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
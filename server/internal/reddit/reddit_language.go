@@ -0,0 +1,92 @@
+package reddit
+
+import (
+	"html"
+	"redditwordcloud/pkg/language"
+
+	"github.com/go-playground/validator/v10"
+)
+
+func ValidateLanguage(fl validator.FieldLevel) bool {
+	lang := fl.Field().String()
+	if lang == language.Undetermined {
+		return true
+	}
+	for _, l := range language.Languages {
+		if lang == l {
+			return true
+		}
+	}
+	return false
+}
+
+// detectLanguage returns the language of a comment body.
+func detectLanguage(body string) string {
+	return language.Detect(html.UnescapeString(body))
+}
+
+// commentLanguage returns the language of comment. Comments stored before
+// their language was detected are undetermined until their thread is crawled
+// again or reprocessed, which stores it.
+func commentLanguage(comment *CommentDocument) string {
+	if comment.Language == "" {
+		return language.Undetermined
+	}
+	return comment.Language
+}
+
+// cloudOptions returns the options of a cloud selected by query.
+func (query ThreadCloudQuery) cloudOptions(filter *commentFilter) cloudOptions {
+	return cloudOptions{
+		filter:          filter,
+		includeFlagged:  query.IncludeFlagged,
+		language:        query.Language,
+		removeStopWords: query.RemoveStopWords,
+		stem:            query.Stem,
+		phrases:         query.Phrases,
+	}
+}
+
+// cloudOptions returns the options of the cloud requested by req.
+func (req *GetRedditThreadWordsByLinkReq) cloudOptions(filter *commentFilter) cloudOptions {
+	return ThreadCloudQuery{
+		IncludeFlagged:  req.IncludeFlagged,
+		Language:        req.Language,
+		RemoveStopWords: req.RemoveStopWords,
+		Stem:            req.Stem,
+		Phrases:         req.Phrases,
+	}.cloudOptions(filter)
+}
+
+// words returns the words of comment counted in the cloud, after the phrase,
+// stop-word and stemming stages of opts in the language of the comment.
+func (opts cloudOptions) words(comment *CommentDocument) map[string]int {
+	words := opts.terms(comment)
+	if !opts.removeStopWords && !opts.stem {
+		return words
+	}
+	return opts.normalize(commentLanguage(comment), words)
+}
+
+// postWords returns the words of post counted in the cloud, after the
+// stop-word and stemming stages of opts in the language of the post.
+func (opts cloudOptions) postWords(post *PostDocument) map[string]int {
+	if !opts.removeStopWords && !opts.stem {
+		return post.Words
+	}
+	return opts.normalize(language.Detect(html.UnescapeString(post.Title+"\n"+post.Selftext)), post.Words)
+}
+
+func (opts cloudOptions) normalize(lang string, words map[string]int) map[string]int {
+	normalized := make(map[string]int, len(words))
+	for word, count := range words {
+		if opts.removeStopWords && language.IsStopWord(lang, word) {
+			continue
+		}
+		if opts.stem {
+			word = language.Stem(lang, word)
+		}
+		normalized[word] += count
+	}
+	return normalized
+}
@@ -0,0 +1,63 @@
+package reddit
+
+import (
+	"context"
+	"fmt"
+)
+
+// Reasons a comment without its original body is excluded from the cloud.
+const (
+	flagDeleted = "deleted"
+	flagRemoved = "removed"
+)
+
+// removedByRedditBody replaces the bodies of comments removed by Reddit's own admins.
+const removedByRedditBody = "[ Removed by Reddit ]"
+
+// deletionFlag returns the flag of a comment deleted by its author or removed
+// by moderators, from the placeholder Reddit serves in place of its body, or ""
+// if the comment still has its body.
+func deletionFlag(body string) string {
+	switch body {
+	case deletedBody:
+		return flagDeleted
+	case removedBody, removedByRedditBody:
+		return flagRemoved
+	default:
+		return ""
+	}
+}
+
+// ModerationStats report how many comments of a thread were deleted by their
+// authors or removed by moderators.
+type ModerationStats struct {
+	Comments     int     `json:"comments"`
+	Deleted      int     `json:"deleted"`
+	Removed      int     `json:"removed"`
+	DeletedRatio float64 `json:"deletedRatio"`
+	RemovedRatio float64 `json:"removedRatio"`
+}
+
+// moderationStats returns the moderation stats of the thread of wordDoc, from
+// its exclusion counts and the number of its stored comments.
+func (svc *service) moderationStats(c context.Context, wordDoc *WordDocument) (*ModerationStats, error) {
+	scid := wordDoc.SubredditAndCommentId
+	comments, err := svc.Repository.CountComments(c, scid)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not count comments of %s: %w", scid, err)
+	}
+
+	stats := &ModerationStats{
+		Comments: comments,
+		Deleted:  wordDoc.Excluded[flagDeleted],
+		Removed:  wordDoc.Excluded[flagRemoved],
+	}
+
+	if comments != 0 {
+		stats.DeletedRatio = float64(stats.Deleted) / float64(comments)
+		stats.RemovedRatio = float64(stats.Removed) / float64(comments)
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,74 @@
+package reddit
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestDeletionFlag(t *testing.T) {
+	tests := []struct {
+		body string
+		want string
+	}{
+		{body: deletedBody, want: flagDeleted},
+		{body: removedBody, want: flagRemoved},
+		{body: removedByRedditBody, want: flagRemoved},
+		{body: "I [deleted] my old account", want: ""},
+		{body: "", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.body, func(t *testing.T) {
+			if got := deletionFlag(tt.body); got != tt.want {
+				t.Errorf("deletionFlag(%q) = %q, want %q", tt.body, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestModerationStats(t *testing.T) {
+	scid := "r/test/comments/t"
+
+	tests := []struct {
+		name     string
+		comments int
+		excluded map[string]int
+		want     ModerationStats
+	}{
+		{
+			name:     "deleted and removed",
+			comments: 8,
+			excluded: map[string]int{flagDeleted: 2, flagRemoved: 1, flagDuplicate: 3},
+			want:     ModerationStats{Comments: 8, Deleted: 2, Removed: 1, DeletedRatio: 0.25, RemovedRatio: 0.125},
+		},
+		{
+			name:     "none",
+			comments: 4,
+			want:     ModerationStats{Comments: 4},
+		},
+		{
+			name: "no comments",
+			want: ModerationStats{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newFakeRepository()
+			for i := 0; i < tt.comments; i++ {
+				repo.addComments(CommentDocument{SubredditAndCommentId: scid, CommentId: fmt.Sprint(i)})
+			}
+			svc := newTestService(repo, nil)
+
+			got, err := svc.moderationStats(context.Background(), &WordDocument{SubredditAndCommentId: scid, Excluded: tt.excluded})
+			if err != nil {
+				t.Fatalf("moderationStats() error = %v", err)
+			}
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("moderationStats() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
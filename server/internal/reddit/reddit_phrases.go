@@ -0,0 +1,209 @@
+package reddit
+
+import (
+	"context"
+	"fmt"
+	"redditwordcloud/pkg/language"
+	"redditwordcloud/pkg/phrases"
+	"sort"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// withPhrases returns opts with the phrase model of the thread of wordDoc,
+// when opts counts phrases.
+func (svc *service) withPhrases(c context.Context, opts cloudOptions, wordDoc *WordDocument, comments []CommentDocument) (cloudOptions, error) {
+	if !opts.phrases || opts.model != nil {
+		return opts, nil
+	}
+
+	model, err := svc.phraseModel(c, wordDoc, comments)
+
+	if err != nil {
+		return opts, err
+	}
+
+	opts.model = model
+	return opts, nil
+}
+
+// phraseModel returns the phrase model of the thread of wordDoc, stored by
+// the last crawl of the thread or trained on comments and stored. A model
+// of a partial crawl is not stored, the next request trains it again.
+func (svc *service) phraseModel(c context.Context, wordDoc *WordDocument, comments []CommentDocument) (*phrases.Model, error) {
+	scid := wordDoc.SubredditAndCommentId
+	crawling := svc.crawling.Has(scid)
+
+	if !crawling {
+		stored, err := svc.Repository.GetPhrases(c, scid)
+
+		if err != nil {
+			return nil, fmt.Errorf("could not get phrases of %s: %w", scid, err)
+		}
+
+		if stored != nil && stored.LastCrawled == wordDoc.LastCrawled && stored.LastUpdated == wordDoc.LastUpdated && stored.Tokenizer == tokenizerVersion {
+			return stored.model(), nil
+		}
+	}
+
+	model := trainPhrases(wordDoc, comments)
+
+	if !crawling {
+		if err := svc.Repository.SavePhrases(c, newPhrasesDocument(wordDoc, model)); err != nil {
+			zap.S().Errorf("Could not store phrases of %s: %w", scid, err)
+		}
+	}
+
+	return model, nil
+}
+
+// refreshPhrases trains the phrase model of a thread once a crawl of it
+// completes, if the thread has one or the crawl counts phrases.
+func (svc *service) refreshPhrases(c context.Context, scid string, opts cloudOptions) {
+	if !opts.phrases {
+		stored, err := svc.Repository.GetPhrases(c, scid)
+
+		if err != nil {
+			zap.S().Errorf("Could not get phrases of %s: %w", scid, err)
+			return
+		}
+
+		if stored == nil {
+			return
+		}
+	}
+
+	wordDoc, err := svc.Repository.GetWordsFromLink(c, scid)
+
+	if err != nil {
+		zap.S().Errorf("Could not get words of %s: %w", scid, err)
+		return
+	}
+
+	if wordDoc == nil {
+		return
+	}
+
+	comments, err := svc.Repository.GetComments(c, scid)
+
+	if err != nil {
+		zap.S().Errorf("Could not get comments of %s: %w", scid, err)
+		return
+	}
+
+	if err := svc.Repository.SavePhrases(c, newPhrasesDocument(wordDoc, trainPhrases(wordDoc, comments))); err != nil {
+		zap.S().Errorf("Could not store phrases of %s: %w", scid, err)
+	}
+}
+
+// trainPhrases trains the phrase model of the comments of the thread of
+// wordDoc counted in its words, whatever the options of a query select, so
+// that every query of the thread shares it.
+func trainPhrases(wordDoc *WordDocument, comments []CommentDocument) *phrases.Model {
+	var documents [][][]string
+	for i := range comments {
+		if comments[i].Flag == "" && len(comments[i].Words) != 0 {
+			documents = append(documents, commentSentences(comments[i].Body))
+		}
+	}
+
+	lang := dominantLanguage(wordDoc.Languages)
+	return phrases.Train(documents, phrases.Options{
+		StopWord: func(word string) bool { return language.IsStopWord(lang, word) },
+	})
+}
+
+// newPhrasesDocument returns the document storing the phrase model of the
+// thread of wordDoc.
+func newPhrasesDocument(wordDoc *WordDocument, model *phrases.Model) *PhrasesDocument {
+	snapshot := model.Snapshot()
+
+	doc := &PhrasesDocument{
+		Scid:         wordDoc.SubredditAndCommentId,
+		Casing:       phraseForms(snapshot.Casing),
+		Proper:       snapshot.Proper,
+		Names:        phraseForms(snapshot.Names),
+		Collocations: make([]string, 0, len(snapshot.Collocations)),
+		MaxLength:    snapshot.MaxLength,
+		Tokenizer:    tokenizerVersion,
+		LastCrawled:  wordDoc.LastCrawled,
+		LastUpdated:  wordDoc.LastUpdated,
+	}
+
+	sort.Strings(doc.Proper)
+	for _, pair := range snapshot.Collocations {
+		doc.Collocations = append(doc.Collocations, pair[0]+" "+pair[1])
+	}
+	sort.Strings(doc.Collocations)
+
+	return doc
+}
+
+// model returns the phrase model stored in doc.
+func (doc *PhrasesDocument) model() *phrases.Model {
+	snapshot := phrases.Snapshot{
+		Casing:    phraseFormsMap(doc.Casing),
+		Proper:    doc.Proper,
+		Names:     phraseFormsMap(doc.Names),
+		MaxLength: doc.MaxLength,
+	}
+	for _, collocation := range doc.Collocations {
+		if first, second, ok := strings.Cut(collocation, " "); ok {
+			snapshot.Collocations = append(snapshot.Collocations, [2]string{first, second})
+		}
+	}
+	return phrases.Restore(snapshot)
+}
+
+func phraseForms(forms map[string]string) []PhraseForm {
+	out := make([]PhraseForm, 0, len(forms))
+	for key, form := range forms {
+		out = append(out, PhraseForm{Key: key, Form: form})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Key < out[j].Key })
+	return out
+}
+
+func phraseFormsMap(forms []PhraseForm) map[string]string {
+	out := make(map[string]string, len(forms))
+	for _, form := range forms {
+		out[form.Key] = form.Form
+	}
+	return out
+}
+
+// commentSentences splits a comment body into sentences of words in their
+// original case. Sentences are split once the body is unescaped and its links
+// are removed, so the dots of entities and links end none.
+func commentSentences(body string) [][]string {
+	var sentences [][]string
+	for _, sentence := range sentenceBoundary.Split(plainText(body), -1) {
+		if words := tokenizeText(sentence); len(words) != 0 {
+			sentences = append(sentences, words)
+		}
+	}
+	return sentences
+}
+
+// terms returns the words of comment, or its terms once names and
+// collocations are recognized when opts has a phrase model. Comments recorded
+// before their bodies were stored keep their words.
+func (opts cloudOptions) terms(comment *CommentDocument) map[string]int {
+	if opts.model == nil || comment.Body == "" {
+		return comment.Words
+	}
+	return countWords(opts.model.Apply(commentSentences(comment.Body)))
+}
+
+// sentenceTerms returns the terms of a sentence of commentSentences.
+func (opts cloudOptions) sentenceTerms(sentence []string) map[string]int {
+	if opts.model == nil {
+		words := make(map[string]int, len(sentence))
+		for _, word := range sentence {
+			words[strings.ToLower(word)]++
+		}
+		return words
+	}
+	return countWords(opts.model.Apply([][]string{sentence}))
+}
@@ -0,0 +1,68 @@
+package reddit
+
+import (
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// phraseComments are the comments of a thread naming New York and talking
+// about machine learning, and a comment of a bot left out of its model.
+var phraseComments = []CommentDocument{
+	{CommentId: "a", Body: "I moved to New York last year. I study machine learning.", Words: map[string]int{"new": 1}},
+	{CommentId: "b", Body: "She loves New York pizza &amp; the machine learning course.", Words: map[string]int{"new": 1}},
+	{CommentId: "c", Body: "We visited New York again, for a machine learning talk.", Words: map[string]int{"new": 1}},
+	{CommentId: "d", Body: "Nobody in New York cares about New York.", Words: map[string]int{"new": 2}, Flag: "bot"},
+}
+
+func TestTrainPhrases(t *testing.T) {
+	m := trainPhrases(&WordDocument{Languages: map[string]int{"en": 3}}, phraseComments)
+
+	tests := []struct {
+		body string
+		want []string
+	}{
+		{body: "New York is big", want: []string{"New York", "is", "big"}},
+		{body: "machine learning in New York", want: []string{"machine learning", "in", "New York"}},
+		{body: "the machine", want: []string{"the", "machine"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.body, func(t *testing.T) {
+			if got := m.Apply(commentSentences(tt.body)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Apply() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPhrasesDocument(t *testing.T) {
+	wordDoc := &WordDocument{SubredditAndCommentId: "r/golang/comments/abc", Languages: map[string]int{"en": 3}}
+	m := trainPhrases(wordDoc, phraseComments)
+
+	data, err := bson.Marshal(newPhrasesDocument(wordDoc, m))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var doc PhrasesDocument
+	if err := bson.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+
+	if doc.Scid != wordDoc.SubredditAndCommentId || doc.Tokenizer != tokenizerVersion {
+		t.Errorf("stored scid and tokenizer = %q and %d, want %q and %d", doc.Scid, doc.Tokenizer, wordDoc.SubredditAndCommentId, tokenizerVersion)
+	}
+	if want := []string{"machine learning"}; !reflect.DeepEqual(doc.Collocations, want) {
+		t.Errorf("stored collocations = %q, want %q", doc.Collocations, want)
+	}
+
+	restored := doc.model()
+	for _, comment := range phraseComments {
+		sentences := commentSentences(comment.Body)
+		if got, want := restored.Apply(sentences), m.Apply(sentences); !reflect.DeepEqual(got, want) {
+			t.Errorf("Apply() of the stored model = %q, want %q", got, want)
+		}
+	}
+}
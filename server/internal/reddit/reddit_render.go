@@ -0,0 +1,231 @@
+package reddit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"net/http"
+	"redditwordcloud/pkg/wordcloud"
+	"strings"
+)
+
+// subredditIconMask is the mask shape of the icon of the thread's subreddit,
+// the other shapes are built into the renderer.
+const subredditIconMask = "subreddit"
+
+const colorBySentiment = "sentiment"
+
+// Icons are read up to maxIconBytes and decoded up to maxIconSide pixels on a
+// side, subreddit icons are a few hundred pixels wide.
+const (
+	maxIconBytes = 4 << 20
+	maxIconSide  = 2048
+)
+
+// GetRedditThreadCloudSVG renders the stored cloud of a thread as an SVG image.
+func (svc *service) GetRedditThreadCloudSVG(c context.Context, req *GetRedditThreadCloudReq) ([]byte, error) {
+	return svc.renderThreadCloud(c, req, "svg", wordcloud.RenderSVG)
+}
+
+// GetRedditThreadCloudPNG renders the stored cloud of a thread as a PNG image.
+func (svc *service) GetRedditThreadCloudPNG(c context.Context, req *GetRedditThreadCloudReq) ([]byte, error) {
+	return svc.renderThreadCloud(c, req, "png", wordcloud.RenderPNG)
+}
+
+// renderThreadCloud renders the stored cloud of a thread in format. Renders
+// are cached until the thread is crawled or reprocessed again, and not while
+// it is being crawled.
+func (svc *service) renderThreadCloud(c context.Context, req *GetRedditThreadCloudReq, format string, render func(io.Writer, map[string]int, wordcloud.Options) error) ([]byte, error) {
+	wordDoc, err := svc.Repository.GetWordsFromLink(c, req.Scid)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not get words of %s: %w", req.Scid, err)
+	}
+
+	if wordDoc == nil {
+		return nil, fmt.Errorf("could not get words of %s: %w", req.Scid, ErrThreadNotFound)
+	}
+
+	filter, err := parseFilter(req.Filter)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not parse filter %q: %w", req.Filter, err)
+	}
+
+	cloudOpts := req.ThreadCloudQuery.cloudOptions(filter)
+	key := fmt.Sprintf("%s|%d|%d|%s|%s|%s", req.Scid, wordDoc.LastCrawled, wordDoc.LastUpdated, cloudOpts.fingerprint(), format, req.renderKey())
+	crawling := svc.crawling.Has(req.Scid)
+
+	if rendered, ok := svc.renders.Get(key); ok && !crawling {
+		return rendered, nil
+	}
+
+	if wordDoc, err = svc.storedWords(c, wordDoc, cloudOpts); err != nil {
+		return nil, err
+	}
+
+	opts := req.options()
+
+	switch req.Mask {
+	case "":
+	case subredditIconMask:
+		if opts.Mask, err = svc.subredditIcon(c, strings.Join(strings.SplitN(req.Scid, "/", 3)[:2], "/")); err != nil {
+			return nil, fmt.Errorf("could not get mask of %s: %w", req.Scid, err)
+		}
+	default:
+		opts.Mask = wordcloud.Shapes[req.Mask]()
+	}
+
+	if req.ColorBy == colorBySentiment {
+		sentiment, err := svc.threadSentiment(c, wordDoc, cloudOpts)
+		if err != nil {
+			return nil, err
+		}
+
+		opts.Colors = make(map[string]string, len(sentiment.Words))
+		for word, score := range sentiment.Words {
+			opts.Colors[word] = wordcloud.DivergingColor(score)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := render(&buf, wordDoc.Words, opts); err != nil {
+		return nil, fmt.Errorf("could not render cloud of %s: %w", req.Scid, err)
+	}
+
+	if !crawling {
+		svc.renders.Set(key, buf.Bytes())
+	}
+
+	return buf.Bytes(), nil
+}
+
+// renderKey identifies the rendering options of the request in the key of its render.
+func (req *GetRedditThreadCloudReq) renderKey() string {
+	padding := "default"
+	if req.Padding != nil {
+		padding = fmt.Sprint(*req.Padding)
+	}
+
+	return fmt.Sprintf("%dx%d|font=%g-%g|scale=%s|rotation=%s|palette=%s|seed=%d|max=%d|background=%s|padding=%s|mask=%s|color=%s",
+		req.Width, req.Height, req.MinFontSize, req.MaxFontSize, req.Scale, req.Rotation, req.Palette, req.Seed,
+		req.MaxWords, req.Background, padding, req.Mask, req.ColorBy)
+}
+
+func (req *GetRedditThreadCloudReq) options() wordcloud.Options {
+	opts := wordcloud.Options{
+		Width:       req.Width,
+		Height:      req.Height,
+		MinFontSize: req.MinFontSize,
+		MaxFontSize: req.MaxFontSize,
+		Scale:       wordcloud.Scale(req.Scale),
+		Rotation:    wordcloud.Rotation(req.Rotation),
+		Palette:     req.Palette,
+		Seed:        req.Seed,
+		MaxWords:    req.MaxWords,
+		Background:  req.Background,
+		Padding:     wordcloud.DefaultOptions().Padding,
+	}
+
+	if req.Padding != nil {
+		opts.Padding = float64(*req.Padding)
+	}
+
+	return opts
+}
+
+type subredditAboutResponse struct {
+	Data struct {
+		CommunityIcon string `json:"community_icon"`
+		IconImg       string `json:"icon_img"`
+	} `json:"data"`
+}
+
+// subredditIcon fetches and decodes the icon of a subreddit, "r/name".
+func (svc *service) subredditIcon(c context.Context, subreddit string) (image.Image, error) {
+	redditReq, err := http.NewRequestWithContext(c, "GET", fmt.Sprintf("%s/%s/about", defaultBaseURL, subreddit), nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	redditReq.Header.Set("User-Agent", "redditwordcloud/1.0")
+
+	svc.rl.Take()
+	res, err := svc.redditClient.Do(redditReq)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not get about of %s: %w", subreddit, err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("could not get about of %s: status %d", subreddit, res.StatusCode)
+	}
+
+	var about subredditAboutResponse
+
+	if err := json.NewDecoder(res.Body).Decode(&about); err != nil {
+		return nil, fmt.Errorf("could not decode about of %s: %w", subreddit, err)
+	}
+
+	// Icon URLs are served HTML-escaped.
+	icon := html.UnescapeString(about.Data.CommunityIcon)
+	if icon == "" {
+		icon = html.UnescapeString(about.Data.IconImg)
+	}
+
+	if icon == "" {
+		return nil, fmt.Errorf("%s has no icon", subreddit)
+	}
+
+	iconReq, err := http.NewRequestWithContext(c, "GET", icon, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	iconRes, err := svc.client.Do(iconReq)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not get icon of %s: %w", subreddit, err)
+	}
+	defer iconRes.Body.Close()
+
+	if iconRes.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("could not get icon of %s: status %d", subreddit, iconRes.StatusCode)
+	}
+
+	img, err := decodeIcon(io.LimitReader(iconRes.Body, maxIconBytes))
+
+	if err != nil {
+		return nil, fmt.Errorf("could not decode icon of %s: %w", subreddit, err)
+	}
+
+	return img, nil
+}
+
+// decodeIcon decodes an icon image, rejecting the images larger than
+// maxIconSide pixels on a side before decoding their pixels.
+func decodeIcon(r io.Reader) (image.Image, error) {
+	var header bytes.Buffer
+
+	config, _, err := image.DecodeConfig(io.TeeReader(r, &header))
+
+	if err != nil {
+		return nil, err
+	}
+
+	if config.Width > maxIconSide || config.Height > maxIconSide {
+		return nil, fmt.Errorf("icon of %dx%d pixels is larger than %dx%d", config.Width, config.Height, maxIconSide, maxIconSide)
+	}
+
+	img, _, err := image.Decode(io.MultiReader(&header, r))
+	return img, err
+}
@@ -0,0 +1,70 @@
+package reddit
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"testing"
+)
+
+func encodedIcon(t *testing.T, width, height int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, width, height))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDecodeIcon(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr bool
+	}{
+		{name: "icon", data: encodedIcon(t, 256, 256)},
+		{name: "largest icon", data: encodedIcon(t, maxIconSide, 1)},
+		{name: "too wide", data: encodedIcon(t, maxIconSide+1, 1), wantErr: true},
+		{name: "too tall", data: encodedIcon(t, 1, maxIconSide+1), wantErr: true},
+		{name: "not an image", data: []byte("<html></html>"), wantErr: true},
+		{name: "truncated", data: encodedIcon(t, 64, 64)[:60], wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img, err := decodeIcon(bytes.NewReader(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("decodeIcon() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && img == nil {
+				t.Errorf("decodeIcon() = nil")
+			}
+		})
+	}
+}
+
+func TestRenderKey(t *testing.T) {
+	zero, two := 0, 2
+	base := GetRedditThreadCloudReq{Width: 800}
+
+	tests := []struct {
+		name  string
+		other GetRedditThreadCloudReq
+		same  bool
+	}{
+		{"same options", GetRedditThreadCloudReq{Width: 800}, true},
+		{"other scid", GetRedditThreadCloudReq{Scid: "r/a/comments/b", Width: 800}, true},
+		{"other width", GetRedditThreadCloudReq{Width: 801}, false},
+		{"zero padding", GetRedditThreadCloudReq{Width: 800, Padding: &zero}, false},
+		{"other padding", GetRedditThreadCloudReq{Width: 800, Padding: &two}, false},
+		{"other mask", GetRedditThreadCloudReq{Width: 800, Mask: "snoo"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := base.renderKey() == tt.other.renderKey(); got != tt.same {
+				t.Errorf("same key = %v, want %v", got, tt.same)
+			}
+		})
+	}
+}
@@ -6,28 +6,91 @@ import (
 	"redditwordcloud/internal/mongodb"
 	"redditwordcloud/internal/newrelic"
 	"redditwordcloud/pkg/util"
+	"strings"
 	"sync"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 	"go.uber.org/zap"
 )
 
 type repository struct {
-	upsertMu        sync.Mutex
-	wordsCollection *mongo.Collection
-	nrc             *newrelic.NewRelicClient
+	upsertMu             sync.Mutex
+	wordsCollection      *mongo.Collection
+	commentsCollection   *mongo.Collection
+	backgroundCollection *mongo.Collection
+	watchesCollection    *mongo.Collection
+	cloudsCollection     *mongo.Collection
+	phrasesCollection    *mongo.Collection
+	nrc                  *newrelic.NewRelicClient
 }
 
 func NewRepository(mdbc *mongodb.MongoDBClient, nrc *newrelic.NewRelicClient) Repository {
 
-	collection := mdbc.Client.Database(mdbc.Config.DatabaseName).Collection(mdbc.Config.CollectionName)
+	db := mdbc.Client.Database(mdbc.Config.DatabaseName)
+	collection := db.Collection(mdbc.Config.CollectionName)
+	commentsCollection := db.Collection(mdbc.Config.CommentsCollectionName)
+	backgroundCollection := db.Collection(mdbc.Config.BackgroundCollectionName)
+	watchesCollection := db.Collection(mdbc.Config.WatchesCollectionName)
+	cloudsCollection := db.Collection(mdbc.Config.CloudsCollectionName)
+	phrasesCollection := db.Collection(mdbc.Config.PhrasesCollectionName)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, err := commentsCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "scid", Value: 1}, {Key: "comment_id", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}); err != nil {
+		zap.S().Errorf("Could not create index on comments collection: %w", err)
+	}
+
+	// Concordances look comments up by the words they contain.
+	if _, err := commentsCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "words.$**", Value: 1}},
+	}); err != nil {
+		zap.S().Errorf("Could not create words index on comments collection: %w", err)
+	}
+
+	if _, err := backgroundCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "scope", Value: 1}, {Key: "word", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}); err != nil {
+		zap.S().Errorf("Could not create index on background collection: %w", err)
+	}
+
+	if _, err := watchesCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "scid", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}); err != nil {
+		zap.S().Errorf("Could not create index on watches collection: %w", err)
+	}
+
+	if _, err := cloudsCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "scid", Value: 1}, {Key: "fingerprint", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}); err != nil {
+		zap.S().Errorf("Could not create index on clouds collection: %w", err)
+	}
+
+	if _, err := phrasesCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "scid", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}); err != nil {
+		zap.S().Errorf("Could not create index on phrases collection: %w", err)
+	}
 
 	return &repository{
-		wordsCollection: collection,
-		nrc:             nrc,
+		wordsCollection:      collection,
+		commentsCollection:   commentsCollection,
+		backgroundCollection: backgroundCollection,
+		watchesCollection:    watchesCollection,
+		cloudsCollection:     cloudsCollection,
+		phrasesCollection:    phrasesCollection,
+		nrc:                  nrc,
 	}
 }
 
@@ -73,11 +136,14 @@ func (r *repository) GetWordsFromLink(ctx context.Context, scid string) (*WordDo
 	return &result, nil
 }
 
-func (r *repository) Upsert(ctx context.Context, words map[string]int, scid string) error {
+// Upsert adds the deltas of a crawl batch to the WordDocument of scid, and
+// records the batch as counted in its Batches.
+func (r *repository) Upsert(ctx context.Context, words map[string]int, excluded map[string]int, languages map[string]int, facets Facets, scid string, batch string) error {
 	defer r.nrc.Client.StartTransaction(fmt.Sprintf("%s: Upsert", scid)).End()
 	filter := bson.D{{Key: "scid", Value: scid}}
 
 	r.upsertMu.Lock()
+	defer r.upsertMu.Unlock()
 	wordDoc, err := r.GetWordsFromLink(ctx, scid)
 
 	if err != nil {
@@ -86,6 +152,26 @@ func (r *repository) Upsert(ctx context.Context, words map[string]int, scid stri
 
 	if wordDoc != nil {
 		wordDoc.Words = util.CombineMaps(wordDoc.Words, words)
+		wordDoc.Excluded = util.CombineMaps(wordDoc.Excluded, excluded)
+		wordDoc.Languages = util.CombineMaps(wordDoc.Languages, languages)
+		if wordDoc.Facets == nil {
+			wordDoc.Facets = make(Facets)
+		}
+		wordDoc.Facets.addAll(facets, 1)
+		wordDoc.Facets.prune()
+		if wordDoc.Batches == nil {
+			wordDoc.Batches = make(map[string]bool)
+		}
+		wordDoc.Batches[batch] = true
+
+		// Reconciled comments subtract their old counts, drop the ones no longer counted.
+		for _, counts := range []map[string]int{wordDoc.Words, wordDoc.Excluded, wordDoc.Languages} {
+			for key, count := range counts {
+				if count <= 0 {
+					delete(counts, key)
+				}
+			}
+		}
 	}
 
 	update := bson.M{
@@ -96,7 +182,264 @@ func (r *repository) Upsert(ctx context.Context, words map[string]int, scid stri
 		zap.S().Errorf("Error upserting WordDocument %s to MongoDb: %w", scid, err)
 		return fmt.Errorf("could not upsert: %w", err)
 	}
-	r.upsertMu.Unlock()
+
+	return nil
+}
+
+func (r *repository) SetWords(ctx context.Context, words map[string]int, excluded map[string]int, languages map[string]int, facets Facets, scid string) error {
+	defer r.nrc.Client.StartTransaction(fmt.Sprintf("%s: SetWords", scid)).End()
+	filter := bson.D{{Key: "scid", Value: scid}}
+
+	r.upsertMu.Lock()
+	defer r.upsertMu.Unlock()
+
+	// The words are rebuilt from the comments as they are, none is pending.
+	update := bson.M{
+		"$set": bson.M{
+			"words":        words,
+			"excluded":     excluded,
+			"languages":    languages,
+			"facets":       facets,
+			"last_updated": primitive.NewDateTimeFromTime(time.Now()),
+		},
+		"$unset": bson.M{"batches": ""},
+	}
+
+	if _, err := r.wordsCollection.UpdateOne(ctx, filter, update); err != nil {
+		zap.S().Errorf("Error setting words of WordDocument %s in MongoDb: %w", scid, err)
+		return fmt.Errorf("could not set words: %w", err)
+	}
+
+	return nil
+}
+
+func (r *repository) GetCloud(ctx context.Context, scid string, fingerprint string) (*CloudDocument, error) {
+	defer r.nrc.Client.StartTransaction(fmt.Sprintf("%s: GetCloud", scid)).End()
+	filter := bson.D{{Key: "scid", Value: scid}, {Key: "fingerprint", Value: fingerprint}}
+
+	var cloud CloudDocument
+
+	if err := r.cloudsCollection.FindOne(ctx, filter).Decode(&cloud); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		zap.S().Errorf("Error getting cloud %s of %s from MongoDb: %w", fingerprint, scid, err)
+		return nil, err
+	}
+
+	return &cloud, nil
+}
+
+func (r *repository) SaveCloud(ctx context.Context, cloud *CloudDocument) error {
+	defer r.nrc.Client.StartTransaction(fmt.Sprintf("%s: SaveCloud", cloud.Scid)).End()
+	filter := bson.D{{Key: "scid", Value: cloud.Scid}, {Key: "fingerprint", Value: cloud.Fingerprint}}
+
+	update := bson.M{"$set": bson.M{
+		"words":        cloud.Words,
+		"facets":       cloud.Facets,
+		"last_crawled": cloud.LastCrawled,
+		"last_updated": cloud.LastUpdated,
+	}}
+
+	if _, err := r.cloudsCollection.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true)); err != nil {
+		zap.S().Errorf("Error saving cloud %s of %s to MongoDb: %w", cloud.Fingerprint, cloud.Scid, err)
+		return fmt.Errorf("could not save cloud: %w", err)
+	}
+
+	return nil
+}
+
+func (r *repository) GetPhrases(ctx context.Context, scid string) (*PhrasesDocument, error) {
+	defer r.nrc.Client.StartTransaction(fmt.Sprintf("%s: GetPhrases", scid)).End()
+	filter := bson.D{{Key: "scid", Value: scid}}
+
+	var doc PhrasesDocument
+
+	if err := r.phrasesCollection.FindOne(ctx, filter).Decode(&doc); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		zap.S().Errorf("Error getting phrases of %s from MongoDb: %w", scid, err)
+		return nil, err
+	}
+
+	return &doc, nil
+}
+
+func (r *repository) SavePhrases(ctx context.Context, doc *PhrasesDocument) error {
+	defer r.nrc.Client.StartTransaction(fmt.Sprintf("%s: SavePhrases", doc.Scid)).End()
+	filter := bson.D{{Key: "scid", Value: doc.Scid}}
+
+	update := bson.M{"$set": bson.M{
+		"casing":       doc.Casing,
+		"proper":       doc.Proper,
+		"names":        doc.Names,
+		"collocations": doc.Collocations,
+		"max_length":   doc.MaxLength,
+		"tokenizer":    doc.Tokenizer,
+		"last_crawled": doc.LastCrawled,
+		"last_updated": doc.LastUpdated,
+	}}
+
+	if _, err := r.phrasesCollection.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true)); err != nil {
+		zap.S().Errorf("Error saving phrases of %s to MongoDb: %w", doc.Scid, err)
+		return fmt.Errorf("could not save phrases: %w", err)
+	}
+
+	return nil
+}
+
+func (r *repository) SetPost(ctx context.Context, scid string, post PostDocument) error {
+	defer r.nrc.Client.StartTransaction(fmt.Sprintf("%s: SetPost", scid)).End()
+	filter := bson.D{{Key: "scid", Value: scid}}
+
+	r.upsertMu.Lock()
+	defer r.upsertMu.Unlock()
+
+	if _, err := r.wordsCollection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"post": post}}); err != nil {
+		zap.S().Errorf("Error setting post of WordDocument %s in MongoDb: %w", scid, err)
+		return fmt.Errorf("could not set post: %w", err)
+	}
+
+	return nil
+}
+
+func (r *repository) MarkCrawled(ctx context.Context, scid string, crawledAt time.Time, threadCreated time.Time) error {
+	defer r.nrc.Client.StartTransaction(fmt.Sprintf("%s: MarkCrawled", scid)).End()
+	filter := bson.D{{Key: "scid", Value: scid}}
+
+	set := bson.M{"last_crawled": primitive.NewDateTimeFromTime(crawledAt)}
+	if !threadCreated.IsZero() {
+		set["thread_created"] = primitive.NewDateTimeFromTime(threadCreated)
+	}
+
+	// Upsert rewrites the whole document, so it must not interleave with this update.
+	r.upsertMu.Lock()
+	defer r.upsertMu.Unlock()
+
+	if _, err := r.wordsCollection.UpdateOne(ctx, filter, bson.M{"$set": set}); err != nil {
+		zap.S().Errorf("Error marking WordDocument %s as crawled in MongoDb: %w", scid, err)
+		return fmt.Errorf("could not mark crawled: %w", err)
+	}
+
+	return nil
+}
+
+// ClearPending clears the pending marks of the comments of scid changed by
+// batch, or by any batch if batch is empty, then forgets the batch.
+func (r *repository) ClearPending(ctx context.Context, scid string, batch string) error {
+	defer r.nrc.Client.StartTransaction(fmt.Sprintf("%s: ClearPending", scid)).End()
+
+	filter := bson.D{{Key: "scid", Value: scid}, {Key: "pending", Value: bson.M{"$exists": true}}}
+	if batch != "" {
+		filter = bson.D{{Key: "scid", Value: scid}, {Key: "pending.batch", Value: batch}}
+	}
+
+	if _, err := r.commentsCollection.UpdateMany(ctx, filter, bson.M{"$unset": bson.M{"pending": ""}}); err != nil {
+		zap.S().Errorf("Error clearing pending comments of %s in MongoDb: %w", scid, err)
+		return fmt.Errorf("could not clear pending comments: %w", err)
+	}
+
+	if batch == "" {
+		return nil
+	}
+
+	// Upsert rewrites the whole document, so it must not interleave with this update.
+	r.upsertMu.Lock()
+	defer r.upsertMu.Unlock()
+
+	if _, err := r.wordsCollection.UpdateOne(ctx, bson.D{{Key: "scid", Value: scid}}, bson.M{"$unset": bson.M{"batches." + batch: ""}}); err != nil {
+		zap.S().Errorf("Error clearing batch %s of %s in MongoDb: %w", batch, scid, err)
+		return fmt.Errorf("could not clear batch: %w", err)
+	}
+
+	return nil
+}
+
+func (r *repository) GetComments(ctx context.Context, scid string) ([]CommentDocument, error) {
+	defer r.nrc.Client.StartTransaction(fmt.Sprintf("%s: GetComments", scid)).End()
+	filter := bson.D{{Key: "scid", Value: scid}}
+
+	cursor, err := r.commentsCollection.Find(ctx, filter)
+
+	if err != nil {
+		zap.S().Errorf("Error getting comments of %s from MongoDb: %w", scid, err)
+		return nil, err
+	}
+
+	var comments []CommentDocument
+
+	if err := cursor.All(ctx, &comments); err != nil {
+		zap.S().Errorf("Error decoding comments of %s from MongoDb: %w", scid, err)
+		return nil, err
+	}
+
+	return comments, nil
+}
+
+// GetCommentsWithWord returns the comments of a thread counting word.
+func (r *repository) GetCommentsWithWord(ctx context.Context, scid string, word string) ([]CommentDocument, error) {
+	defer r.nrc.Client.StartTransaction(fmt.Sprintf("%s: GetCommentsWithWord", scid)).End()
+	filter := bson.D{{Key: "scid", Value: scid}}
+
+	// Words holding a path separator cannot be queried as a field, their
+	// comments are matched by the caller.
+	if !strings.ContainsAny(word, ".$") {
+		filter = append(filter, bson.E{Key: "words." + word, Value: bson.D{{Key: "$exists", Value: true}}})
+	}
+
+	cursor, err := r.commentsCollection.Find(ctx, filter)
+
+	if err != nil {
+		zap.S().Errorf("Error getting comments of %s with %q from MongoDb: %w", scid, word, err)
+		return nil, err
+	}
+
+	var comments []CommentDocument
+
+	if err := cursor.All(ctx, &comments); err != nil {
+		zap.S().Errorf("Error decoding comments of %s from MongoDb: %w", scid, err)
+		return nil, err
+	}
+
+	return comments, nil
+}
+
+func (r *repository) CountComments(ctx context.Context, scid string) (int, error) {
+	defer r.nrc.Client.StartTransaction(fmt.Sprintf("%s: CountComments", scid)).End()
+	filter := bson.D{{Key: "scid", Value: scid}}
+
+	count, err := r.commentsCollection.CountDocuments(ctx, filter)
+
+	if err != nil {
+		zap.S().Errorf("Error counting comments of %s in MongoDb: %w", scid, err)
+		return 0, err
+	}
+
+	return int(count), nil
+}
+
+func (r *repository) UpsertComments(ctx context.Context, comments []CommentDocument) error {
+	if len(comments) == 0 {
+		return nil
+	}
+
+	scid := comments[0].SubredditAndCommentId
+	defer r.nrc.Client.StartTransaction(fmt.Sprintf("%s: UpsertComments", scid)).End()
+
+	models := make([]mongo.WriteModel, 0, len(comments))
+	for _, comment := range comments {
+		comment.ID = primitive.NilObjectID
+		models = append(models, mongo.NewUpdateOneModel().
+			SetFilter(bson.D{{Key: "scid", Value: comment.SubredditAndCommentId}, {Key: "comment_id", Value: comment.CommentId}}).
+			SetUpdate(bson.M{"$set": comment}).
+			SetUpsert(true))
+	}
+
+	if _, err := r.commentsCollection.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false)); err != nil {
+		zap.S().Errorf("Error upserting comments of %s to MongoDb: %w", scid, err)
+		return fmt.Errorf("could not upsert comments: %w", err)
+	}
 
 	return nil
 }
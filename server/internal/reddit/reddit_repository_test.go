@@ -0,0 +1,242 @@
+package reddit
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// fakeRepository keeps the words, comments and clouds of threads in memory.
+// Methods the tests do not need are left to the nil embedded Repository.
+type fakeRepository struct {
+	Repository
+
+	mu       sync.Mutex
+	clock    int64
+	words    map[string]*WordDocument
+	comments map[string]map[string]CommentDocument
+	clouds   map[string]*CloudDocument
+}
+
+func newFakeRepository() *fakeRepository {
+	return &fakeRepository{
+		words:    make(map[string]*WordDocument),
+		comments: make(map[string]map[string]CommentDocument),
+		clouds:   make(map[string]*CloudDocument),
+	}
+}
+
+// now returns a time later than the previous one, so that every write
+// changes LastUpdated.
+func (r *fakeRepository) now() primitive.DateTime {
+	r.clock++
+	return primitive.DateTime(r.clock)
+}
+
+func (r *fakeRepository) addComments(comments ...CommentDocument) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, comment := range comments {
+		scid := comment.SubredditAndCommentId
+		if r.comments[scid] == nil {
+			r.comments[scid] = make(map[string]CommentDocument)
+		}
+		r.comments[scid][comment.CommentId] = comment
+	}
+}
+
+func (r *fakeRepository) addWords(doc WordDocument) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	doc.LastUpdated = r.now()
+	r.words[doc.SubredditAndCommentId] = &doc
+}
+
+func (r *fakeRepository) InsertWords(ctx context.Context, words map[string]int, scid string) (*WordDocument, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	doc := &WordDocument{SubredditAndCommentId: scid, Words: words, LastUpdated: r.now()}
+	r.words[scid] = doc
+	copied := *doc
+	return &copied, nil
+}
+
+func (r *fakeRepository) GetWordsFromLink(ctx context.Context, scid string) (*WordDocument, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	doc, ok := r.words[scid]
+	if !ok {
+		return nil, nil
+	}
+	copied := *doc
+	return &copied, nil
+}
+
+func (r *fakeRepository) Upsert(ctx context.Context, words map[string]int, excluded map[string]int, languages map[string]int, facets Facets, link string, batch string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	doc, ok := r.words[link]
+	if !ok {
+		doc = &WordDocument{SubredditAndCommentId: link}
+		r.words[link] = doc
+	}
+
+	doc.Words = mergeCounts(doc.Words, words)
+	doc.Excluded = mergeCounts(doc.Excluded, excluded)
+	doc.Languages = mergeCounts(doc.Languages, languages)
+	if doc.Facets == nil {
+		doc.Facets = make(Facets)
+	}
+	doc.Facets.addAll(facets, 1)
+	if doc.Batches == nil {
+		doc.Batches = make(map[string]bool)
+	}
+	doc.Batches[batch] = true
+	doc.LastUpdated = r.now()
+	return nil
+}
+
+// mergeCounts returns counts with deltas added, without the counts that drop to zero.
+func mergeCounts(counts map[string]int, deltas map[string]int) map[string]int {
+	merged := make(map[string]int, len(counts))
+	for key, count := range counts {
+		merged[key] = count
+	}
+	for key, delta := range deltas {
+		if merged[key] += delta; merged[key] <= 0 {
+			delete(merged, key)
+		}
+	}
+	return merged
+}
+
+func (r *fakeRepository) ClearPending(ctx context.Context, scid string, batch string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id, comment := range r.comments[scid] {
+		if comment.Pending != nil && (batch == "" || comment.Pending.Batch == batch) {
+			comment.Pending = nil
+			r.comments[scid][id] = comment
+		}
+	}
+
+	if doc, ok := r.words[scid]; ok && batch != "" {
+		delete(doc.Batches, batch)
+	}
+	return nil
+}
+
+func (r *fakeRepository) MarkCrawled(ctx context.Context, scid string, crawledAt time.Time, threadCreated time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if doc, ok := r.words[scid]; ok {
+		doc.LastCrawled = primitive.NewDateTimeFromTime(crawledAt)
+		doc.ThreadCreated = primitive.NewDateTimeFromTime(threadCreated)
+	}
+	return nil
+}
+
+func (r *fakeRepository) GetComments(ctx context.Context, scid string) ([]CommentDocument, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	comments := make([]CommentDocument, 0, len(r.comments[scid]))
+	for _, comment := range r.comments[scid] {
+		comments = append(comments, comment)
+	}
+	sort.Slice(comments, func(i, j int) bool { return comments[i].CommentId < comments[j].CommentId })
+	return comments, nil
+}
+
+func (r *fakeRepository) GetCommentsWithWord(ctx context.Context, scid string, word string) ([]CommentDocument, error) {
+	comments, _ := r.GetComments(ctx, scid)
+
+	withWord := comments[:0]
+	for _, comment := range comments {
+		if comment.Words[word] > 0 {
+			withWord = append(withWord, comment)
+		}
+	}
+	return withWord, nil
+}
+
+func (r *fakeRepository) CountComments(ctx context.Context, scid string) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return len(r.comments[scid]), nil
+}
+
+func (r *fakeRepository) UpdateBackground(ctx context.Context, scid string, before map[string]int) error {
+	return nil
+}
+
+func (r *fakeRepository) UpsertComments(ctx context.Context, comments []CommentDocument) error {
+	r.addComments(comments...)
+	return nil
+}
+
+func (r *fakeRepository) SetWords(ctx context.Context, words map[string]int, excluded map[string]int, languages map[string]int, facets Facets, scid string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	doc, ok := r.words[scid]
+	if !ok {
+		return nil
+	}
+	doc.Words = words
+	doc.Excluded = excluded
+	doc.Languages = languages
+	doc.Facets = facets
+	doc.LastUpdated = r.now()
+	return nil
+}
+
+func (r *fakeRepository) SetPost(ctx context.Context, scid string, post PostDocument) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if doc, ok := r.words[scid]; ok {
+		doc.Post = &post
+	}
+	return nil
+}
+
+func (r *fakeRepository) GetCloud(ctx context.Context, scid string, fingerprint string) (*CloudDocument, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cloud, ok := r.clouds[scid+"|"+fingerprint]
+	if !ok {
+		return nil, nil
+	}
+	copied := *cloud
+	return &copied, nil
+}
+
+func (r *fakeRepository) SaveCloud(ctx context.Context, cloud *CloudDocument) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	copied := *cloud
+	r.clouds[cloud.Scid+"|"+cloud.Fingerprint] = &copied
+	return nil
+}
+
+func (r *fakeRepository) GetPhrases(ctx context.Context, scid string) (*PhrasesDocument, error) {
+	return nil, nil
+}
+
+func (r *fakeRepository) SavePhrases(ctx context.Context, doc *PhrasesDocument) error {
+	return nil
+}
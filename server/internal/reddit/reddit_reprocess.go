@@ -0,0 +1,121 @@
+package reddit
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ReprocessRedditThread rebuilds the word map of a thread from its stored
+// comments with the current tokenization pipeline, without fetching the
+// thread from Reddit again.
+func (svc *service) ReprocessRedditThread(c context.Context, req *ReprocessRedditThreadReq) (*GetRedditThreadWordsRes, error) {
+	scid := req.Scid
+
+	// Reprocessing rewrites the counts a crawl would be merging into.
+	if !svc.crawling.SetIfAbsent(scid, time.Now()) {
+		return nil, fmt.Errorf("could not reprocess %s: a crawl is underway", scid)
+	}
+	defer svc.crawling.Remove(scid)
+
+	wordDocument, err := svc.Repository.GetWordsFromLink(c, scid)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not get words of %s: %w", scid, err)
+	}
+
+	if wordDocument == nil {
+		return nil, fmt.Errorf("could not reprocess %s: %w", scid, ErrThreadNotFound)
+	}
+
+	comments, err := svc.Repository.GetComments(c, scid)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not get comments of %s: %w", scid, err)
+	}
+
+	// Copies are flagged after the earliest comment with their body.
+	sort.Slice(comments, func(i, j int) bool {
+		return commentBefore(&comments[i], &comments[j])
+	})
+
+	bots := newBotDetector(svc.bots, nil)
+	words := make(map[string]int)
+	excluded := make(map[string]int)
+	languages := make(map[string]int)
+	facets := make(Facets)
+	skipped := 0
+
+	for i, comment := range comments {
+		switch {
+		case comment.Body == "" && comment.BodyHash != bodyHash(""):
+			// Recorded before comment bodies were stored, its old words are kept.
+			skipped++
+		case isDeletedBody(comment.Body):
+			comments[i].Words = nil
+			comments[i].Tokenizer = tokenizerVersion
+			comments[i].Flag = deletionFlag(comment.Body)
+			comments[i].Language = ""
+			comments[i].Facets = nil
+		default:
+			comments[i].Words = countWords(tokenize(comment.Body))
+			comments[i].Tokenizer = tokenizerVersion
+			comments[i].Sentiment = scoreSentiment(comment.Body)
+			comments[i].Language = detectLanguage(comment.Body)
+			comments[i].Facets = extractFacets(comment.Body)
+			comments[i].Flag = bots.flag(&comments[i])
+		}
+
+		if flag := comments[i].Flag; flag != "" {
+			excluded[flag]++
+			continue
+		}
+
+		if lang := comments[i].Language; lang != "" {
+			languages[lang]++
+		}
+		facets.addAll(comments[i].Facets, 1)
+
+		for word, count := range comments[i].Words {
+			words[word] += count
+		}
+	}
+
+	if err := svc.Repository.UpsertComments(c, comments); err != nil {
+		return nil, fmt.Errorf("could not update comments of %s: %w", scid, err)
+	}
+
+	if err := svc.Repository.ClearPending(c, scid, ""); err != nil {
+		return nil, fmt.Errorf("could not clear pending comments of %s: %w", scid, err)
+	}
+
+	if err := svc.Repository.SetWords(c, words, excluded, languages, facets, scid); err != nil {
+		return nil, fmt.Errorf("could not set words of %s: %w", scid, err)
+	}
+
+	if err := svc.Repository.UpdateBackground(c, scid, wordDocument.Words); err != nil {
+		zap.S().Errorf("Could not update the background of %s: %w", scid, err)
+	}
+
+	if post := wordDocument.Post; post != nil {
+		post.Words = countWords(append(tokenize(post.Title), tokenize(post.Selftext)...))
+		if err := svc.Repository.SetPost(c, scid, *post); err != nil {
+			return nil, fmt.Errorf("could not set post of %s: %w", scid, err)
+		}
+	}
+
+	zap.S().Debugf("Reprocessed %d comments of %s into %d words, %d comments had no stored body.", len(comments), scid, len(words), skipped)
+
+	wordDocument.Excluded = excluded
+	moderation, err := svc.moderationStats(c, wordDocument)
+
+	if err != nil {
+		return nil, err
+	}
+
+	lastCrawled := wordDocument.crawledAt()
+	return &GetRedditThreadWordsRes{Words: words, Excluded: excluded, Moderation: moderation, Languages: languages, Facets: facets.ranked(), Post: wordDocument.Post, Success: true, Link: scid, LastCrawled: &lastCrawled}, nil
+}
@@ -0,0 +1,127 @@
+package reddit
+
+import (
+	"context"
+	"redditwordcloud/pkg/util"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestReprocessRedditThread(t *testing.T) {
+	scid := "r/test/comments/t"
+	english := "The compiler rejects every program that borrows the same value twice."
+	copied := "Have you tried turning it off and on again, it fixed mine."
+
+	comment := func(id string, created int64, body string, words map[string]int) CommentDocument {
+		return CommentDocument{SubredditAndCommentId: scid, CommentId: id, Author: id, CreatedUTC: primitive.NewDateTimeFromTime(time.Unix(1700000000+created, 0)), Body: body, BodyHash: bodyHash(body), Words: words}
+	}
+
+	tests := []struct {
+		name          string
+		comments      []CommentDocument
+		post          *PostDocument
+		wantWords     map[string]int
+		wantExcluded  map[string]int
+		wantLanguages map[string]int
+		wantFlags     map[string]string
+		wantPostWords map[string]int
+	}{
+		{
+			name: "comment without a stored body",
+			comments: []CommentDocument{
+				{SubredditAndCommentId: scid, CommentId: "a", BodyHash: "recorded-before-bodies", Words: map[string]int{"legacy": 2}},
+				comment("b", 1, english, nil),
+			},
+			wantWords:     util.CombineMaps(map[string]int{"legacy": 2}, countWords(tokenize(english))),
+			wantExcluded:  map[string]int{},
+			wantLanguages: map[string]int{"en": 1},
+			wantFlags:     map[string]string{"a": "", "b": ""},
+		},
+		{
+			name: "comment counted by an earlier tokenizer",
+			comments: []CommentDocument{
+				comment("a", 1, english, map[string]int{"stale": 1}),
+			},
+			wantWords:     countWords(tokenize(english)),
+			wantExcluded:  map[string]int{},
+			wantLanguages: map[string]int{"en": 1},
+			wantFlags:     map[string]string{"a": ""},
+		},
+		{
+			name: "deleted and removed comments",
+			comments: []CommentDocument{
+				comment("a", 1, deletedBody, map[string]int{"stale": 1}),
+				comment("b", 2, removedBody, map[string]int{"stale": 1}),
+				comment("c", 3, english, nil),
+			},
+			wantWords:     countWords(tokenize(english)),
+			wantExcluded:  map[string]int{flagDeleted: 1, flagRemoved: 1},
+			wantLanguages: map[string]int{"en": 1},
+			wantFlags:     map[string]string{"a": flagDeleted, "b": flagRemoved, "c": ""},
+		},
+		{
+			name: "duplicate comments",
+			comments: []CommentDocument{
+				comment("b", 2, copied, nil),
+				comment("a", 1, copied, nil),
+			},
+			wantWords:     countWords(tokenize(copied)),
+			wantExcluded:  map[string]int{flagDuplicate: 1},
+			wantLanguages: map[string]int{"en": 1},
+			wantFlags:     map[string]string{"a": "", "b": flagDuplicate},
+		},
+		{
+			name:          "post",
+			post:          &PostDocument{Title: "Compiler errors", Selftext: "Which compiler errors confuse you?"},
+			wantWords:     map[string]int{},
+			wantExcluded:  map[string]int{},
+			wantLanguages: map[string]int{},
+			wantFlags:     map[string]string{},
+			wantPostWords: countWords(append(tokenize("Compiler errors"), tokenize("Which compiler errors confuse you?")...)),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newFakeRepository()
+			repo.addWords(WordDocument{SubredditAndCommentId: scid, Words: map[string]int{"stale": 2}, Post: tt.post})
+			repo.addComments(tt.comments...)
+			svc := newTestService(repo, nil)
+			svc.bots = BotConfig{MinDuplicateLength: 30}
+			ctx := context.Background()
+
+			res, err := svc.ReprocessRedditThread(ctx, &ReprocessRedditThreadReq{Scid: scid})
+			if err != nil {
+				t.Fatalf("ReprocessRedditThread() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(res.Words, tt.wantWords) {
+				t.Errorf("words = %v, want %v", res.Words, tt.wantWords)
+			}
+			if !reflect.DeepEqual(res.Excluded, tt.wantExcluded) {
+				t.Errorf("excluded = %v, want %v", res.Excluded, tt.wantExcluded)
+			}
+			if !reflect.DeepEqual(res.Languages, tt.wantLanguages) {
+				t.Errorf("languages = %v, want %v", res.Languages, tt.wantLanguages)
+			}
+
+			wordDoc, _ := repo.GetWordsFromLink(ctx, scid)
+			if !reflect.DeepEqual(wordDoc.Words, tt.wantWords) {
+				t.Errorf("stored words = %v, want %v", wordDoc.Words, tt.wantWords)
+			}
+			if tt.post != nil && !reflect.DeepEqual(wordDoc.Post.Words, tt.wantPostWords) {
+				t.Errorf("post words = %v, want %v", wordDoc.Post.Words, tt.wantPostWords)
+			}
+
+			comments, _ := repo.GetComments(ctx, scid)
+			for _, comment := range comments {
+				if comment.Flag != tt.wantFlags[comment.CommentId] {
+					t.Errorf("flag of %s = %q, want %q", comment.CommentId, comment.Flag, tt.wantFlags[comment.CommentId])
+				}
+			}
+		})
+	}
+}
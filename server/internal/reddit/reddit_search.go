@@ -0,0 +1,107 @@
+package reddit
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"redditwordcloud/pkg/util"
+	"strconv"
+)
+
+const (
+	defaultSearchSort = "relevance"
+	defaultSearchTime = "all"
+)
+
+// GetRedditSearchWords aggregates the cloud of the posts matching a Reddit
+// search, from their titles and selftexts and optionally their comments. The
+// comments of every post are crawled as a thread of its own.
+func (svc *service) GetRedditSearchWords(c context.Context, req *GetRedditSearchWordsReq) (*GetRedditSearchWordsRes, error) {
+	sort := req.Sort
+	if sort == "" {
+		sort = defaultSearchSort
+	}
+
+	t := req.Time
+	if t == "" {
+		t = defaultSearchTime
+	}
+
+	limit := req.Limit
+	if limit == 0 {
+		limit = defaultListingLimit
+	}
+
+	params := url.Values{}
+	params.Add("q", req.Query)
+	params.Add("sort", sort)
+	params.Add("t", t)
+	params.Add("limit", strconv.Itoa(limit))
+	params.Add("type", "link")
+
+	path := "search"
+	var subreddit string
+	if req.Subreddit != "" {
+		subreddit = subredditPrefix(req.Subreddit)
+		path = fmt.Sprintf("%s/search", subreddit)
+		params.Add("restrict_sr", "true")
+	}
+
+	posts, _, err := svc.getListing(path, params)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not search for %q: %w", req.Query, err)
+	}
+
+	res := &GetRedditSearchWordsRes{
+		Query:     req.Query,
+		Subreddit: subreddit,
+		Sort:      sort,
+		Time:      t,
+		Words:     make(map[string]int),
+		Success:   true,
+	}
+
+	facets := make(Facets)
+
+	if req.IncludeComments {
+		opts := newFreshnessOptions(req.ForceRefresh, req.MaxAge)
+		res.Words, facets, res.Posts = svc.aggregatePosts(c, subreddit, posts, opts)
+		res.Refreshing = anyRefreshing(res.Posts)
+	} else {
+		res.Posts = make([]PostWords, 0, len(posts))
+		for _, post := range posts {
+			res.Posts = append(res.Posts, PostWords{
+				Link:      postLink(subreddit, post).scid(),
+				Title:     post.Title,
+				Permalink: redditBaseURL + post.Permalink,
+				Score:     post.Score,
+			})
+		}
+	}
+
+	textWords := make(map[string]map[string]int, len(posts))
+	for _, post := range posts {
+		textWords[postLink(subreddit, post).scid()] = countWords(append(tokenize(post.Title), tokenize(post.Selftext)...))
+		facets.addAll(extractFacets(post.Title+"\n"+post.Selftext), 1)
+	}
+
+	for i, pw := range res.Posts {
+		res.Posts[i].TextWords = textWords[pw.Link]
+		res.Words = util.CombineMaps(res.Words, textWords[pw.Link])
+	}
+
+	res.VocabularySize = len(res.Words)
+	res.TotalTokens = countTokens(res.Words)
+	res.Facets = facets.ranked()
+
+	if res.List, err = req.WordListQuery.listWords(res.Words, req.withStats); err != nil {
+		return nil, err
+	}
+	if res.List != nil {
+		res.Words = nil
+		dropPostWords(res.Posts)
+	}
+
+	return res, nil
+}
@@ -0,0 +1,86 @@
+package reddit
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestGetRedditSearchWords(t *testing.T) {
+	reddit := fakeReddit{
+		"/search": listingResponse("", "t3",
+			&RedditRepliesObject{Id: "a", Subreddit: "golang", Title: "Generics", Selftext: "generics released"},
+			&RedditRepliesObject{Id: "b", Subreddit: "rust", Title: "Borrow checker"},
+		),
+	}
+
+	tests := []struct {
+		name          string
+		req           GetRedditSearchWordsReq
+		wantWords     map[string]int
+		wantPostWords []map[string]int
+		wantTextWords []map[string]int
+		wantList      bool
+	}{
+		{
+			name:          "titles and selftexts",
+			req:           GetRedditSearchWordsReq{Query: "q"},
+			wantWords:     map[string]int{"generics": 2, "released": 1, "borrow": 1, "checker": 1},
+			wantPostWords: []map[string]int{nil, nil},
+			wantTextWords: []map[string]int{{"generics": 2, "released": 1}, {"borrow": 1, "checker": 1}},
+		},
+		{
+			name:          "comments",
+			req:           GetRedditSearchWordsReq{Query: "q", IncludeComments: true},
+			wantWords:     map[string]int{"generics": 3, "released": 1, "borrow": 1, "checker": 1, "lifetimes": 1},
+			wantPostWords: []map[string]int{{"generics": 1}, {"lifetimes": 1}},
+			wantTextWords: []map[string]int{{"generics": 2, "released": 1}, {"borrow": 1, "checker": 1}},
+		},
+		{
+			name:          "word list",
+			req:           GetRedditSearchWordsReq{Query: "q", IncludeComments: true, WordListQuery: WordListQuery{Top: 1}},
+			wantPostWords: []map[string]int{nil, nil},
+			wantTextWords: []map[string]int{nil, nil},
+			wantList:      true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			crawledAt := primitive.NewDateTimeFromTime(time.Now())
+			repo := newFakeRepository()
+			repo.addWords(WordDocument{SubredditAndCommentId: "r/golang/comments/a", Words: map[string]int{"generics": 1}, LastCrawled: crawledAt})
+			repo.addWords(WordDocument{SubredditAndCommentId: "r/rust/comments/b", Words: map[string]int{"lifetimes": 1}, LastCrawled: crawledAt})
+			svc := newTestService(repo, reddit)
+
+			res, err := svc.GetRedditSearchWords(context.Background(), &tt.req)
+			if err != nil {
+				t.Fatalf("GetRedditSearchWords() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(res.Words, tt.wantWords) {
+				t.Errorf("words = %v, want %v", res.Words, tt.wantWords)
+			}
+			if (res.List != nil) != tt.wantList {
+				t.Errorf("list = %v, want a list %v", res.List, tt.wantList)
+			}
+			if len(res.Posts) != len(tt.wantPostWords) {
+				t.Fatalf("posts = %v, want %d posts", res.Posts, len(tt.wantPostWords))
+			}
+			for i, pw := range res.Posts {
+				if !reflect.DeepEqual(pw.Words, tt.wantPostWords[i]) {
+					t.Errorf("words of post %s = %v, want %v", pw.Link, pw.Words, tt.wantPostWords[i])
+				}
+				if !reflect.DeepEqual(pw.TextWords, tt.wantTextWords[i]) {
+					t.Errorf("text words of post %s = %v, want %v", pw.Link, pw.TextWords, tt.wantTextWords[i])
+				}
+				if pw.Refreshing {
+					t.Errorf("post %s is refreshing, want a fresh post", pw.Link)
+				}
+			}
+		})
+	}
+}
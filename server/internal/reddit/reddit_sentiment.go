@@ -0,0 +1,85 @@
+package reddit
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"redditwordcloud/pkg/sentiment"
+)
+
+// ThreadSentiment is the sentiment of the comments of a thread counted in its cloud.
+type ThreadSentiment struct {
+	// Compound is the mean compound sentiment of the comments, from -1 to 1.
+	Compound float64 `json:"compound"`
+	Positive int     `json:"positive"`
+	Neutral  int     `json:"neutral"`
+	Negative int     `json:"negative"`
+	// Words are the mean sentiment of the comments each word appears in.
+	Words map[string]float64 `json:"words"`
+}
+
+// scoreSentiment returns the compound sentiment of a comment body.
+func scoreSentiment(body string) *float64 {
+	score := sentiment.Score(html.UnescapeString(body))
+	return &score
+}
+
+// threadSentiment aggregates the sentiment of the comments of the thread of
+// wordDoc selected by opts. Comments stored before they were scored are
+// scored now.
+func (svc *service) threadSentiment(c context.Context, wordDoc *WordDocument, opts cloudOptions) (*ThreadSentiment, error) {
+	scid := wordDoc.SubredditAndCommentId
+	comments, err := svc.Repository.GetComments(c, scid)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not get comments of %s: %w", scid, err)
+	}
+
+	env := threadFilterEnv(wordDoc, comments)
+	if opts, err = svc.withPhrases(c, opts, wordDoc, comments); err != nil {
+		return nil, err
+	}
+	res := &ThreadSentiment{Words: make(map[string]float64)}
+	sums := make(map[string]float64)
+	occurrences := make(map[string]int)
+	total, scored := 0.0, 0
+
+	for i := range comments {
+		comment := &comments[i]
+		if !opts.counts(comment, env) || len(comment.Words) == 0 {
+			continue
+		}
+
+		score := comment.Sentiment
+		if score == nil {
+			score = scoreSentiment(comment.Body)
+		}
+
+		total += *score
+		scored++
+
+		switch {
+		case *score >= sentiment.Neutral:
+			res.Positive++
+		case *score <= -sentiment.Neutral:
+			res.Negative++
+		default:
+			res.Neutral++
+		}
+
+		for word := range opts.words(comment) {
+			sums[word] += *score
+			occurrences[word]++
+		}
+	}
+
+	if scored != 0 {
+		res.Compound = total / float64(scored)
+	}
+
+	for word, sum := range sums {
+		res.Words[word] = sum / float64(occurrences[word])
+	}
+
+	return res, nil
+}
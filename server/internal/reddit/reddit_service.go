@@ -1,16 +1,15 @@
 package reddit
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
-	"html"
 	"io"
 	"net/http"
 	"redditwordcloud/pkg/retryhttp"
 	"redditwordcloud/pkg/util"
 	"regexp"
-	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -28,6 +27,19 @@ type service struct {
 	redditClient *http.Client
 	rl           ratelimit.Limiter
 	rcfg         RedditConfig
+	freshness    FreshnessConfig
+	bots         BotConfig
+	watch        WatchConfig
+	// watchMu serializes the requests watching threads.
+	watchMu sync.Mutex
+	// crawling holds the scids of threads being crawled, with the time the crawl started.
+	crawling cmap.ConcurrentMap[string, time.Time]
+	// refreshes holds a slot for each background refresh of a thread underway.
+	refreshes chan struct{}
+	// renders are the rendered images of clouds.
+	renders *lruCache[[]byte]
+	// topics are the topic models of threads.
+	topics *lruCache[*GetRedditThreadTopicsRes]
 }
 
 const (
@@ -35,6 +47,10 @@ const (
 	defaultBaseURLReadonly = "https://reddit.com"
 	defaultTokenURL        = "https://www.reddit.com/api/v1/access_token"
 	maxMoreChildrenLimit   = 100
+	// maxRenderCacheBytes bounds the size of the rendered clouds kept in memory.
+	maxRenderCacheBytes = 64 << 20
+	// maxTopicsCacheBytes bounds the size of the topic models kept in memory.
+	maxTopicsCacheBytes    = 32 << 20
 	getCommentArticleLimit = 4
 	redditRps              = 2
 	NotFoundMessage        = "{\"message\": \"Not Found\", \"error\": 404}"
@@ -60,6 +76,13 @@ func NewService(rcfg RedditConfig, repository Repository) Service {
 		redditClient: c,
 		rl:           ratelimit.New(redditRps),
 		rcfg:         rcfg,
+		freshness:    rcfg.Cache,
+		bots:         rcfg.Bots,
+		watch:        rcfg.Watch,
+		crawling:     cmap.New[time.Time](),
+		refreshes:    make(chan struct{}, rcfg.Cache.MaxRefreshes),
+		renders:      newLRUCache(maxRenderCacheBytes, func(b []byte) int { return len(b) }),
+		topics:       newLRUCache(maxTopicsCacheBytes, topicsCost),
 	}
 }
 
@@ -75,11 +98,28 @@ type Link struct {
 	CommentId  string
 }
 
+// scid returns the key of the thread's documents in the repository.
+func (link *Link) scid() string {
+	return fmt.Sprintf("%s/comments/%s", link.Subreddit, link.CommentId)
+}
+
 type RedditRepliesObject struct {
-	Body    string         `json:"body"`
-	Replies RedditResponse `json:"replies,omitempty"`
-	Ups     int            `json:"ups"`
-	Id      string         `json:"id"`
+	Body       string         `json:"body"`
+	Replies    RedditResponse `json:"replies,omitempty"`
+	Ups        int            `json:"ups"`
+	Id         string         `json:"id"`
+	CreatedUTC float64        `json:"created_utc"`
+	ParentId   string         `json:"parent_id"`
+	Author     string         `json:"author"`
+	Score      int            `json:"score"`
+	Permalink  string         `json:"permalink"`
+	Subreddit  string         `json:"subreddit"`
+	Title      string         `json:"title"`
+	Selftext   string         `json:"selftext"`
+	// Depth is 0 for top-level comments.
+	Depth         int    `json:"depth"`
+	Distinguished string `json:"distinguished"`
+	Stickied      bool   `json:"stickied"`
 }
 
 type RedditMoreObject struct {
@@ -100,6 +140,7 @@ type RedditMoreChildrenObject struct {
 
 type RedditListingObject struct {
 	Children []RedditResponse `json:"children,omitempty"`
+	After    string           `json:"after,omitempty"`
 }
 
 type RedditResponse struct {
@@ -148,59 +189,117 @@ func (rr *RedditResponse) UnmarshalJSON(data []byte) error {
 
 func (rro *RedditRepliesObject) UnmarshalJSON(data []byte) error {
 	// Define an auxiliary type to use for unmarshaling, to avoid recursion
-	type RedditChildrenDataObjectNoReplies struct {
-		Body string `json:"body"`
-		Id   string `json:"id"`
-		Ups  int    `json:"ups"`
+	type RedditChildrenDataObjectAux RedditRepliesObject
+
+	// Replies is an empty string rather than a listing when there are none.
+	var aux struct {
+		RedditChildrenDataObjectAux
+		Replies json.RawMessage `json:"replies"`
 	}
 
-	type RedditChildrenDataObjectAux RedditRepliesObject
-	var raw json.RawMessage
-	if err := json.Unmarshal(data, &raw); err != nil {
+	if err := json.Unmarshal(data, &aux); err != nil {
 		return err
 	}
 
-	rawString := string(raw)
-	index := strings.Index(rawString, "\"replies\": {")
+	*rro = RedditRepliesObject(aux.RedditChildrenDataObjectAux)
+	rro.Replies = RedditResponse{}
 
-	if index != -1 {
-		var aux RedditChildrenDataObjectAux
-		if err := json.Unmarshal(data, &aux); err != nil {
+	if replies := bytes.TrimSpace(aux.Replies); len(replies) != 0 && replies[0] == '{' {
+		if err := json.Unmarshal(replies, &rro.Replies); err != nil {
 			return err
 		}
-		rro.Body = aux.Body
-		rro.Ups = aux.Ups
-		rro.Id = aux.Id
-		rro.Replies = aux.Replies
-	} else {
-		var auxNoReplies RedditChildrenDataObjectNoReplies
-		if err := json.Unmarshal(data, &auxNoReplies); err != nil {
-			return err
-		}
-		rro.Body = auxNoReplies.Body
-		rro.Ups = auxNoReplies.Ups
-		rro.Id = auxNoReplies.Id
-		rro.Replies = RedditResponse{}
-
 	}
 
 	return nil
 }
 
-func (svc *service) upsertWords(c context.Context, words cmap.ConcurrentMap[string, int], link *Link) {
-	scid := fmt.Sprintf("%s/comments/%s", link.Subreddit, link.CommentId)
-	m := words.Items()
+// commitBatch writes the comment records and word deltas of b to the
+// repository. Comments are written first, marked pending with what they
+// counted for before, so that the comments of a batch whose words could not
+// be written are counted again by the next crawl instead of being skipped.
+func (svc *service) commitBatch(c context.Context, b *batch, cr *crawl) {
+	m := b.words.Items()
+	excluded := b.excluded.Items()
+	languages := b.languages.Items()
+	facets := b.facets.Items()
+
+	comments := make([]CommentDocument, 0, b.comments.Count())
+	pending := false
+	for _, comment := range b.comments.Items() {
+		comments = append(comments, comment)
+		pending = pending || comment.Pending != nil
+	}
 
-	if len(m) != 0 {
-		if err := svc.Repository.Upsert(c, m, scid); err != nil {
-			zap.S().Errorf("could not upsert words for linkId: %s\n", link.CommentId)
+	if len(comments) != 0 {
+		if err := svc.Repository.UpsertComments(c, comments); err != nil {
+			zap.S().Errorf("could not upsert comments for scid: %s\n", cr.scid)
+			cr.failed.Store(true)
+			return
+		}
+		zap.S().Debugf("Upserted %d comments.", len(comments))
+	}
+
+	if len(m) != 0 || len(excluded) != 0 || len(languages) != 0 || len(facets) != 0 {
+		if err := svc.Repository.Upsert(c, m, excluded, languages, facetsOfDeltas(facets), cr.scid, b.id); err != nil {
+			zap.S().Errorf("could not upsert words for scid: %s\n", cr.scid)
+			cr.failed.Store(true)
 			return
 		}
 		zap.S().Debugf("Upserted Word Map with %d entries.", len(m))
 	}
+
+	// A batch left in the Batches of the WordDocument is cleared by the next crawl.
+	if pending {
+		if err := svc.Repository.ClearPending(c, cr.scid, b.id); err != nil {
+			zap.S().Errorf("Could not clear pending comments of %s: %w", cr.scid, err)
+		}
+	}
 }
 
-func (svc *service) processRedditResponse(c context.Context, redditResponse RedditResponse, words cmap.ConcurrentMap[string, int], link *Link) {
+// endCrawl updates the background with the change of the words of the
+// crawl, once all of its batches are written. A crawl that failed still
+// wrote the words of some batches.
+func (svc *service) endCrawl(c context.Context, cr *crawl) {
+	if err := svc.Repository.UpdateBackground(c, cr.scid, cr.words); err != nil {
+		zap.S().Errorf("Could not update the background of %s: %w", cr.scid, err)
+	}
+}
+
+// startCrawl returns a crawl of the comments counted under scid, clearing
+// the pending marks of the batches whose words were written.
+func (svc *service) startCrawl(ctx context.Context, scid string, link *Link) (*crawl, error) {
+	wordDoc, err := svc.Repository.GetWordsFromLink(ctx, scid)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var applied map[string]bool
+	if wordDoc != nil {
+		applied = wordDoc.Batches
+	}
+
+	counted, err := svc.Repository.GetComments(ctx, scid)
+
+	if err != nil {
+		return nil, err
+	}
+
+	cr := newCrawl(scid, link, counted, applied, svc.bots)
+	if wordDoc != nil {
+		cr.words = wordDoc.Words
+	}
+
+	for _, id := range cr.appliedBatches(applied) {
+		if err := svc.Repository.ClearPending(ctx, scid, id); err != nil {
+			zap.S().Errorf("Could not clear pending comments of %s: %w", scid, err)
+		}
+	}
+
+	return cr, nil
+}
+
+func (svc *service) processRedditResponse(c context.Context, redditResponse RedditResponse, b *batch, cr *crawl) {
 
 	if redditResponse.Data == nil || redditResponse.Kind == "t3" {
 		return
@@ -211,23 +310,26 @@ func (svc *service) processRedditResponse(c context.Context, redditResponse Redd
 	switch redditResponse.Kind {
 	case "Listing":
 		children := redditResponse.Data.(*RedditListingObject).Children
-		words := cmap.New[int]()
+		b := newBatch()
 		for _, child := range children {
-			svc.processRedditResponse(c, child, words, link)
+			svc.processRedditResponse(c, child, b, cr)
 		}
-		svc.upsertWords(c, words, link)
+		svc.commitBatch(c, b, cr)
 	case "more":
 		if len(redditResponse.Data.(*RedditMoreObject).Children) == 0 {
 			wg.Add(1)
-			words := cmap.New[int]()
-			go func(words cmap.ConcurrentMap[string, int]) {
+			b := newBatch()
+			go func(b *batch) {
 				defer wg.Done()
 				parentIdNoPrefix := strings.Split(redditResponse.Data.(*RedditMoreObject).ParentId, "_")[1]
-				redditResponses, _ := svc.getCommentArticleResp(parentIdNoPrefix, link)
+				redditResponses, err := svc.getCommentArticleResp(parentIdNoPrefix, cr.link)
+				if err != nil {
+					cr.failed.Store(true)
+				}
 				for _, resp := range redditResponses {
-					svc.processRedditResponse(c, resp, words, link)
+					svc.processRedditResponse(c, resp, b, cr)
 				}
-			}(words)
+			}(b)
 			wg.Wait()
 			return
 		}
@@ -250,7 +352,7 @@ func (svc *service) processRedditResponse(c context.Context, redditResponse Redd
 
 				q := redditReq.URL.Query()
 
-				q.Add("link_id", fmt.Sprintf("t3_%s", link.CommentId))
+				q.Add("link_id", fmt.Sprintf("t3_%s", cr.link.CommentId))
 				q.Add("children", strings.Join(children, ","))
 				q.Add("api_type", "json")
 
@@ -260,7 +362,8 @@ func (svc *service) processRedditResponse(c context.Context, redditResponse Redd
 
 				if err != nil {
 					zap.S().Debugf("Error c.Do: %w, reprocessing reddit response...", err)
-					svc.processRedditResponse(c, redditResponse, cmap.New[int](), link)
+					svc.processRedditResponse(c, redditResponse, newBatch(), cr)
+					return
 				}
 
 				zap.S().Debugf("Successful GET request.")
@@ -269,7 +372,7 @@ func (svc *service) processRedditResponse(c context.Context, redditResponse Redd
 
 				if err != nil {
 					zap.S().Error("Error reading the response body:", err)
-					wg.Wait()
+					cr.failed.Store(true)
 					return
 				}
 
@@ -280,60 +383,54 @@ func (svc *service) processRedditResponse(c context.Context, redditResponse Redd
 				if err != nil {
 					zap.S().Error("Error unmarshaling res to JSON:", err)
 					zap.S().Debug(string(body))
-					wg.Wait()
+					cr.failed.Store(true)
 					return
 				}
 
-				words := cmap.New[int]()
+				b := newBatch()
 				for _, child := range MoreChildrenAPIResponse.JSON.Data.Things {
-					svc.processRedditResponse(c, child, words, link)
+					svc.processRedditResponse(c, child, b, cr)
 				}
-				svc.upsertWords(c, words, link)
+				svc.commitBatch(c, b, cr)
 			}(chunk, parentId)
 		}
 		wg.Wait()
 		return
 	default:
 		repliesResp := redditResponse.Data.(*RedditRepliesObject).Replies
-		svc.processRedditResponse(c, repliesResp, words, link)
-
-		body := redditResponse.Data.(*RedditRepliesObject).Body
-		htmlUnescapedBody := html.UnescapeString(body)
-		cleanedBody := cleanBody(strconv.Quote(htmlUnescapedBody))
-
-		for _, word := range strings.Split(cleanedBody, " ") {
-			lowerCaseWord := strings.ToLower(word)
-			cwc, b := words.Get(lowerCaseWord)
-			if b {
-				words.Set(lowerCaseWord, cwc+1)
-			} else {
-				words.Set(lowerCaseWord, 1)
-			}
-		}
+		svc.processRedditResponse(c, repliesResp, b, cr)
+
+		cr.count(redditResponse.Data.(*RedditRepliesObject), b)
 	}
 	wg.Wait()
 
 }
 
-func cleanBody(body string) string {
-	re := regexp.MustCompile(`\\(.)`) // Matches any escaped character
+var (
+	// escapedChar matches any escaped character.
+	escapedChar = regexp.MustCompile(`\\(.)`)
+	// punctuation matches a character other than a letter of any script, a
+	// digit or an apostrophe, and the characters around it.
+	punctuation = regexp.MustCompile(`(.{0,1})([^\p{L}\p{N}_\s'])(.{0,1})`)
+	// isFloat matches the separator of a decimal number.
+	isFloat = regexp.MustCompile(`\d([^\p{L}\p{N}_\s])\d`)
+)
 
+func cleanBody(body string) string {
 	// Replace escaped characters with an empty string
-	cleanedBody := re.ReplaceAllString(body, "")
+	cleanedBody := escapedChar.ReplaceAllString(body, "")
 	// Removes quotes at beginning and end of string
 	cleanedBody = strings.Trim(cleanedBody, "\"")
 
 	// zap.S().Debugf("Current cleaned body: %s", cleanedBody)
 
-	re = regexp.MustCompile(`(.{0,1})([^\w\s'])(.{0,1})`)
-	isFloat := regexp.MustCompile(`\d([^\w\s])\d`)
-
-	parts := re.FindAllString(cleanedBody, -1)
+	// Letters of every script are kept, so comments in any language are counted.
+	parts := punctuation.FindAllString(cleanedBody, -1)
 
 	for _, part := range parts {
 		isAFloat := isFloat.MatchString(part)
 		if !isAFloat {
-			newPart := re.ReplaceAllString(part, "$1$3")
+			newPart := punctuation.ReplaceAllString(part, "$1$3")
 			cleanedBody = strings.Replace(cleanedBody, part, newPart, 1)
 			cleanedBody = strings.ReplaceAll(cleanedBody, "’", "'") // Replace curly apostrophe with straight apostrophe
 			cleanedBody = strings.ReplaceAll(cleanedBody, "`", "'") // Replace backtick with straight apostrophe
@@ -362,45 +459,309 @@ func createLink(link string) *Link {
 func (svc *service) GetRedditThreadWordsByLink(c context.Context, req *GetRedditThreadWordsByLinkReq, txn *newrelic.Transaction) (*GetRedditThreadWordsRes, error) {
 	link := createLink(req.Link)
 	linkStr := fmt.Sprintf("%s/%s/%s/comments/%s", link.Protocol, link.DomainName, link.Subreddit, link.CommentId)
-	scid := fmt.Sprintf("%s/comments/%s", link.Subreddit, link.CommentId)
+	scid := link.scid()
+	opts := newFreshnessOptions(req.ForceRefresh, req.MaxAge)
+	filter, err := parseFilter(req.Filter)
 
-	svc.rl.Take()
-	redditResponses, err := svc.getCommentArticleResp(link.CommentId, link)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse filter %q: %w", req.Filter, err)
+	}
+
+	cloudOpts := req.cloudOptions(filter)
+
+	wordDocument, state, err := svc.cachedWords(c, scid, opts, txn, func() { svc.refreshThread(link, cloudOpts) })
+
+	if err == nil && state == expired {
+		segment := txn.StartSegment(fmt.Sprintf("Crawl article %s", scid))
+		_, err = svc.crawlThread(link, cloudOpts)
+		segment.End()
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("could not get comments for link: %s, err: %w", linkStr, err)
+	}
+
+	if state == expired {
+		return &GetRedditThreadWordsRes{Success: true, Words: nil, Link: scid, Refreshing: true}, nil
+	}
 
-	if len(redditResponses) == 0 || err != nil {
+	words := wordDocument.Words
+	facets := wordDocument.Facets
+	if !cloudOpts.isDefault() {
+		derived, err := svc.derivedWords(c, wordDocument, cloudOpts)
 		if err != nil {
-			return nil, fmt.Errorf("could not get comments for link: %s, err: %w", linkStr, err)
+			return nil, err
+		}
+		words = derived.Words
+		facets = derived.Facets
+	}
+
+	var stats wordStats
+	if req.withStats {
+		if stats, err = svc.threadWordStats(c, wordDocument, cloudOpts, req.IncludePost); err != nil {
+			return nil, err
+		}
+	}
+
+	if req.IncludePost && wordDocument.Post != nil {
+		postWeight := req.PostWeight
+		if postWeight == 0 {
+			postWeight = 1
+		}
+		postWords := cloudOpts.postWords(wordDocument.Post)
+		if req.withStats {
+			stats.counts = util.CombineMaps(words, postWords)
+		}
+		words = util.CombineMaps(words, weightWords(postWords, postWeight))
+	}
+
+	moderation, err := svc.moderationStats(c, wordDocument)
+
+	if err != nil {
+		return nil, err
+	}
+
+	lastCrawled := wordDocument.crawledAt()
+	res := &GetRedditThreadWordsRes{
+		Words:          words,
+		VocabularySize: len(words),
+		TotalTokens:    countTokens(words),
+		Post:           wordDocument.Post,
+		Filter:         filter.String(),
+		Excluded:       wordDocument.Excluded,
+		Moderation:     moderation,
+		Languages:      wordDocument.Languages,
+		Facets:         facets.ranked(),
+		Success:        true,
+		Link:           scid,
+		Stale:          state == stale,
+		Refreshing:     svc.crawling.Has(scid),
+		LastCrawled:    &lastCrawled,
+		wordStats:      stats,
+	}
+
+	if req.IncludeSentiment {
+		if res.Sentiment, err = svc.threadSentiment(c, wordDocument, cloudOpts); err != nil {
+			return nil, err
+		}
+	}
+
+	if req.Scoring != "" && req.Scoring != scoringCount {
+		if res.Scores, err = svc.scoreWords(c, scid, words, req.Scoring, req.Background); err != nil {
+			return nil, err
 		}
 	}
 
+	// Exports list every word, whatever the query.
+	if req.WordListQuery.isSet() && !req.withStats {
+		if res.List, err = req.WordListQuery.list(words, res.Scores); err != nil {
+			return nil, err
+		}
+		res.Words = nil
+		res.Scores = nil
+	}
+
+	return res, nil
+}
+
+// cachedWords returns the WordDocument stored under scid and its freshness.
+// Stale documents are refreshed in the background with refresh. Missing
+// documents are inserted empty and reported as expired, like for expired ones
+// the caller has to crawl their comments.
+func (svc *service) cachedWords(c context.Context, scid string, opts freshnessOptions, txn *newrelic.Transaction, refresh func()) (*WordDocument, freshness, error) {
 	zap.S().Debugf("Checking if scid %s exists in db...", scid)
 
 	segment := txn.StartSegment(fmt.Sprintf("scid %s check", scid))
-	if wordDocument, err := svc.Repository.GetWordsFromLink(c, scid); err == nil {
+	wordDocument, err := svc.Repository.GetWordsFromLink(c, scid)
+	segment.End()
+
+	if err == nil {
 		if wordDocument != nil {
-			if util.IsInLastWeek(wordDocument.LastUpdated.Time()) {
+			state := svc.freshness.evaluate(wordDocument, opts, time.Now())
+			zap.S().Debugf("Word document for %s is %s.", scid, state)
+
+			if state != expired {
 				zap.S().Debugf("Retrieved word document for %s from MongoDB Words Collection.", scid)
-				return &GetRedditThreadWordsRes{Words: wordDocument.Words, Success: true, Link: scid}, nil
+				if state == stale {
+					go refresh()
+				}
 			}
+			return wordDocument, state, nil
 		} else {
 			zap.S().Debugf("Scid %s does not exist in db. Inserting with empty map.", scid)
 			if _, err := svc.Repository.InsertWords(c, make(map[string]int), scid); err != nil {
 				zap.S().Errorf("Could not insert empty map into MongoDB: %w", err)
-				return nil, fmt.Errorf("could not insert empty map into MongoDB: %w", err)
+				return nil, expired, fmt.Errorf("could not insert empty map into MongoDB: %w", err)
 			}
 			zap.S().Debug("Created Word Map with 0 entries.")
 		}
 	}
-	segment.End()
 
-	defer txn.StartSegment(fmt.Sprintf("Process RedditResponses for article %s", scid))
+	return nil, expired, nil
+}
+
+// refreshThread crawls a thread in the background of a request, such as one
+// whose cached cloud has gone stale, and returns once the crawl completes.
+// Refreshes past MaxRefreshes are skipped, a later request refreshes the thread.
+func (svc *service) refreshThread(link *Link, opts cloudOptions) {
+	select {
+	case svc.refreshes <- struct{}{}:
+		defer func() { <-svc.refreshes }()
+	default:
+		zap.S().Debugf("Too many refreshes underway, skipping %s.", link.scid())
+		return
+	}
+
+	done, err := svc.startThreadCrawl(link, opts)
+
+	if err != nil {
+		zap.S().Errorf("could not refresh thread %s: %w", link.CommentId, err)
+		return
+	}
+
+	if done != nil {
+		<-done
+	}
+}
+
+// crawlThread fetches the comment tree of the thread and processes it in the
+// background. Only comments that are new or changed since the previous crawl
+// are counted. The cloud selected by opts, if they are not the default, is
+// counted along and cached. At most one crawl of a thread runs at a time;
+// false is returned when one is already underway.
+func (svc *service) crawlThread(link *Link, opts cloudOptions) (bool, error) {
+	done, err := svc.startThreadCrawl(link, opts)
+	return done != nil, err
+}
+
+// startThreadCrawl starts a crawl of the thread as crawlThread does and
+// returns a channel closed once the crawl completes, nil when a crawl of the
+// thread is already underway.
+func (svc *service) startThreadCrawl(link *Link, opts cloudOptions) (<-chan struct{}, error) {
+	scid := link.scid()
+
+	if !svc.crawling.SetIfAbsent(scid, time.Now()) {
+		zap.S().Debugf("Crawl of %s already underway.", scid)
+		return nil, nil
+	}
+
+	redditResponses, err := svc.getCommentArticleResp(link.CommentId, link)
+
+	if err != nil {
+		svc.crawling.Remove(scid)
+		return nil, err
+	}
+
+	// The request's context ends with the response, the crawl outlives it.
+	ctx := context.Background()
+
+	cr, err := svc.startCrawl(ctx, scid, link)
+
+	if err != nil {
+		svc.crawling.Remove(scid)
+		return nil, err
+	}
+
+	var created time.Time
+	if submission := threadSubmission(redditResponses); submission != nil {
+		if submission.CreatedUTC != 0 {
+			created = time.Unix(int64(submission.CreatedUTC), 0)
+		}
+
+		post := PostDocument{
+			Title:    submission.Title,
+			Selftext: submission.Selftext,
+			Words:    countWords(append(tokenize(submission.Title), tokenize(submission.Selftext)...)),
+		}
+
+		if err := svc.Repository.SetPost(ctx, scid, post); err != nil {
+			zap.S().Errorf("Could not set the post of %s: %w", scid, err)
+		}
+	}
+
+	crawledAt := time.Now()
+	if err := svc.Repository.MarkCrawled(ctx, scid, crawledAt, created); err != nil {
+		zap.S().Errorf("Could not mark %s as crawled: %w", scid, err)
+	}
+
+	// Threads crawled without their creation time have no environment for
+	// filters yet, their filtered clouds are counted from the stored comments.
+	if !created.IsZero() {
+		cr.cloud = newCrawlCloud(opts, filterEnv{threadCreated: created})
+	}
+
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		defer svc.crawling.Remove(scid)
+
+		var wg sync.WaitGroup
+		for _, rr := range redditResponses {
+			wg.Add(1)
+			go func(rr RedditResponse) {
+				defer wg.Done()
+				svc.processRedditResponse(ctx, rr, newBatch(), cr)
+			}(rr)
+		}
+		wg.Wait()
+
+		// Comments deleted without replies disappear from the tree, their words
+		// can only be subtracted once the whole tree has been seen.
+		if !cr.failed.Load() {
+			svc.commitBatch(ctx, cr.vanished(), cr)
+			svc.commitBatch(ctx, cr.duplicates(), cr)
+		}
+		svc.endCrawl(ctx, cr)
+
+		if cr.cloud != nil && !cr.failed.Load() {
+			svc.saveCrawlCloud(ctx, cr.cloud, scid, crawledAt)
+		}
+
+		if !cr.failed.Load() {
+			svc.refreshPhrases(ctx, scid, opts)
+		}
+
+		zap.S().Debugf("Finished crawl of %s.", scid)
+	}()
+
+	return done, nil
+}
+
+// saveCrawlCloud caches the cloud counted by a crawl of scid, stamped with
+// the time the words of the thread were last updated by the crawl.
+func (svc *service) saveCrawlCloud(ctx context.Context, cloud *crawlCloud, scid string, crawledAt time.Time) {
+	wordDoc, err := svc.Repository.GetWordsFromLink(ctx, scid)
+
+	if err != nil || wordDoc == nil {
+		zap.S().Errorf("Could not get words of %s: %w", scid, err)
+		return
+	}
+
+	if err := svc.Repository.SaveCloud(ctx, cloud.document(scid, crawledAt, wordDoc.LastUpdated)); err != nil {
+		zap.S().Errorf("Could not cache derived words of %s: %w", scid, err)
+	}
+}
+
+// threadSubmission returns the submission in the responses of a comments
+// article request, or nil if there is none.
+func threadSubmission(redditResponses []RedditResponse) *RedditRepliesObject {
 	for _, rr := range redditResponses {
-		go func(rr RedditResponse) {
-			svc.processRedditResponse(c, rr, cmap.New[int](), link)
-		}(rr)
+		listing, ok := rr.Data.(*RedditListingObject)
+		if !ok {
+			continue
+		}
+		for _, child := range listing.Children {
+			if child.Kind != "t3" {
+				continue
+			}
+			if post, ok := child.Data.(*RedditRepliesObject); ok {
+				return post
+			}
+		}
 	}
 
-	return &GetRedditThreadWordsRes{Success: true, Words: nil, Link: scid}, nil
+	return nil
 }
 
 func (svc *service) getCommentArticleResp(commentId string, link *Link) ([]RedditResponse, error) {
@@ -408,6 +769,7 @@ func (svc *service) getCommentArticleResp(commentId string, link *Link) ([]Reddi
 
 	if err != nil {
 		zap.S().Errorf("Could not create reddit request: ", err)
+		return nil, err
 	}
 
 	redditReq.Header.Set("User-Agent", "redditwordcloud/1.0")
@@ -422,9 +784,9 @@ func (svc *service) getCommentArticleResp(commentId string, link *Link) ([]Reddi
 	res, err := svc.redditClient.Do(redditReq)
 
 	if err != nil {
-		zap.S().Debugf("Error c.Do: %w, reprocessing reddit response...", err)
-		svc.getCommentArticleResp(commentId, link)
+		return nil, fmt.Errorf("could not get comments of %s: %w", link.CommentId, err)
 	}
+	defer res.Body.Close()
 
 	zap.S().Debugf("Successful GET request.")
 
@@ -436,7 +798,7 @@ func (svc *service) getCommentArticleResp(commentId string, link *Link) ([]Reddi
 	}
 
 	if string(body) == NotFoundMessage {
-		return nil, fmt.Errorf("could not find reddit thread %s", link.CommentId)
+		return nil, fmt.Errorf("could not find reddit thread %s: %w", link.CommentId, ErrThreadNotFound)
 	}
 
 	// zap.S().Debugf("Body: %s", body)
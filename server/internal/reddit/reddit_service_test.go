@@ -0,0 +1,180 @@
+package reddit
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	cmap "github.com/orcaman/concurrent-map/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/ratelimit"
+)
+
+// fakeReddit serves the Reddit API from canned responses, keyed by the path
+// of the request and, for the following pages of a listing, its after cursor.
+// Other requests get a 404.
+type fakeReddit map[string]RedditResponse
+
+func (f fakeReddit) RoundTrip(req *http.Request) (*http.Response, error) {
+	key := req.URL.Path
+	if after := req.URL.Query().Get("after"); after != "" {
+		key += "?after=" + after
+	}
+
+	res := &http.Response{StatusCode: http.StatusNotFound, Header: make(http.Header), Body: io.NopCloser(strings.NewReader("{}")), Request: req}
+
+	if response, ok := f[key]; ok {
+		body, err := json.Marshal(response)
+		if err != nil {
+			return nil, err
+		}
+		res.StatusCode = http.StatusOK
+		res.Body = io.NopCloser(strings.NewReader(string(body)))
+	}
+
+	return res, nil
+}
+
+// listingResponse returns a page of a listing of things, followed by the page after.
+func listingResponse(after string, kind string, things ...*RedditRepliesObject) RedditResponse {
+	listing := &RedditListingObject{After: after}
+	for _, thing := range things {
+		listing.Children = append(listing.Children, RedditResponse{Kind: kind, Data: thing})
+	}
+	return RedditResponse{Kind: "Listing", Data: listing}
+}
+
+// newTestService returns a service of repo that calls reddit instead of
+// Reddit. It has no slot for background refreshes, they are all skipped.
+func newTestService(repo *fakeRepository, reddit fakeReddit) *service {
+	return &service{
+		Repository:   repo,
+		redditClient: &http.Client{Transport: reddit},
+		rl:           ratelimit.NewUnlimited(),
+		freshness: FreshnessConfig{
+			MinTTL:               time.Minute,
+			MaxTTL:               168 * time.Hour,
+			AgeFactor:            0.5,
+			StaleWhileRevalidate: 24 * time.Hour,
+		},
+		crawling:  cmap.New[time.Time](),
+		refreshes: make(chan struct{}),
+	}
+}
+
+// waitForCrawl waits for the background crawl of scid to complete.
+func waitForCrawl(t *testing.T, svc *service, scid string) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for svc.crawling.Has(scid) {
+		if time.Now().After(deadline) {
+			t.Fatalf("crawl of %s did not complete", scid)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestGetRedditThreadWordsByLinkPost(t *testing.T) {
+	scid := "r/golang/comments/abc123"
+	post := &PostDocument{Title: "Generics", Selftext: "generics released", Words: map[string]int{"generics": 2, "released": 1}}
+
+	tests := []struct {
+		name string
+		req  GetRedditThreadWordsByLinkReq
+		want map[string]int
+	}{
+		{
+			name: "comments only",
+			req:  GetRedditThreadWordsByLinkReq{},
+			want: map[string]int{"generics": 1, "compiler": 3},
+		},
+		{
+			name: "post",
+			req:  GetRedditThreadWordsByLinkReq{IncludePost: true},
+			want: map[string]int{"generics": 3, "compiler": 3, "released": 1},
+		},
+		{
+			name: "weighted post",
+			req:  GetRedditThreadWordsByLinkReq{IncludePost: true, PostWeight: 3},
+			want: map[string]int{"generics": 7, "compiler": 3, "released": 3},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newFakeRepository()
+			repo.addWords(WordDocument{
+				SubredditAndCommentId: scid,
+				Words:                 map[string]int{"generics": 1, "compiler": 3},
+				LastCrawled:           primitive.NewDateTimeFromTime(time.Now()),
+				Post:                  post,
+			})
+			svc := newTestService(repo, nil)
+
+			tt.req.Link = "https://www.reddit.com/r/golang/comments/abc123/generics/"
+			res, err := svc.GetRedditThreadWordsByLink(context.Background(), &tt.req, nil)
+			if err != nil {
+				t.Fatalf("GetRedditThreadWordsByLink() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(res.Words, tt.want) {
+				t.Errorf("words = %v, want %v", res.Words, tt.want)
+			}
+			if !reflect.DeepEqual(res.Post, post) {
+				t.Errorf("post = %v, want %v", res.Post, post)
+			}
+		})
+	}
+}
+
+// roundTripFunc lets a function serve the requests of an http.Client.
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestRefreshThreadLimit(t *testing.T) {
+	link := &Link{Protocol: "https:/", DomainName: "www.reddit.com", Subreddit: "r/golang", CommentId: "abc123"}
+
+	tests := []struct {
+		name         string
+		slots        int
+		busy         int
+		wantRequests int
+	}{
+		{name: "free slot", slots: 2, busy: 1, wantRequests: 1},
+		{name: "no free slot", slots: 2, busy: 2, wantRequests: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := newTestService(newFakeRepository(), nil)
+			svc.refreshes = make(chan struct{}, tt.slots)
+			for i := 0; i < tt.busy; i++ {
+				svc.refreshes <- struct{}{}
+			}
+
+			requests := 0
+			svc.redditClient.Transport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+				requests++
+				return fakeReddit{}.RoundTrip(req)
+			})
+
+			svc.refreshThread(link, cloudOptions{})
+
+			if requests != tt.wantRequests {
+				t.Errorf("requests = %d, want %d", requests, tt.wantRequests)
+			}
+			if len(svc.refreshes) != tt.busy {
+				t.Errorf("busy slots = %d, want the %d busy before the refresh", len(svc.refreshes), tt.busy)
+			}
+		})
+	}
+}
@@ -0,0 +1,232 @@
+package reddit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"redditwordcloud/pkg/util"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultListing      = "hot"
+	defaultListingTime  = "week"
+	defaultListingLimit = 25
+)
+
+var subredditNameRegexp = regexp.MustCompile(`^(?:/?r/)?[A-Za-z0-9][A-Za-z0-9_]{1,20}$`)
+
+func ValidateSubreddit(fl validator.FieldLevel) bool {
+	return subredditNameRegexp.MatchString(fl.Field().String())
+}
+
+// subredditPrefix returns the name of a subreddit prefixed with "r/", the way
+// Link.Subreddit holds it.
+func subredditPrefix(subreddit string) string {
+	return "r/" + strings.TrimPrefix(strings.TrimPrefix(subreddit, "/"), "r/")
+}
+
+// GetRedditSubredditWords aggregates the clouds of the top posts of a
+// subreddit listing. Every post is crawled as a thread of its own, so the
+// per-post clouds are kept and shared with link requests.
+func (svc *service) GetRedditSubredditWords(c context.Context, req *GetRedditSubredditWordsReq) (*GetRedditSubredditWordsRes, error) {
+	subreddit := subredditPrefix(req.Subreddit)
+	listing, t, posts, err := svc.subredditListing(subreddit, req.Listing, req.Time, req.Limit)
+
+	if err != nil {
+		return nil, err
+	}
+
+	opts := newFreshnessOptions(req.ForceRefresh, req.MaxAge)
+	words, facets, postWords := svc.aggregatePosts(c, subreddit, posts, opts)
+
+	res := &GetRedditSubredditWordsRes{
+		Subreddit:      subreddit,
+		Listing:        listing,
+		Time:           t,
+		Words:          words,
+		VocabularySize: len(words),
+		TotalTokens:    countTokens(words),
+		Posts:          postWords,
+		Success:        true,
+		Refreshing:     anyRefreshing(postWords),
+		Facets:         facets.ranked(),
+	}
+
+	if res.List, err = req.WordListQuery.listWords(words, req.withStats); err != nil {
+		return nil, err
+	}
+	if res.List != nil {
+		res.Words = nil
+		dropPostWords(res.Posts)
+	}
+
+	return res, nil
+}
+
+// subredditListing returns the posts of a listing of subreddit, and the
+// listing and time window they were listed with once defaulted.
+func (svc *service) subredditListing(subreddit, listing, t string, limit int) (string, string, []*RedditRepliesObject, error) {
+	if listing == "" {
+		listing = defaultListing
+	}
+
+	if t == "" {
+		t = defaultListingTime
+	}
+
+	if limit == 0 {
+		limit = defaultListingLimit
+	}
+
+	params := url.Values{}
+	params.Add("limit", strconv.Itoa(limit))
+	params.Add("t", t)
+
+	posts, _, err := svc.getListing(fmt.Sprintf("%s/%s", subreddit, listing), params)
+
+	if err != nil {
+		return "", "", nil, fmt.Errorf("could not get %s posts of %s: %w", listing, subreddit, err)
+	}
+
+	return listing, t, posts, nil
+}
+
+// aggregatePosts combines the clouds and facets of posts, starting a background crawl
+// of every post whose cloud is missing or expired. subreddit is used for posts
+// that do not name theirs.
+func (svc *service) aggregatePosts(c context.Context, subreddit string, posts []*RedditRepliesObject, opts freshnessOptions) (map[string]int, Facets, []PostWords) {
+	words := make(map[string]int)
+	facets := make(Facets)
+	postWords := make([]PostWords, 0, len(posts))
+
+	for _, post := range posts {
+		link := postLink(subreddit, post)
+		scid := link.scid()
+
+		wordDocument, state, err := svc.cachedWords(c, scid, opts, nil, func() { svc.refreshThread(link, cloudOptions{}) })
+
+		if err != nil {
+			zap.S().Errorf("Could not get words of post %s: %w", scid, err)
+			continue
+		}
+
+		if state == expired {
+			go svc.refreshThread(link, cloudOptions{})
+		}
+
+		pw := PostWords{
+			Link:       scid,
+			Title:      post.Title,
+			Permalink:  redditBaseURL + post.Permalink,
+			Score:      post.Score,
+			Refreshing: state == expired || svc.crawling.Has(scid),
+		}
+
+		if state != expired {
+			pw.Words = wordDocument.Words
+			words = util.CombineMaps(words, wordDocument.Words)
+			facets.addAll(wordDocument.Facets, 1)
+		}
+
+		postWords = append(postWords, pw)
+	}
+
+	return words, facets, postWords
+}
+
+// postLink returns the Link of a post from a listing.
+func postLink(subreddit string, post *RedditRepliesObject) *Link {
+	if post.Subreddit != "" {
+		subreddit = subredditPrefix(post.Subreddit)
+	}
+
+	return &Link{
+		Protocol:   "https:/",
+		DomainName: "www.reddit.com",
+		Subreddit:  subreddit,
+		CommentId:  post.Id,
+	}
+}
+
+// dropPostWords removes the clouds of posts from a response that lists the
+// words of their aggregate instead, a cloud of every post would outweigh the list.
+func dropPostWords(postWords []PostWords) {
+	for i := range postWords {
+		postWords[i].Words = nil
+		postWords[i].TextWords = nil
+	}
+}
+
+func anyRefreshing(postWords []PostWords) bool {
+	for _, pw := range postWords {
+		if pw.Refreshing {
+			return true
+		}
+	}
+	return false
+}
+
+// getListing fetches a page of a listing of the Reddit API, such as the hot
+// posts of a subreddit, and returns its things and the cursor of the next page.
+func (svc *service) getListing(path string, params url.Values) ([]*RedditRepliesObject, string, error) {
+	redditReq, err := http.NewRequest("GET", fmt.Sprintf("%s/%s", defaultBaseURL, path), nil)
+
+	if err != nil {
+		zap.S().Errorf("Could not create reddit request: ", err)
+		return nil, "", err
+	}
+
+	redditReq.Header.Set("User-Agent", "redditwordcloud/1.0")
+	redditReq.URL.RawQuery = params.Encode()
+
+	svc.rl.Take()
+	res, err := svc.redditClient.Do(redditReq)
+
+	if err != nil {
+		return nil, "", fmt.Errorf("could not get listing %s: %w", path, err)
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+
+	if err != nil {
+		zap.S().Error("Error reading the response body:", err)
+		return nil, "", err
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("could not get listing %s: status %d", path, res.StatusCode)
+	}
+
+	var listingResponse RedditResponse
+
+	if err := json.Unmarshal(body, &listingResponse); err != nil {
+		zap.S().Error("Error unmarshaling res to JSON:", err)
+		zap.S().Debug(string(body))
+		return nil, "", fmt.Errorf("error unmarshaling res to JSON: %w", err)
+	}
+
+	listing, ok := listingResponse.Data.(*RedditListingObject)
+
+	if !ok {
+		return nil, "", fmt.Errorf("listing %s returned a %s", path, listingResponse.Kind)
+	}
+
+	things := make([]*RedditRepliesObject, 0, len(listing.Children))
+	for _, child := range listing.Children {
+		if thing, ok := child.Data.(*RedditRepliesObject); ok {
+			things = append(things, thing)
+		}
+	}
+
+	return things, listing.After, nil
+}
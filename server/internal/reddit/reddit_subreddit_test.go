@@ -0,0 +1,63 @@
+package reddit
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestAggregatePosts(t *testing.T) {
+	now := time.Now()
+	repo := newFakeRepository()
+	repo.addWords(WordDocument{
+		SubredditAndCommentId: "r/golang/comments/fresh",
+		Words:                 map[string]int{"go": 2, "generics": 1},
+		Facets:                Facets{"emoji": {"🚀": 1}},
+		LastCrawled:           primitive.NewDateTimeFromTime(now),
+	})
+	repo.addWords(WordDocument{
+		SubredditAndCommentId: "r/golang/comments/expired",
+		Words:                 map[string]int{"outdated": 1},
+		LastCrawled:           primitive.NewDateTimeFromTime(now.AddDate(0, -1, 0)),
+		ThreadCreated:         primitive.NewDateTimeFromTime(now.AddDate(-1, 0, 0)),
+	})
+	repo.addWords(WordDocument{
+		SubredditAndCommentId: "r/rust/comments/crosspost",
+		Words:                 map[string]int{"go": 1, "rust": 3},
+		LastCrawled:           primitive.NewDateTimeFromTime(now),
+	})
+	svc := newTestService(repo, nil)
+
+	posts := []*RedditRepliesObject{
+		{Id: "fresh", Title: "Fresh", Permalink: "/r/golang/comments/fresh/"},
+		{Id: "expired", Title: "Expired"},
+		{Id: "missing", Title: "Missing"},
+		{Id: "crosspost", Subreddit: "rust", Title: "Crosspost"},
+	}
+
+	words, facets, postWords := svc.aggregatePosts(context.Background(), "r/golang", posts, freshnessOptions{})
+
+	if want := map[string]int{"go": 3, "generics": 1, "rust": 3}; !reflect.DeepEqual(words, want) {
+		t.Errorf("words = %v, want %v", words, want)
+	}
+	if want := (Facets{"emoji": {"🚀": 1}}); !reflect.DeepEqual(facets, want) {
+		t.Errorf("facets = %v, want %v", facets, want)
+	}
+
+	want := []PostWords{
+		{Link: "r/golang/comments/fresh", Title: "Fresh", Permalink: redditBaseURL + "/r/golang/comments/fresh/", Words: map[string]int{"go": 2, "generics": 1}},
+		{Link: "r/golang/comments/expired", Title: "Expired", Permalink: redditBaseURL, Refreshing: true},
+		{Link: "r/golang/comments/missing", Title: "Missing", Permalink: redditBaseURL, Refreshing: true},
+		{Link: "r/rust/comments/crosspost", Title: "Crosspost", Permalink: redditBaseURL, Words: map[string]int{"go": 1, "rust": 3}},
+	}
+	if !reflect.DeepEqual(postWords, want) {
+		t.Errorf("posts = %+v, want %+v", postWords, want)
+	}
+
+	if missing, _ := repo.GetWordsFromLink(context.Background(), "r/golang/comments/missing"); missing == nil {
+		t.Errorf("words of the missing post were not inserted")
+	}
+}
@@ -0,0 +1,222 @@
+package reddit
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const defaultBranchTopWords = 10
+
+// Orders of the branches of a thread besides size.
+const (
+	branchSortScore   = "score"
+	branchSortCreated = "created"
+)
+
+// commentTree links the stored comments of a thread to their replies through
+// their parent ids. Depths are counted in the tree rather than read from the
+// comments, comments fetched from a "continue this thread" link are stored
+// with depths relative to the comment the page starts at.
+type commentTree struct {
+	comments map[string]*CommentDocument
+	// replies are the replies to each comment and to the post, keyed by the
+	// fullname of their parent, t1_{id} for a comment and t3_{id} for the post.
+	replies map[string][]*CommentDocument
+}
+
+func newCommentTree(comments []CommentDocument) *commentTree {
+	t := &commentTree{
+		comments: make(map[string]*CommentDocument, len(comments)),
+		replies:  make(map[string][]*CommentDocument),
+	}
+
+	for i := range comments {
+		comment := &comments[i]
+		t.comments[comment.CommentId] = comment
+		t.replies[comment.ParentId] = append(t.replies[comment.ParentId], comment)
+	}
+
+	return t
+}
+
+// topLevel returns the comments replying to the post.
+func (t *commentTree) topLevel() []*CommentDocument {
+	var roots []*CommentDocument
+	for parent, replies := range t.replies {
+		if strings.HasPrefix(parent, "t3_") {
+			roots = append(roots, replies...)
+		}
+	}
+	return roots
+}
+
+// subthread counts the cloud of root and the replies below it.
+func (t *commentTree) subthread(root *CommentDocument, opts cloudOptions, env filterEnv) (Subthread, map[string]int, Facets) {
+	sub := Subthread{
+		CommentId: root.CommentId,
+		Author:    root.Author,
+		Score:     root.Score,
+		Permalink: commentPermalink(*root),
+		Created:   root.CreatedUTC.Time(),
+	}
+	words := make(map[string]int)
+	facets := make(Facets)
+
+	type node struct {
+		comment *CommentDocument
+		depth   int
+	}
+
+	// visited guards against a comment being its own ancestor in a corrupt tree.
+	visited := map[string]bool{root.CommentId: true}
+	stack := []node{{root, 0}}
+	for len(stack) > 0 {
+		n := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		sub.Comments++
+		sub.Depth = max(sub.Depth, n.depth)
+
+		if opts.counts(n.comment, env) {
+			sub.Counted++
+			for word, count := range opts.words(n.comment) {
+				words[word] += count
+			}
+			facets.addAll(n.comment.Facets, 1)
+		}
+
+		for _, reply := range t.replies["t1_"+n.comment.CommentId] {
+			if !visited[reply.CommentId] {
+				visited[reply.CommentId] = true
+				stack = append(stack, node{reply, n.depth + 1})
+			}
+		}
+	}
+
+	sub.TotalTokens = countTokens(words)
+
+	return sub, words, facets
+}
+
+// threadTree returns the comment tree of a stored thread and the options of
+// the clouds of its subthreads selected by query.
+func (svc *service) threadTree(c context.Context, scid string, query ThreadCloudQuery) (*commentTree, cloudOptions, filterEnv, error) {
+	wordDoc, err := svc.Repository.GetWordsFromLink(c, scid)
+
+	if err != nil {
+		return nil, cloudOptions{}, filterEnv{}, fmt.Errorf("could not get words of %s: %w", scid, err)
+	}
+
+	if wordDoc == nil {
+		return nil, cloudOptions{}, filterEnv{}, fmt.Errorf("could not get comment tree of %s: %w", scid, ErrThreadNotFound)
+	}
+
+	filter, err := parseFilter(query.Filter)
+
+	if err != nil {
+		return nil, cloudOptions{}, filterEnv{}, fmt.Errorf("could not parse filter %q: %w", query.Filter, err)
+	}
+
+	comments, err := svc.Repository.GetComments(c, scid)
+
+	if err != nil {
+		return nil, cloudOptions{}, filterEnv{}, fmt.Errorf("could not get comments of %s: %w", scid, err)
+	}
+
+	env := threadFilterEnv(wordDoc, comments)
+	opts, err := svc.withPhrases(c, query.cloudOptions(filter), wordDoc, comments)
+
+	if err != nil {
+		return nil, cloudOptions{}, filterEnv{}, err
+	}
+
+	return newCommentTree(comments), opts, env, nil
+}
+
+// GetRedditSubthreadWords returns the cloud of a comment of a stored thread
+// and of all the replies below it.
+func (svc *service) GetRedditSubthreadWords(c context.Context, req *GetRedditSubthreadWordsReq) (*GetRedditSubthreadWordsRes, error) {
+	tree, opts, env, err := svc.threadTree(c, req.Scid, req.ThreadCloudQuery)
+
+	if err != nil {
+		return nil, err
+	}
+
+	root, ok := tree.comments[req.CommentId]
+
+	if !ok {
+		return nil, fmt.Errorf("could not get subthread %s of %s: %w", req.CommentId, req.Scid, ErrCommentNotFound)
+	}
+
+	sub, words, facets := tree.subthread(root, opts, env)
+
+	return &GetRedditSubthreadWordsRes{
+		Link:           req.Scid,
+		Subthread:      sub,
+		Words:          words,
+		VocabularySize: len(words),
+		Facets:         facets.ranked(),
+		Success:        true,
+	}, nil
+}
+
+// GetRedditThreadBranches summarizes the subthread of every top-level comment
+// of a stored thread, largest first by default.
+func (svc *service) GetRedditThreadBranches(c context.Context, req *GetRedditThreadBranchesReq) (*GetRedditThreadBranchesRes, error) {
+	tree, opts, env, err := svc.threadTree(c, req.Scid, req.ThreadCloudQuery)
+
+	if err != nil {
+		return nil, err
+	}
+
+	topWords := req.TopWords
+	if topWords == 0 {
+		topWords = defaultBranchTopWords
+	}
+
+	roots := tree.topLevel()
+	branches := make([]Branch, len(roots))
+	for i, root := range roots {
+		sub, words, _ := tree.subthread(root, opts, env)
+		ranked := rankWords(words, nil)
+		if len(ranked) > topWords {
+			ranked = ranked[:topWords]
+		}
+		branches[i] = Branch{Subthread: sub, Words: ranked}
+	}
+
+	sortBranches(branches, req.Sort)
+
+	return &GetRedditThreadBranchesRes{
+		Link:     req.Scid,
+		Branches: branches,
+		Success:  true,
+	}, nil
+}
+
+// sortBranches orders branches by size, score or creation, ties broken by comment id.
+func sortBranches(branches []Branch, by string) {
+	sort.Slice(branches, func(i, j int) bool {
+		a, b := branches[i], branches[j]
+		switch by {
+		case branchSortScore:
+			if a.Score != b.Score {
+				return a.Score > b.Score
+			}
+		case branchSortCreated:
+			if !a.Created.Equal(b.Created) {
+				return a.Created.Before(b.Created)
+			}
+		default:
+			if a.Comments != b.Comments {
+				return a.Comments > b.Comments
+			}
+			if a.TotalTokens != b.TotalTokens {
+				return a.TotalTokens > b.TotalTokens
+			}
+		}
+		return a.CommentId < b.CommentId
+	})
+}
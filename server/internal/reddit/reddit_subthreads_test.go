@@ -0,0 +1,104 @@
+package reddit
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSubthread(t *testing.T) {
+	comments := []CommentDocument{
+		{CommentId: "a", ParentId: "t3_post", SubredditAndCommentId: "r/golang/comments/post", Permalink: "/r/golang/comments/post/_/a/", Words: map[string]int{"go": 1}},
+		{CommentId: "b", ParentId: "t1_a", SubredditAndCommentId: "r/golang/comments/post", Words: map[string]int{"go": 1, "rust": 1}},
+		{CommentId: "c", ParentId: "t1_b", SubredditAndCommentId: "r/golang/comments/post", Words: map[string]int{"zig": 2}},
+		{CommentId: "d", ParentId: "t1_a", SubredditAndCommentId: "r/golang/comments/post", Words: map[string]int{"spam": 1}, Flag: "bot"},
+		{CommentId: "e", ParentId: "t3_post", SubredditAndCommentId: "r/golang/comments/post", Words: map[string]int{"python": 1}},
+		// f and g reply to each other in a corrupt tree.
+		{CommentId: "f", ParentId: "t1_g", SubredditAndCommentId: "r/golang/comments/post", Words: map[string]int{"loop": 1}},
+		{CommentId: "g", ParentId: "t1_f", SubredditAndCommentId: "r/golang/comments/post", Words: map[string]int{"loop": 1}},
+	}
+	tree := newCommentTree(comments)
+
+	tests := []struct {
+		name      string
+		root      string
+		wantSub   Subthread
+		wantWords map[string]int
+	}{
+		{
+			name: "replies",
+			root: "a",
+			wantSub: Subthread{
+				CommentId: "a", Permalink: "https://www.reddit.com/r/golang/comments/post/_/a/",
+				Comments: 4, Counted: 3, Depth: 2, TotalTokens: 5,
+			},
+			wantWords: map[string]int{"go": 2, "rust": 1, "zig": 2},
+		},
+		{
+			name: "comment stored without a permalink",
+			root: "e",
+			wantSub: Subthread{
+				CommentId: "e", Permalink: "https://www.reddit.com/r/golang/comments/post/_/e/",
+				Comments: 1, Counted: 1, Depth: 0, TotalTokens: 1,
+			},
+			wantWords: map[string]int{"python": 1},
+		},
+		{
+			name: "cycle",
+			root: "f",
+			wantSub: Subthread{
+				CommentId: "f", Permalink: "https://www.reddit.com/r/golang/comments/post/_/f/",
+				Comments: 2, Counted: 2, Depth: 1, TotalTokens: 2,
+			},
+			wantWords: map[string]int{"loop": 2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub, words, _ := tree.subthread(tree.comments[tt.root], cloudOptions{}, filterEnv{})
+			sub.Created = time.Time{}
+
+			if sub != tt.wantSub {
+				t.Errorf("subthread() = %+v, want %+v", sub, tt.wantSub)
+			}
+			if !reflect.DeepEqual(words, tt.wantWords) {
+				t.Errorf("subthread() words = %v, want %v", words, tt.wantWords)
+			}
+		})
+	}
+}
+
+func TestSortBranches(t *testing.T) {
+	day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	branches := []Branch{
+		{Subthread: Subthread{CommentId: "a", Comments: 2, TotalTokens: 5, Score: 10, Created: day.Add(2 * time.Hour)}},
+		{Subthread: Subthread{CommentId: "b", Comments: 3, TotalTokens: 1, Score: 1, Created: day}},
+		{Subthread: Subthread{CommentId: "c", Comments: 2, TotalTokens: 9, Score: 10, Created: day.Add(time.Hour)}},
+	}
+
+	tests := []struct {
+		name string
+		by   string
+		want []string
+	}{
+		{name: "size", by: "", want: []string{"b", "c", "a"}},
+		{name: "score", by: branchSortScore, want: []string{"a", "c", "b"}},
+		{name: "created", by: branchSortCreated, want: []string{"b", "c", "a"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sorted := append([]Branch(nil), branches...)
+			sortBranches(sorted, tt.by)
+
+			var got []string
+			for _, branch := range sorted {
+				got = append(got, branch.CommentId)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("sortBranches(%q) = %v, want %v", tt.by, got, tt.want)
+			}
+		})
+	}
+}
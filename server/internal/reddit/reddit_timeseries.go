@@ -0,0 +1,248 @@
+package reddit
+
+import (
+	"context"
+	"fmt"
+	"redditwordcloud/pkg/language"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+)
+
+const (
+	minInterval = time.Minute
+	// maxBuckets is the most buckets of a series, intervals are widened to fit.
+	maxBuckets = 1000
+	// targetBuckets is about the number of buckets of a series of the default interval.
+	targetBuckets        = 60
+	defaultSeriesWords   = 5
+	defaultBaseline      = 6
+	defaultTrendingWords = 10
+)
+
+// intervals are the default intervals, the narrowest making about
+// targetBuckets buckets is used.
+var intervals = []time.Duration{
+	time.Minute,
+	5 * time.Minute,
+	15 * time.Minute,
+	30 * time.Minute,
+	time.Hour,
+	3 * time.Hour,
+	6 * time.Hour,
+	12 * time.Hour,
+	24 * time.Hour,
+	7 * 24 * time.Hour,
+}
+
+func ValidateInterval(fl validator.FieldLevel) bool {
+	d, err := time.ParseDuration(fl.Field().String())
+	return err == nil && d >= minInterval
+}
+
+// GetRedditThreadTimeseries counts the words of the stored comments of a
+// thread in buckets of time by their creation, and ranks the words trending
+// in the latest bucket.
+func (svc *service) GetRedditThreadTimeseries(c context.Context, req *GetRedditThreadTimeseriesReq) (*GetRedditThreadTimeseriesRes, error) {
+	wordDoc, err := svc.Repository.GetWordsFromLink(c, req.Scid)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not get words of %s: %w", req.Scid, err)
+	}
+
+	if wordDoc == nil {
+		return nil, fmt.Errorf("could not get timeseries of %s: %w", req.Scid, ErrThreadNotFound)
+	}
+
+	filter, err := parseFilter(req.Filter)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not parse filter %q: %w", req.Filter, err)
+	}
+
+	comments, err := svc.Repository.GetComments(c, req.Scid)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not get comments of %s: %w", req.Scid, err)
+	}
+
+	env := threadFilterEnv(wordDoc, comments)
+	opts, err := svc.withPhrases(c, req.cloudOptions(filter), wordDoc, comments)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var counted []*CommentDocument
+	var first, last time.Time
+	for i := range comments {
+		comment := &comments[i]
+		if !opts.counts(comment, env) {
+			continue
+		}
+		created := comment.CreatedUTC.Time()
+		if len(counted) == 0 || created.Before(first) {
+			first = created
+		}
+		if len(counted) == 0 || created.After(last) {
+			last = created
+		}
+		counted = append(counted, comment)
+	}
+
+	interval := seriesInterval(req.Interval, last.Sub(first))
+	// Buckets are aligned on multiples of the interval, the same comments
+	// fall in the same buckets however the thread grows.
+	start := first.Truncate(interval)
+	n := 0
+	if len(counted) != 0 {
+		n = int(last.Sub(start)/interval) + 1
+	}
+
+	buckets := make([]TimeBucket, n)
+	bucketWords := make([]map[string]int, n)
+	for i := range buckets {
+		buckets[i].Start = start.Add(time.Duration(i) * interval).UTC()
+		bucketWords[i] = make(map[string]int)
+	}
+
+	total := make(map[string]int)
+	for _, comment := range counted {
+		i := int(comment.CreatedUTC.Time().Sub(start) / interval)
+		buckets[i].Comments++
+		for word, count := range opts.words(comment) {
+			bucketWords[i][word] += count
+			buckets[i].Tokens += count
+			total[word] += count
+		}
+	}
+
+	words := req.seriesWords(opts, wordDoc)
+	if len(words) == 0 {
+		for _, w := range rankWords(total, nil)[:min(defaultSeriesWords, len(total))] {
+			words = append(words, w.Word)
+		}
+	}
+
+	series := make([]WordSeries, len(words))
+	for i, word := range words {
+		series[i] = WordSeries{Word: word, Counts: make([]int, n), Total: total[word]}
+		for j := range bucketWords {
+			series[i].Counts[j] = bucketWords[j][word]
+		}
+	}
+
+	return &GetRedditThreadTimeseriesRes{
+		Link:     req.Scid,
+		Interval: formatInterval(interval),
+		Buckets:  buckets,
+		Series:   series,
+		Trending: req.trending(bucketWords),
+		Success:  true,
+	}, nil
+}
+
+// seriesWords returns the words of the query in the form they are counted
+// in by opts.
+func (req *GetRedditThreadTimeseriesReq) seriesWords(opts cloudOptions, wordDoc *WordDocument) []string {
+	var words []string
+	seen := make(map[string]bool)
+
+	for _, word := range strings.Split(req.Words, ",") {
+		word = strings.TrimSpace(word)
+		// Names keep their case when phrases are counted, other words never do.
+		if !opts.phrases {
+			word = strings.ToLower(word)
+		}
+		if opts.stem {
+			word = language.Stem(dominantLanguage(wordDoc.Languages), word)
+		}
+		if word != "" && !seen[word] {
+			seen[word] = true
+			words = append(words, word)
+		}
+	}
+
+	return words
+}
+
+// trending ranks the words of the latest bucket by their log-likelihood
+// against the baseline buckets before it, keeping the words more frequent
+// in the latest bucket.
+func (req *GetRedditThreadTimeseriesReq) trending(bucketWords []map[string]int) []TrendingWord {
+	trending := []TrendingWord{}
+	if len(bucketWords) < 2 {
+		return trending
+	}
+
+	baselineBuckets := req.Baseline
+	if baselineBuckets == 0 {
+		baselineBuckets = defaultBaseline
+	}
+	top := req.Top
+	if top == 0 {
+		top = defaultTrendingWords
+	}
+
+	latest := bucketWords[len(bucketWords)-1]
+	baseline := make(map[string]int)
+	for _, words := range bucketWords[max(0, len(bucketWords)-1-baselineBuckets) : len(bucketWords)-1] {
+		for word, count := range words {
+			baseline[word] += count
+		}
+	}
+
+	// The latest bucket and its baseline make up the background it is scored against.
+	bg := &Background{Tokens: countTokens(latest) + countTokens(baseline), TermFrequency: make(map[string]int)}
+	for word, count := range baseline {
+		bg.TermFrequency[word] += count
+	}
+	for word, count := range latest {
+		bg.TermFrequency[word] += count
+	}
+
+	for _, w := range rankWords(latest, logLikelihoods(latest, bg)) {
+		if w.Score <= 0 || len(trending) == top {
+			break
+		}
+		trending = append(trending, TrendingWord{RankedWord: w, Baseline: baseline[w.Word]})
+	}
+
+	return trending
+}
+
+// seriesInterval returns the interval of a series spanning span, the
+// requested one if any, widened to at most maxBuckets buckets.
+func seriesInterval(requested string, span time.Duration) time.Duration {
+	interval, err := time.ParseDuration(requested)
+
+	if err != nil || interval < minInterval {
+		interval = intervals[len(intervals)-1]
+		for _, d := range intervals {
+			if span/d < targetBuckets {
+				interval = d
+				break
+			}
+		}
+	}
+
+	// The first and the last buckets may only partly overlap the span.
+	if span/interval+2 > maxBuckets {
+		interval = (span/(maxBuckets-2) + time.Minute).Truncate(time.Minute)
+	}
+
+	return interval
+}
+
+// formatInterval formats d without its zero minutes and seconds, 1h rather than 1h0m0s.
+func formatInterval(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
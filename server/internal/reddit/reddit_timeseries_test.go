@@ -0,0 +1,125 @@
+package reddit
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSeriesInterval(t *testing.T) {
+	tests := []struct {
+		name      string
+		requested string
+		span      time.Duration
+		want      time.Duration
+	}{
+		{name: "empty thread", span: 0, want: time.Minute},
+		{name: "short thread", span: 30 * time.Minute, want: time.Minute},
+		{name: "default interval", span: 2 * time.Hour, want: 5 * time.Minute},
+		{name: "targetBuckets buckets", span: time.Hour, want: 5 * time.Minute},
+		{name: "widest default interval", span: 2000 * 24 * time.Hour, want: 7 * 24 * time.Hour},
+		{name: "requested", requested: "15m", span: time.Hour, want: 15 * time.Minute},
+		{name: "requested below the minimum", requested: "30s", span: 30 * time.Minute, want: time.Minute},
+		{name: "requested invalid", requested: "soon", span: 2 * time.Hour, want: 5 * time.Minute},
+		{name: "requested widened to maxBuckets", requested: "1m", span: 48 * time.Hour, want: 3 * time.Minute},
+		{name: "default widened to maxBuckets", span: 7000 * 24 * time.Hour, want: 168*time.Hour + 21*time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := seriesInterval(tt.requested, tt.span)
+			if got != tt.want {
+				t.Errorf("seriesInterval(%q, %v) = %v, want %v", tt.requested, tt.span, got, tt.want)
+			}
+			if buckets := tt.span/got + 2; buckets > maxBuckets {
+				t.Errorf("seriesInterval(%q, %v) makes %d buckets, want at most %d", tt.requested, tt.span, buckets, maxBuckets)
+			}
+		})
+	}
+}
+
+func TestFormatInterval(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{d: time.Minute, want: "1m"},
+		{d: 15 * time.Minute, want: "15m"},
+		{d: time.Hour, want: "1h"},
+		{d: 90 * time.Minute, want: "1h30m"},
+		{d: 7 * 24 * time.Hour, want: "168h"},
+		{d: 90 * time.Second, want: "1m30s"},
+		{d: time.Hour + 30*time.Second, want: "1h0m30s"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := formatInterval(tt.d); got != tt.want {
+				t.Errorf("formatInterval(%v) = %q, want %q", tt.d, got, tt.want)
+			}
+			if d, err := time.ParseDuration(formatInterval(tt.d)); err != nil || d != tt.d {
+				t.Errorf("ParseDuration(formatInterval(%v)) = %v, %v", tt.d, d, err)
+			}
+		})
+	}
+}
+
+func TestTrending(t *testing.T) {
+	buckets := []map[string]int{
+		{"zig": 10},
+		{"go": 5, "rust": 5},
+		{"go": 5, "rust": 5},
+		{"go": 1, "zig": 6, "odin": 3},
+	}
+
+	tests := []struct {
+		name    string
+		req     GetRedditThreadTimeseriesReq
+		buckets []map[string]int
+		want    []TrendingWord
+	}{
+		{
+			name:    "no baseline",
+			buckets: buckets[3:],
+			want:    []TrendingWord{},
+		},
+		{
+			name:    "default baseline",
+			buckets: buckets,
+			want: []TrendingWord{
+				{RankedWord: RankedWord{Rank: 1, Word: "odin", Count: 3}},
+				{RankedWord: RankedWord{Rank: 2, Word: "zig", Count: 6}, Baseline: 10},
+			},
+		},
+		{
+			name:    "baseline buckets",
+			req:     GetRedditThreadTimeseriesReq{Baseline: 2},
+			buckets: buckets,
+			want: []TrendingWord{
+				{RankedWord: RankedWord{Rank: 1, Word: "zig", Count: 6}},
+				{RankedWord: RankedWord{Rank: 2, Word: "odin", Count: 3}},
+			},
+		},
+		{
+			name:    "top",
+			req:     GetRedditThreadTimeseriesReq{Baseline: 2, Top: 1},
+			buckets: buckets,
+			want:    []TrendingWord{{RankedWord: RankedWord{Rank: 1, Word: "zig", Count: 6}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.req.trending(tt.buckets)
+			for i := range got {
+				if got[i].Score <= 0 {
+					t.Errorf("trending()[%d] = %+v, want a positive score", i, got[i])
+				}
+				got[i].Score = 0
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("trending() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package reddit
+
+import (
+	"context"
+	"fmt"
+	"redditwordcloud/pkg/topics"
+	"sort"
+)
+
+// GetRedditThreadTopics fits a topic model to the stored comments of a
+// thread, each comment a document, and returns the words of every topic and
+// the dominant topic of every comment.
+func (svc *service) GetRedditThreadTopics(c context.Context, req *GetRedditThreadTopicsReq) (*GetRedditThreadTopicsRes, error) {
+	wordDoc, err := svc.Repository.GetWordsFromLink(c, req.Scid)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not get words of %s: %w", req.Scid, err)
+	}
+
+	if wordDoc == nil {
+		return nil, fmt.Errorf("could not get topics of %s: %w", req.Scid, ErrThreadNotFound)
+	}
+
+	filter, err := parseFilter(req.Filter)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not parse filter %q: %w", req.Filter, err)
+	}
+
+	// Models are fitted again once the thread or the request changes.
+	key := fmt.Sprintf("%s|%d|%d|%s|%d|%d|%d|%d", req.Scid, wordDoc.LastCrawled, wordDoc.LastUpdated,
+		req.ThreadCloudQuery.cloudOptions(filter).fingerprint(), req.Topics, req.Seed, req.Iterations, req.TopWords)
+	crawling := svc.crawling.Has(req.Scid)
+
+	if res, ok := svc.topics.Get(key); ok && !crawling {
+		return res, nil
+	}
+
+	units, err := svc.commentUnits(c, wordDoc, req.ThreadCloudQuery, graphLevelComment)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// The comments are modeled in the same order, and the same are left out by
+	// the caps of the model, however they were stored.
+	sort.SliceStable(units, func(i, j int) bool { return units[i].commentId < units[j].commentId })
+
+	model, err := topics.LDA(c, unitWords(units), topics.Options{
+		Topics:     req.Topics,
+		Iterations: req.Iterations,
+		Seed:       req.Seed,
+		TopWords:   req.TopWords,
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("could not model topics of %s: %w", req.Scid, err)
+	}
+
+	comments := make([]CommentTopic, len(units))
+	for i, unit := range units {
+		comments[i] = CommentTopic{CommentId: unit.commentId, Assignment: model.Documents[i]}
+	}
+
+	res := &GetRedditThreadTopicsRes{
+		Link:       req.Scid,
+		Topics:     model.Topics,
+		Comments:   comments,
+		Vocabulary: model.Vocabulary,
+		Success:    true,
+	}
+
+	// A model of a thread being crawled is fitted to part of its comments.
+	if !crawling {
+		svc.topics.Set(key, res)
+	}
+
+	return res, nil
+}
+
+// topicsCost approximates the bytes of res kept in the cache.
+func topicsCost(res *GetRedditThreadTopicsRes) int {
+	cost := 64 * len(res.Comments)
+	for _, topic := range res.Topics {
+		cost += 32 * (len(topic.Counts) + len(topic.Words))
+	}
+	return cost
+}
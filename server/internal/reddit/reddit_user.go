@@ -0,0 +1,216 @@
+package reddit
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"go.uber.org/zap"
+)
+
+const (
+	// userKeyPrefix namespaces the documents of user histories, thread scids start with "r/".
+	userKeyPrefix = "u/"
+	// maxUserHistoryPages bounds a history crawl, Reddit serves at most 1000 items of a listing.
+	maxUserHistoryPages = 10
+	userHistoryPageSize = 100
+)
+
+var usernameRegexp = regexp.MustCompile(`^(?:/?u/)?[A-Za-z0-9_-]{3,20}$`)
+
+func ValidateUsername(fl validator.FieldLevel) bool {
+	return usernameRegexp.MatchString(fl.Field().String())
+}
+
+// userKey returns the key of the documents of a user's comment history.
+func userKey(username string) string {
+	return userKeyPrefix + strings.ToLower(strings.TrimPrefix(strings.TrimPrefix(username, "/"), "u/"))
+}
+
+// GetRedditUserWords returns the cloud of a user's comment history. The whole
+// history is crawled and stored, the subreddit and date range filters are
+// applied to the stored comments.
+func (svc *service) GetRedditUserWords(c context.Context, req *GetRedditUserWordsReq) (*GetRedditUserWordsRes, error) {
+	key := userKey(req.Username)
+	username := strings.TrimPrefix(key, userKeyPrefix)
+	opts := newFreshnessOptions(req.ForceRefresh, req.MaxAge)
+
+	wordDocument, state, err := svc.cachedWords(c, key, opts, nil, func() { svc.refreshUser(username) })
+
+	if err == nil && state == expired {
+		_, err = svc.crawlUser(username)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("could not get comments of user %s: %w", username, err)
+	}
+
+	res := &GetRedditUserWordsRes{
+		Username:   username,
+		Subreddit:  req.Subreddit,
+		From:       req.From,
+		To:         req.To,
+		Success:    true,
+		Stale:      state == stale,
+		Refreshing: state == expired || svc.crawling.Has(key),
+	}
+
+	if state == expired {
+		return res, nil
+	}
+
+	lastCrawled := wordDocument.crawledAt()
+	res.LastCrawled = &lastCrawled
+
+	if req.Subreddit == "" && req.From == nil && req.To == nil && !req.withStats {
+		res.Words = wordDocument.Words
+		res.Facets = wordDocument.Facets.ranked()
+	} else {
+		comments, err := svc.Repository.GetComments(c, key)
+
+		if err != nil {
+			return nil, fmt.Errorf("could not get comments of user %s: %w", username, err)
+		}
+
+		res.Words = make(map[string]int)
+		res.documentFrequencies = make(map[string]int)
+		facets := make(Facets)
+		for _, comment := range comments {
+			if !userCommentMatches(comment, req) {
+				continue
+			}
+			for word, count := range comment.Words {
+				res.Words[word] += count
+				res.documentFrequencies[word]++
+			}
+			facets.addAll(comment.Facets, 1)
+		}
+		res.Facets = facets.ranked()
+	}
+
+	res.VocabularySize = len(res.Words)
+	res.TotalTokens = countTokens(res.Words)
+
+	if res.List, err = req.WordListQuery.listWords(res.Words, req.withStats); err != nil {
+		return nil, err
+	}
+	if res.List != nil {
+		res.Words = nil
+	}
+
+	return res, nil
+}
+
+func userCommentMatches(comment CommentDocument, req *GetRedditUserWordsReq) bool {
+	if comment.Flag != "" {
+		return false
+	}
+
+	if req.Subreddit != "" && !strings.EqualFold(subredditPrefix(comment.Subreddit), subredditPrefix(req.Subreddit)) {
+		return false
+	}
+
+	created := comment.CreatedUTC.Time()
+
+	if req.From != nil && created.Before(*req.From) {
+		return false
+	}
+
+	if req.To != nil && created.After(*req.To) {
+		return false
+	}
+
+	return true
+}
+
+// refreshUser crawls a user's history in the background of a request.
+func (svc *service) refreshUser(username string) {
+	if _, err := svc.crawlUser(username); err != nil {
+		zap.S().Errorf("could not refresh user %s: %w", username, err)
+	}
+}
+
+// crawlUser fetches the comment history of a user, following the after
+// cursors of the listing, and processes it in the background. Like threads,
+// only new or edited comments are counted and one crawl of a user runs at a
+// time; false is returned when one is already underway.
+func (svc *service) crawlUser(username string) (bool, error) {
+	key := userKey(username)
+
+	if !svc.crawling.SetIfAbsent(key, time.Now()) {
+		zap.S().Debugf("Crawl of %s already underway.", key)
+		return false, nil
+	}
+
+	path := fmt.Sprintf("user/%s/comments", username)
+	params := url.Values{}
+	params.Add("limit", strconv.Itoa(userHistoryPageSize))
+	params.Add("sort", "new")
+
+	comments, after, err := svc.getListing(path, params)
+
+	if err != nil {
+		svc.crawling.Remove(key)
+		return false, err
+	}
+
+	// The request's context ends with the response, the crawl outlives it.
+	ctx := context.Background()
+
+	cr, err := svc.startCrawl(ctx, key, nil)
+
+	if err != nil {
+		svc.crawling.Remove(key)
+		return false, err
+	}
+
+	// The freshness of a history follows the user's latest activity, like the
+	// freshness of a thread follows its creation.
+	var lastActive time.Time
+	if len(comments) != 0 {
+		lastActive = time.Unix(int64(comments[0].CreatedUTC), 0)
+	}
+
+	if err := svc.Repository.MarkCrawled(ctx, key, time.Now(), lastActive); err != nil {
+		zap.S().Errorf("Could not mark %s as crawled: %w", key, err)
+	}
+
+	go func() {
+		defer svc.crawling.Remove(key)
+
+		for page := 1; ; page++ {
+			b := newBatch()
+			for _, comment := range comments {
+				cr.count(comment, b)
+			}
+			svc.commitBatch(ctx, b, cr)
+
+			if after == "" || page == maxUserHistoryPages {
+				break
+			}
+
+			params.Set("after", after)
+			if comments, after, err = svc.getListing(path, params); err != nil {
+				zap.S().Errorf("Could not get page %d of the history of %s: %w", page+1, username, err)
+				cr.failed.Store(true)
+				break
+			}
+		}
+
+		// Comments past the last page are not vanished, they are only out of reach.
+		if !cr.failed.Load() && after == "" {
+			svc.commitBatch(ctx, cr.vanished(), cr)
+			svc.commitBatch(ctx, cr.duplicates(), cr)
+		}
+		svc.endCrawl(ctx, cr)
+
+		zap.S().Debugf("Finished crawl of %s.", key)
+	}()
+
+	return true, nil
+}
@@ -0,0 +1,108 @@
+package reddit
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestUserCommentMatches(t *testing.T) {
+	created := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	before := created.Add(-time.Hour)
+	after := created.Add(time.Hour)
+	comment := CommentDocument{Subreddit: "golang", CreatedUTC: primitive.NewDateTimeFromTime(created)}
+
+	tests := []struct {
+		name    string
+		comment CommentDocument
+		req     GetRedditUserWordsReq
+		want    bool
+	}{
+		{name: "no filters", comment: comment, want: true},
+		{name: "subreddit", comment: comment, req: GetRedditUserWordsReq{Subreddit: "golang"}, want: true},
+		{name: "prefixed subreddit in another case", comment: comment, req: GetRedditUserWordsReq{Subreddit: "r/GoLang"}, want: true},
+		{name: "other subreddit", comment: comment, req: GetRedditUserWordsReq{Subreddit: "rust"}, want: false},
+		{name: "within range", comment: comment, req: GetRedditUserWordsReq{From: &before, To: &after}, want: true},
+		{name: "on the bounds", comment: comment, req: GetRedditUserWordsReq{From: &created, To: &created}, want: true},
+		{name: "before from", comment: comment, req: GetRedditUserWordsReq{From: &after}, want: false},
+		{name: "after to", comment: comment, req: GetRedditUserWordsReq{To: &before}, want: false},
+		{
+			name:    "flagged comment",
+			comment: CommentDocument{Subreddit: "golang", CreatedUTC: comment.CreatedUTC, Flag: flagDuplicate},
+			want:    false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := userCommentMatches(tt.comment, &tt.req); got != tt.want {
+				t.Errorf("userCommentMatches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCrawlUserVanished(t *testing.T) {
+	const path = "/user/alice/comments"
+	key := userKey("alice")
+
+	// history returns the pages of a history whose last page is followed by last.
+	history := func(pages int, last string) fakeReddit {
+		reddit := fakeReddit{}
+		for page := 1; page <= pages; page++ {
+			key, after := path, fmt.Sprintf("p%d", page)
+			if page > 1 {
+				key = fmt.Sprintf("%s?after=p%d", path, page-1)
+			}
+			if page == pages {
+				after = last
+			}
+			comment := &RedditRepliesObject{Id: fmt.Sprintf("c%d", page), Subreddit: "golang", Body: fmt.Sprintf("comment number %d", page), CreatedUTC: float64(1700000000 - page)}
+			reddit[key] = listingResponse(after, "t1", comment)
+		}
+		return reddit
+	}
+
+	tests := []struct {
+		name         string
+		reddit       fakeReddit
+		wantVanished bool
+	}{
+		{name: "whole history", reddit: history(2, ""), wantVanished: true},
+		{name: "history truncated at the last page", reddit: history(maxUserHistoryPages, "more")},
+		{name: "page that failed", reddit: history(2, "missing")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newFakeRepository()
+			repo.addWords(WordDocument{SubredditAndCommentId: key, Words: map[string]int{"old": 1}})
+			repo.addComments(CommentDocument{SubredditAndCommentId: key, CommentId: "old", Body: "old", BodyHash: bodyHash("old"), Words: map[string]int{"old": 1}, Tokenizer: tokenizerVersion})
+			svc := newTestService(repo, tt.reddit)
+
+			if started, err := svc.crawlUser("alice"); !started || err != nil {
+				t.Fatalf("crawlUser() = %v, %v, want a started crawl", started, err)
+			}
+			waitForCrawl(t, svc, key)
+
+			comments, _ := repo.GetComments(context.Background(), key)
+			var old CommentDocument
+			for _, comment := range comments {
+				if comment.CommentId == "old" {
+					old = comment
+				}
+			}
+			if vanished := old.Flag == flagDeleted; vanished != tt.wantVanished {
+				t.Errorf("old comment flagged %q, want vanished %v", old.Flag, tt.wantVanished)
+			}
+
+			wordDoc, _ := repo.GetWordsFromLink(context.Background(), key)
+			if counted := wordDoc.Words["old"] > 0; counted == tt.wantVanished {
+				t.Errorf("words = %v, want the old comment counted %v", wordDoc.Words, !tt.wantVanished)
+			}
+		})
+	}
+}
@@ -0,0 +1,410 @@
+package reddit
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+)
+
+/*
+A watched thread is crawled again and again until its watch expires, for live
+threads whose cloud changes by the minute. Watches are stored in the
+repository and polled by a scheduler running in the server, so they outlive
+restarts: the scheduler picks up the watches that came due while it was down
+on its first tick. Every poll is a regular crawl, which only counts the
+comments that are new or changed since the previous one.
+
+The time between two polls adapts to the activity of the thread. It is
+shortened while comments keep arriving, so that a poll finds about
+TargetComments new comments, and doubled whenever a poll finds none.
+
+At most MaxWatches threads are watched at once, and only by the clients
+holding the API key of the server.
+*/
+
+// maxWatchFailures is the number of polls in a row a thread can fail to be
+// crawled before its watch is dropped, such as a deleted thread.
+const maxWatchFailures = 5
+
+// WatchConfig sets how watched threads are polled.
+type WatchConfig struct {
+	// MinInterval and MaxInterval bound the time between two polls of a thread.
+	MinInterval time.Duration `env:"MIN_INTERVAL" envDefault:"30s"`
+	MaxInterval time.Duration `env:"MAX_INTERVAL" envDefault:"15m"`
+	// TargetComments is the number of new comments a poll aims to find.
+	TargetComments int `env:"TARGET_COMMENTS" envDefault:"25"`
+	// DefaultDuration is how long a thread is watched when no duration is
+	// requested, MaxDuration the longest it can be.
+	DefaultDuration time.Duration `env:"DEFAULT_DURATION" envDefault:"6h"`
+	MaxDuration     time.Duration `env:"MAX_DURATION" envDefault:"72h"`
+	// Tick is how often the scheduler looks for the watches due for a poll.
+	Tick time.Duration `env:"TICK" envDefault:"10s"`
+	// MaxWatches is the most threads watched at once, each polled by a crawl of its own.
+	MaxWatches int `env:"MAX_WATCHES" envDefault:"50"`
+}
+
+// nextInterval returns the time until the next poll of a watch whose last
+// poll found arrived new comments, elapsed after the one before.
+func (cfg WatchConfig) nextInterval(interval time.Duration, elapsed time.Duration, arrived int) time.Duration {
+	next := 2 * interval
+	if arrived > 0 {
+		// The interval that would have found TargetComments comments at the rate they arrived.
+		next = min(next, time.Duration(float64(elapsed)*float64(cfg.TargetComments)/float64(arrived)))
+	}
+	return max(cfg.MinInterval, min(cfg.MaxInterval, next))
+}
+
+func (watch *WatchDocument) link() *Link {
+	return &Link{
+		Protocol:   "https:/",
+		DomainName: "www.reddit.com",
+		Subreddit:  watch.Subreddit,
+		CommentId:  watch.CommentId,
+	}
+}
+
+func (watch *WatchDocument) response() Watch {
+	w := Watch{
+		Link:     watch.SubredditAndCommentId,
+		Created:  watch.Created.Time(),
+		Expires:  watch.Expires.Time(),
+		Interval: int(watch.Interval / time.Second),
+		NextPoll: watch.NextPoll.Time(),
+		Polls:    watch.Polls,
+		Comments: watch.Comments,
+	}
+	if watch.LastPolled != 0 {
+		lastPolled := watch.LastPolled.Time()
+		w.LastPolled = &lastPolled
+	}
+	return w
+}
+
+// WatchRedditThread watches a thread, polled first on the next tick of the
+// scheduler. Watching a watched thread extends its watch and polls it again
+// at the shortest interval.
+func (svc *service) WatchRedditThread(c context.Context, req *WatchRedditThreadReq) (*WatchRedditThreadRes, error) {
+	link := createLink(req.Link)
+	scid := link.scid()
+
+	// Watches are counted and saved under watchMu so that concurrent requests
+	// cannot exceed the limit.
+	svc.watchMu.Lock()
+	defer svc.watchMu.Unlock()
+
+	active, err := svc.activeWatches(c, scid)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if active >= svc.watch.MaxWatches {
+		return nil, fmt.Errorf("could not watch %s, %d threads are watched: %w", scid, active, ErrTooManyWatches)
+	}
+
+	wordDoc, err := svc.Repository.GetWordsFromLink(c, scid)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not get words of %s: %w", scid, err)
+	}
+
+	// Crawls merge into the WordDocument of the thread, it must exist before the first poll.
+	if wordDoc == nil {
+		if _, err := svc.Repository.InsertWords(c, make(map[string]int), scid); err != nil {
+			return nil, fmt.Errorf("could not insert words of %s: %w", scid, err)
+		}
+	}
+
+	// The comments already stored did not arrive while the thread was watched.
+	comments, err := svc.Repository.CountComments(c, scid)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not count comments of %s: %w", scid, err)
+	}
+
+	duration := svc.watch.DefaultDuration
+	if req.Duration != 0 {
+		duration = time.Duration(req.Duration) * time.Second
+	}
+	duration = min(duration, svc.watch.MaxDuration)
+
+	now := time.Now()
+	watch := &WatchDocument{
+		SubredditAndCommentId: scid,
+		Subreddit:             link.Subreddit,
+		CommentId:             link.CommentId,
+		Created:               primitive.NewDateTimeFromTime(now),
+		Expires:               primitive.NewDateTimeFromTime(now.Add(duration)),
+		Interval:              svc.watch.MinInterval,
+		NextPoll:              primitive.NewDateTimeFromTime(now),
+		Comments:              comments,
+	}
+
+	if err := svc.Repository.SaveWatch(c, watch); err != nil {
+		return nil, fmt.Errorf("could not watch %s: %w", scid, err)
+	}
+
+	return &WatchRedditThreadRes{Watch: watch.response(), Success: true}, nil
+}
+
+// activeWatches returns the number of watches of threads other than scid
+// that have not expired, watching a watched thread only extends its watch.
+func (svc *service) activeWatches(c context.Context, scid string) (int, error) {
+	watches, err := svc.Repository.GetWatches(c)
+
+	if err != nil {
+		return 0, fmt.Errorf("could not get watches: %w", err)
+	}
+
+	now := time.Now()
+	active := 0
+	for i := range watches {
+		if watches[i].SubredditAndCommentId != scid && now.Before(watches[i].Expires.Time()) {
+			active++
+		}
+	}
+
+	return active, nil
+}
+
+// UnwatchRedditThread stops watching a thread, its cloud is kept.
+func (svc *service) UnwatchRedditThread(c context.Context, req *UnwatchRedditThreadReq) (*WatchRedditThreadRes, error) {
+	watch, err := svc.Repository.DeleteWatch(c, req.Scid)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not unwatch %s: %w", req.Scid, err)
+	}
+
+	if watch == nil {
+		return nil, fmt.Errorf("could not unwatch %s: %w", req.Scid, ErrWatchNotFound)
+	}
+
+	return &WatchRedditThreadRes{Watch: watch.response(), Success: true}, nil
+}
+
+func (svc *service) GetRedditWatches(c context.Context) (*GetRedditWatchesRes, error) {
+	watches, err := svc.Repository.GetWatches(c)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not get watches: %w", err)
+	}
+
+	res := &GetRedditWatchesRes{Watches: make([]Watch, len(watches)), Success: true}
+	for i := range watches {
+		res.Watches[i] = watches[i].response()
+	}
+
+	return res, nil
+}
+
+func (svc *service) StartWatches(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(svc.watch.Tick)
+		defer ticker.Stop()
+
+		for {
+			svc.pollWatches(ctx, time.Now())
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// pollWatches polls the watches due at now, each in its own goroutine, and
+// returns once all of their crawls complete.
+func (svc *service) pollWatches(ctx context.Context, now time.Time) {
+	watches, err := svc.Repository.GetDueWatches(ctx, now)
+
+	if err != nil {
+		zap.S().Errorf("Could not get due watches: %w", err)
+		return
+	}
+
+	var wg sync.WaitGroup
+	for i := range watches {
+		wg.Add(1)
+		go func(watch *WatchDocument) {
+			defer wg.Done()
+			svc.pollWatch(ctx, watch, now)
+		}(&watches[i])
+	}
+	wg.Wait()
+}
+
+// pollWatch crawls a watched thread and schedules its next poll, or drops
+// its watch once it has expired. The comments that arrived since the last
+// poll are counted once the crawl completes.
+func (svc *service) pollWatch(ctx context.Context, watch *WatchDocument, now time.Time) {
+	scid := watch.SubredditAndCommentId
+
+	if now.After(watch.Expires.Time()) {
+		if _, err := svc.Repository.DeleteWatch(ctx, scid); err != nil {
+			zap.S().Errorf("Could not drop expired watch of %s: %w", scid, err)
+			return
+		}
+		zap.S().Infof("Watch of %s expired after %d polls.", scid, watch.Polls)
+		return
+	}
+
+	done, err := svc.startThreadCrawl(watch.link(), cloudOptions{})
+
+	if err != nil {
+		zap.S().Errorf("Could not poll watched thread %s: %w", scid, err)
+		watch.Failures++
+
+		if watch.Failures >= maxWatchFailures {
+			if _, err := svc.Repository.DeleteWatch(ctx, scid); err != nil {
+				zap.S().Errorf("Could not drop failing watch of %s: %w", scid, err)
+			}
+			zap.S().Infof("Watch of %s dropped after %d failed polls.", scid, watch.Failures)
+			return
+		}
+
+		svc.scheduleWatch(ctx, watch, now)
+		return
+	}
+
+	watch.Failures = 0
+
+	// The comments stored by a crawl of the thread already underway, such as
+	// one requested by a user, are counted by the next poll.
+	if done == nil {
+		svc.scheduleWatch(ctx, watch, now)
+		return
+	}
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return
+	}
+
+	comments, err := svc.Repository.CountComments(ctx, scid)
+
+	if err != nil {
+		zap.S().Errorf("Could not count comments of watched thread %s: %w", scid, err)
+		svc.scheduleWatch(ctx, watch, now)
+		return
+	}
+
+	since := watch.LastPolled
+	if since == 0 {
+		since = watch.Created
+	}
+
+	polled := time.Now()
+	watch.Interval = svc.watch.nextInterval(watch.Interval, polled.Sub(since.Time()), comments-watch.Comments)
+	watch.LastPolled = primitive.NewDateTimeFromTime(polled)
+	watch.Comments = comments
+	watch.Polls++
+
+	svc.scheduleWatch(ctx, watch, polled)
+}
+
+// scheduleWatch schedules the next poll of a watch an interval after now.
+func (svc *service) scheduleWatch(ctx context.Context, watch *WatchDocument, now time.Time) {
+	watch.NextPoll = primitive.NewDateTimeFromTime(now.Add(watch.Interval))
+
+	if err := svc.Repository.UpdateWatch(ctx, watch); err != nil {
+		zap.S().Errorf("Could not schedule the next poll of %s: %w", watch.SubredditAndCommentId, err)
+	}
+}
+
+// SaveWatch stores a watch, replacing the watch of its thread if there is one.
+func (r *repository) SaveWatch(ctx context.Context, watch *WatchDocument) error {
+	scid := watch.SubredditAndCommentId
+	defer r.nrc.Client.StartTransaction(fmt.Sprintf("%s: SaveWatch", scid)).End()
+	filter := bson.D{{Key: "scid", Value: scid}}
+
+	if _, err := r.watchesCollection.ReplaceOne(ctx, filter, watch, options.Replace().SetUpsert(true)); err != nil {
+		zap.S().Errorf("Error saving watch of %s to MongoDb: %w", scid, err)
+		return fmt.Errorf("could not save watch: %w", err)
+	}
+
+	return nil
+}
+
+// UpdateWatch updates the schedule of a watch. A watch deleted since it was
+// read stays deleted.
+func (r *repository) UpdateWatch(ctx context.Context, watch *WatchDocument) error {
+	scid := watch.SubredditAndCommentId
+	defer r.nrc.Client.StartTransaction(fmt.Sprintf("%s: UpdateWatch", scid)).End()
+	filter := bson.D{{Key: "scid", Value: scid}}
+
+	update := bson.M{"$set": bson.M{
+		"interval":    watch.Interval,
+		"next_poll":   watch.NextPoll,
+		"last_polled": watch.LastPolled,
+		"polls":       watch.Polls,
+		"comments":    watch.Comments,
+		"failures":    watch.Failures,
+	}}
+
+	if _, err := r.watchesCollection.UpdateOne(ctx, filter, update); err != nil {
+		zap.S().Errorf("Error updating watch of %s in MongoDb: %w", scid, err)
+		return fmt.Errorf("could not update watch: %w", err)
+	}
+
+	return nil
+}
+
+func (r *repository) GetWatches(ctx context.Context) ([]WatchDocument, error) {
+	defer r.nrc.Client.StartTransaction("GetWatches").End()
+
+	return r.findWatches(ctx, bson.D{})
+}
+
+// GetDueWatches returns the watches whose next poll is at or before now.
+func (r *repository) GetDueWatches(ctx context.Context, now time.Time) ([]WatchDocument, error) {
+	defer r.nrc.Client.StartTransaction("GetDueWatches").End()
+
+	return r.findWatches(ctx, bson.D{{Key: "next_poll", Value: bson.M{"$lte": primitive.NewDateTimeFromTime(now)}}})
+}
+
+func (r *repository) findWatches(ctx context.Context, filter bson.D) ([]WatchDocument, error) {
+	cursor, err := r.watchesCollection.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "next_poll", Value: 1}}))
+
+	if err != nil {
+		zap.S().Errorf("Error getting watches from MongoDb: %w", err)
+		return nil, err
+	}
+
+	watches := []WatchDocument{}
+
+	if err := cursor.All(ctx, &watches); err != nil {
+		zap.S().Errorf("Error decoding watches from MongoDb: %w", err)
+		return nil, err
+	}
+
+	return watches, nil
+}
+
+// DeleteWatch deletes the watch of a thread and returns it, nil if the
+// thread was not watched.
+func (r *repository) DeleteWatch(ctx context.Context, scid string) (*WatchDocument, error) {
+	defer r.nrc.Client.StartTransaction(fmt.Sprintf("%s: DeleteWatch", scid)).End()
+	filter := bson.D{{Key: "scid", Value: scid}}
+
+	var watch WatchDocument
+
+	if err := r.watchesCollection.FindOneAndDelete(ctx, filter).Decode(&watch); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		zap.S().Errorf("Error deleting watch of %s from MongoDb: %w", scid, err)
+		return nil, err
+	}
+
+	return &watch, nil
+}
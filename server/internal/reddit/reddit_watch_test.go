@@ -0,0 +1,35 @@
+package reddit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNextInterval(t *testing.T) {
+	cfg := WatchConfig{MinInterval: 30 * time.Second, MaxInterval: 15 * time.Minute, TargetComments: 25}
+
+	tests := []struct {
+		name     string
+		interval time.Duration
+		elapsed  time.Duration
+		arrived  int
+		want     time.Duration
+	}{
+		{name: "no comments doubles", interval: time.Minute, elapsed: time.Minute, arrived: 0, want: 2 * time.Minute},
+		{name: "doubled up to the max", interval: 10 * time.Minute, elapsed: 10 * time.Minute, arrived: 0, want: 15 * time.Minute},
+		{name: "target comments", interval: 2 * time.Minute, elapsed: 2 * time.Minute, arrived: 25, want: 2 * time.Minute},
+		{name: "few comments widen", interval: 2 * time.Minute, elapsed: 2 * time.Minute, arrived: 10, want: 4 * time.Minute},
+		{name: "many comments narrow", interval: 2 * time.Minute, elapsed: 2 * time.Minute, arrived: 50, want: time.Minute},
+		{name: "narrowed down to the min", interval: time.Minute, elapsed: time.Minute, arrived: 1000, want: 30 * time.Second},
+		{name: "rate over the elapsed time", interval: time.Minute, elapsed: 4 * time.Minute, arrived: 100, want: time.Minute},
+		{name: "comments removed", interval: time.Minute, elapsed: time.Minute, arrived: -3, want: 2 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cfg.nextInterval(tt.interval, tt.elapsed, tt.arrived); got != tt.want {
+				t.Errorf("nextInterval(%v, %v, %d) = %v, want %v", tt.interval, tt.elapsed, tt.arrived, got, tt.want)
+			}
+		})
+	}
+}
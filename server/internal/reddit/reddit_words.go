@@ -0,0 +1,189 @@
+package reddit
+
+import (
+	"encoding/base64"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/go-playground/validator/v10"
+)
+
+const defaultWordPageSize = 1000
+
+// RankedWord is a word of a cloud with its rank, 1 for the most frequent or,
+// when the cloud is scored, the highest scored.
+type RankedWord struct {
+	Rank  int     `json:"rank"`
+	Word  string  `json:"word"`
+	Count int     `json:"count"`
+	Score float64 `json:"score,omitempty"`
+}
+
+// key is what words are ranked by.
+func (w RankedWord) key(scored bool) float64 {
+	if scored {
+		return w.Score
+	}
+	return float64(w.Count)
+}
+
+// WordListQuery lists the words of a cloud as a sorted array instead of a map,
+// filtered and paginated.
+type WordListQuery struct {
+	// Top keeps the most frequent words matching the other filters.
+	Top       int    `json:"top,omitempty" binding:"omitempty,min=1"`
+	MinCount  int    `json:"minCount,omitempty" binding:"omitempty,min=1"`
+	MinLength int    `json:"minLength,omitempty" binding:"omitempty,min=1,max=100"`
+	Prefix    string `json:"prefix,omitempty" binding:"omitempty,max=100"`
+	Pattern   string `json:"pattern,omitempty" binding:"omitempty,max=256,ValidateRegexp"`
+	// Cursor continues a list from the NextCursor of its previous page.
+	Cursor   string `json:"cursor,omitempty" binding:"omitempty,max=512"`
+	PageSize int    `json:"pageSize,omitempty" binding:"omitempty,min=1,max=10000"`
+}
+
+// WordList is a page of the words of a cloud selected by a WordListQuery.
+type WordList struct {
+	Words []RankedWord `json:"words"`
+	// Matching is the number of words matching the query, across all pages.
+	Matching int `json:"matching"`
+	// NextCursor is empty on the last page.
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+func ValidateRegexp(fl validator.FieldLevel) bool {
+	_, err := regexp.Compile(fl.Field().String())
+	return err == nil
+}
+
+func (q WordListQuery) isSet() bool {
+	return q != WordListQuery{}
+}
+
+// rankWords returns the words from the most to the least frequent, or from
+// the highest to the lowest scored if there are scores, ties broken
+// alphabetically.
+func rankWords(words map[string]int, scores map[string]float64) []RankedWord {
+	scored := scores != nil
+	ranked := make([]RankedWord, 0, len(words))
+	for word, count := range words {
+		ranked = append(ranked, RankedWord{Word: word, Count: count, Score: scores[word]})
+	}
+
+	sort.Slice(ranked, func(i, j int) bool {
+		return rankedBefore(ranked[i].key(scored), ranked[i].Word, ranked[j].key(scored), ranked[j].Word)
+	})
+
+	for i := range ranked {
+		ranked[i].Rank = i + 1
+	}
+
+	return ranked
+}
+
+func rankedBefore(key float64, word string, otherKey float64, otherWord string) bool {
+	if key != otherKey {
+		return key > otherKey
+	}
+	return word < otherWord
+}
+
+// listWords returns the page of words selected by q, nil when q is not set
+// or the words are exported, exports list every word.
+func (q WordListQuery) listWords(words map[string]int, export bool) (*WordList, error) {
+	if !q.isSet() || export {
+		return nil, nil
+	}
+	return q.list(words, nil)
+}
+
+// countTokens returns the number of occurrences of all the words.
+func countTokens(words map[string]int) int {
+	total := 0
+	for _, count := range words {
+		total += count
+	}
+	return total
+}
+
+// list returns the page of the words selected by q, ranked by scores if they
+// are not nil. Ranks are the ranks of the words in the whole cloud.
+func (q WordListQuery) list(words map[string]int, scores map[string]float64) (*WordList, error) {
+	scored := scores != nil
+
+	var pattern *regexp.Regexp
+	if q.Pattern != "" {
+		var err error
+		if pattern, err = regexp.Compile(q.Pattern); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", q.Pattern, err)
+		}
+	}
+
+	prefix := strings.ToLower(q.Prefix)
+	matching := []RankedWord{}
+
+	for _, w := range rankWords(words, scores) {
+		if w.Count < q.MinCount || utf8.RuneCountInString(w.Word) < q.MinLength ||
+			!strings.HasPrefix(w.Word, prefix) || (pattern != nil && !pattern.MatchString(w.Word)) {
+			continue
+		}
+		matching = append(matching, w)
+	}
+
+	if q.Top > 0 && len(matching) > q.Top {
+		matching = matching[:q.Top]
+	}
+
+	start := 0
+	if q.Cursor != "" {
+		key, word, err := decodeWordCursor(q.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		// The page starts after the last word of the previous one.
+		start = sort.Search(len(matching), func(i int) bool {
+			return rankedBefore(key, word, matching[i].key(scored), matching[i].Word)
+		})
+	}
+
+	pageSize := q.PageSize
+	if pageSize == 0 {
+		pageSize = defaultWordPageSize
+	}
+
+	end := min(start+pageSize, len(matching))
+	list := &WordList{Words: matching[start:end], Matching: len(matching)}
+
+	if end < len(matching) {
+		last := matching[end-1]
+		list.NextCursor = encodeWordCursor(last.key(scored), last.Word)
+	}
+
+	return list, nil
+}
+
+// Cursors hold the rank key, count or score, and the word of the last word of
+// a page, so pages stay consistent when the cloud changes between requests.
+func encodeWordCursor(key float64, word string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatFloat(key, 'g', -1, 64) + ":" + word))
+}
+
+func decodeWordCursor(cursor string) (float64, string, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+
+	if err != nil {
+		return 0, "", fmt.Errorf("could not decode cursor %q: %w", cursor, ErrInvalidCursor)
+	}
+
+	keyStr, word, ok := strings.Cut(string(b), ":")
+	key, err := strconv.ParseFloat(keyStr, 64)
+
+	if !ok || err != nil {
+		return 0, "", fmt.Errorf("could not decode cursor %q: %w", cursor, ErrInvalidCursor)
+	}
+
+	return key, word, nil
+}
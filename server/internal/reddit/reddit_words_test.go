@@ -0,0 +1,138 @@
+package reddit
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestRankWords(t *testing.T) {
+	words := map[string]int{"b": 2, "a": 2, "c": 5}
+
+	tests := []struct {
+		name   string
+		scores map[string]float64
+		want   []string
+	}{
+		{"by count, ties alphabetically", nil, []string{"c", "a", "b"}},
+		{"by score", map[string]float64{"a": 0.1, "b": 3, "c": 1}, []string{"b", "c", "a"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ranked := rankWords(words, tt.scores)
+			var got []string
+			for i, w := range ranked {
+				if w.Rank != i+1 {
+					t.Errorf("rank of %s = %d, want %d", w.Word, w.Rank, i+1)
+				}
+				got = append(got, w.Word)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("rankWords() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWordCursor(t *testing.T) {
+	tests := []struct {
+		key  float64
+		word string
+	}{
+		{12, "go"},
+		{0.125, "naïve"},
+		{3, "with:colon"},
+		{-1.5e-7, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.word, func(t *testing.T) {
+			key, word, err := decodeWordCursor(encodeWordCursor(tt.key, tt.word))
+			if err != nil || key != tt.key || word != tt.word {
+				t.Errorf("decode(encode(%v, %q)) = %v, %q, %v", tt.key, tt.word, key, word, err)
+			}
+		})
+	}
+
+	for _, cursor := range []string{"not base64!", "bm9jb2xvbg", "YWJjOmdv"} {
+		t.Run(cursor, func(t *testing.T) {
+			if _, _, err := decodeWordCursor(cursor); !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("decodeWordCursor(%q) error = %v, want ErrInvalidCursor", cursor, err)
+			}
+		})
+	}
+}
+
+func TestWordListQueryList(t *testing.T) {
+	words := map[string]int{"go": 10, "golang": 8, "gopher": 8, "rust": 5, "c": 3, "zig": 1}
+
+	tests := []struct {
+		name     string
+		query    WordListQuery
+		want     []string
+		matching int
+		more     bool
+	}{
+		{name: "every word", query: WordListQuery{Top: 100}, want: []string{"go", "golang", "gopher", "rust", "c", "zig"}, matching: 6},
+		{name: "top", query: WordListQuery{Top: 2}, want: []string{"go", "golang"}, matching: 2},
+		{name: "min count", query: WordListQuery{MinCount: 5}, want: []string{"go", "golang", "gopher", "rust"}, matching: 4},
+		{name: "min length", query: WordListQuery{MinLength: 4}, want: []string{"golang", "gopher", "rust"}, matching: 3},
+		{name: "prefix is lower-cased", query: WordListQuery{Prefix: "GO"}, want: []string{"go", "golang", "gopher"}, matching: 3},
+		{name: "pattern", query: WordListQuery{Pattern: "^[a-z]{1,3}$"}, want: []string{"go", "c", "zig"}, matching: 3},
+		{name: "page", query: WordListQuery{PageSize: 2}, want: []string{"go", "golang"}, matching: 6, more: true},
+		{name: "next page", query: WordListQuery{PageSize: 2, Cursor: encodeWordCursor(8, "golang")}, want: []string{"gopher", "rust"}, matching: 6, more: true},
+		{name: "last page", query: WordListQuery{PageSize: 2, Cursor: encodeWordCursor(5, "rust")}, want: []string{"c", "zig"}, matching: 6},
+		// The word of the cursor is gone, the page starts where it would be.
+		{name: "cursor of a removed word", query: WordListQuery{PageSize: 2, Cursor: encodeWordCursor(9, "gone")}, want: []string{"golang", "gopher"}, matching: 6, more: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			list, err := tt.query.list(words, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, w := range list.Words {
+				got = append(got, w.Word)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("words = %v, want %v", got, tt.want)
+			}
+			if list.Matching != tt.matching {
+				t.Errorf("matching = %d, want %d", list.Matching, tt.matching)
+			}
+			if (list.NextCursor != "") != tt.more {
+				t.Errorf("next cursor = %q, want one: %v", list.NextCursor, tt.more)
+			}
+		})
+	}
+}
+
+func TestListWords(t *testing.T) {
+	words := map[string]int{"go": 2, "rust": 1}
+
+	tests := []struct {
+		name   string
+		query  WordListQuery
+		export bool
+		want   bool
+	}{
+		{"no query", WordListQuery{}, false, false},
+		{"query", WordListQuery{Top: 1}, false, true},
+		{"export", WordListQuery{Top: 1}, true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			list, err := tt.query.listWords(words, tt.export)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (list != nil) != tt.want {
+				t.Errorf("listWords() = %v, want a list: %v", list, tt.want)
+			}
+		})
+	}
+}
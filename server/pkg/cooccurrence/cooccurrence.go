@@ -0,0 +1,157 @@
+// Package cooccurrence builds graphs of the words that appear together in the
+// units of a text, such as its comments or sentences, weighted by their
+// pointwise mutual information.
+package cooccurrence
+
+import (
+	"math"
+	"sort"
+)
+
+type Node struct {
+	ID string `json:"id"`
+	// Count is the number of units the word appears in.
+	Count int `json:"count"`
+}
+
+// Edge joins two words appearing together in Count units. PMI is their
+// pointwise mutual information, log(p(x,y) / (p(x)p(y))), and NPMI the same
+// normalized between -1 and 1 by -log(p(x,y)).
+type Edge struct {
+	Source string  `json:"source"`
+	Target string  `json:"target"`
+	Count  int     `json:"count"`
+	PMI    float64 `json:"pmi"`
+	NPMI   float64 `json:"npmi"`
+}
+
+type Graph struct {
+	Nodes []Node `json:"nodes"`
+	Edges []Edge `json:"edges"`
+	// Units is the number of units the graph was built from.
+	Units int `json:"units"`
+}
+
+type Options struct {
+	// Top keeps the words appearing in the most units.
+	Top int
+	// MinCount is the fewest units two words must appear together in to be joined.
+	MinCount int
+	// MaxEdges keeps the edges of highest PMI.
+	MaxEdges int
+}
+
+// DefaultOptions returns the options used for the fields of Options left unset.
+func DefaultOptions() Options {
+	return Options{
+		Top:      50,
+		MinCount: 2,
+		MaxEdges: 1000,
+	}
+}
+
+func (opts Options) withDefaults() Options {
+	defaults := DefaultOptions()
+
+	if opts.Top <= 0 {
+		opts.Top = defaults.Top
+	}
+	if opts.MinCount <= 0 {
+		opts.MinCount = defaults.MinCount
+	}
+	if opts.MaxEdges <= 0 {
+		opts.MaxEdges = defaults.MaxEdges
+	}
+
+	return opts
+}
+
+// Build returns the graph of the opts.Top words of units, joined when they
+// appear together in a unit often enough and more often than chance, that is
+// with a positive PMI. A word counts once per unit however often it appears in it.
+func Build(units []map[string]int, opts Options) *Graph {
+	opts = opts.withDefaults()
+
+	counts := make(map[string]int)
+	for _, unit := range units {
+		for word := range unit {
+			counts[word]++
+		}
+	}
+
+	vocabulary := make([]string, 0, len(counts))
+	for word := range counts {
+		vocabulary = append(vocabulary, word)
+	}
+
+	sort.Slice(vocabulary, func(i, j int) bool {
+		if counts[vocabulary[i]] != counts[vocabulary[j]] {
+			return counts[vocabulary[i]] > counts[vocabulary[j]]
+		}
+		return vocabulary[i] < vocabulary[j]
+	})
+
+	if len(vocabulary) > opts.Top {
+		vocabulary = vocabulary[:opts.Top]
+	}
+
+	index := make(map[string]int, len(vocabulary))
+	g := &Graph{Nodes: make([]Node, len(vocabulary)), Edges: []Edge{}, Units: len(units)}
+	for i, word := range vocabulary {
+		index[word] = i
+		g.Nodes[i] = Node{ID: word, Count: counts[word]}
+	}
+
+	pairs := make(map[[2]int]int)
+	members := make([]int, 0, len(vocabulary))
+	for _, unit := range units {
+		members = members[:0]
+		for word := range unit {
+			if i, ok := index[word]; ok {
+				members = append(members, i)
+			}
+		}
+		sort.Ints(members)
+
+		for a := 0; a < len(members); a++ {
+			for b := a + 1; b < len(members); b++ {
+				pairs[[2]int{members[a], members[b]}]++
+			}
+		}
+	}
+
+	n := float64(len(units))
+	for pair, count := range pairs {
+		if count < opts.MinCount {
+			continue
+		}
+
+		x, y := g.Nodes[pair[0]], g.Nodes[pair[1]]
+		pxy := float64(count) / n
+		pmi := math.Log(pxy / (float64(x.Count) / n * float64(y.Count) / n))
+
+		if pmi <= 0 {
+			continue
+		}
+
+		// Words appearing in every unit together have a PMI of 0 and are left out,
+		// so pxy < 1 and the normalization is defined.
+		g.Edges = append(g.Edges, Edge{Source: x.ID, Target: y.ID, Count: count, PMI: pmi, NPMI: pmi / -math.Log(pxy)})
+	}
+
+	sort.Slice(g.Edges, func(i, j int) bool {
+		if g.Edges[i].PMI != g.Edges[j].PMI {
+			return g.Edges[i].PMI > g.Edges[j].PMI
+		}
+		if g.Edges[i].Source != g.Edges[j].Source {
+			return g.Edges[i].Source < g.Edges[j].Source
+		}
+		return g.Edges[i].Target < g.Edges[j].Target
+	})
+
+	if len(g.Edges) > opts.MaxEdges {
+		g.Edges = g.Edges[:opts.MaxEdges]
+	}
+
+	return g
+}
@@ -0,0 +1,90 @@
+package cooccurrence
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestBuild(t *testing.T) {
+	units := []map[string]int{
+		{"go": 5, "rust": 1},
+		{"go": 1, "rust": 1},
+		{"go": 1, "zig": 1},
+		{"python": 1},
+	}
+	pmi := math.Log(4.0 / 3.0)
+
+	tests := []struct {
+		name      string
+		units     []map[string]int
+		opts      Options
+		wantNodes []Node
+		wantEdges []Edge
+	}{
+		{
+			name:      "default options",
+			units:     units,
+			wantNodes: []Node{{"go", 3}, {"rust", 2}, {"python", 1}, {"zig", 1}},
+			wantEdges: []Edge{{Source: "go", Target: "rust", Count: 2, PMI: pmi, NPMI: pmi / math.Log(2)}},
+		},
+		{
+			name:      "top words",
+			units:     units,
+			opts:      Options{Top: 2},
+			wantNodes: []Node{{"go", 3}, {"rust", 2}},
+			wantEdges: []Edge{{Source: "go", Target: "rust", Count: 2, PMI: pmi, NPMI: pmi / math.Log(2)}},
+		},
+		{
+			name:      "min count",
+			units:     units,
+			opts:      Options{MinCount: 1},
+			wantNodes: []Node{{"go", 3}, {"rust", 2}, {"python", 1}, {"zig", 1}},
+			wantEdges: []Edge{
+				{Source: "go", Target: "rust", Count: 2, PMI: pmi, NPMI: pmi / math.Log(2)},
+				{Source: "go", Target: "zig", Count: 1, PMI: pmi, NPMI: pmi / math.Log(4)},
+			},
+		},
+		{
+			name:      "max edges",
+			units:     units,
+			opts:      Options{MinCount: 1, MaxEdges: 1},
+			wantNodes: []Node{{"go", 3}, {"rust", 2}, {"python", 1}, {"zig", 1}},
+			wantEdges: []Edge{{Source: "go", Target: "rust", Count: 2, PMI: pmi, NPMI: pmi / math.Log(2)}},
+		},
+		{
+			name:      "words in every unit",
+			units:     []map[string]int{{"a": 1, "b": 1}, {"a": 1, "b": 1}},
+			wantNodes: []Node{{"a", 2}, {"b", 2}},
+			wantEdges: []Edge{},
+		},
+		{
+			name:      "no units",
+			wantNodes: []Node{},
+			wantEdges: []Edge{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := Build(tt.units, tt.opts)
+
+			if g.Units != len(tt.units) {
+				t.Errorf("Units = %d, want %d", g.Units, len(tt.units))
+			}
+			if !reflect.DeepEqual(g.Nodes, tt.wantNodes) {
+				t.Errorf("Nodes = %v, want %v", g.Nodes, tt.wantNodes)
+			}
+			if len(g.Edges) != len(tt.wantEdges) {
+				t.Fatalf("Edges = %v, want %v", g.Edges, tt.wantEdges)
+			}
+			for i, want := range tt.wantEdges {
+				got := g.Edges[i]
+				if got.Source != want.Source || got.Target != want.Target || got.Count != want.Count ||
+					math.Abs(got.PMI-want.PMI) > 1e-9 || math.Abs(got.NPMI-want.NPMI) > 1e-9 {
+					t.Errorf("Edges[%d] = %+v, want %+v", i, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package cooccurrence
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+)
+
+// WriteGraphML writes g to w as a GraphML document, the counts and weights
+// of its nodes and edges as data keys.
+func (g *Graph) WriteGraphML(w io.Writer) error {
+	buf := bufio.NewWriter(w)
+
+	buf.WriteString(xml.Header)
+	buf.WriteString(`<graphml xmlns="http://graphml.graphdrawing.org/xmlns">` + "\n")
+	buf.WriteString(`<key id="count" for="all" attr.name="count" attr.type="int"/>` + "\n")
+	buf.WriteString(`<key id="pmi" for="edge" attr.name="pmi" attr.type="double"/>` + "\n")
+	buf.WriteString(`<key id="npmi" for="edge" attr.name="npmi" attr.type="double"/>` + "\n")
+	buf.WriteString(`<graph id="G" edgedefault="undirected">` + "\n")
+
+	for _, n := range g.Nodes {
+		fmt.Fprintf(buf, `<node id="%s"><data key="count">%d</data></node>`+"\n", escape(n.ID), n.Count)
+	}
+
+	for i, e := range g.Edges {
+		fmt.Fprintf(buf, `<edge id="e%d" source="%s" target="%s"><data key="count">%d</data><data key="pmi">%g</data><data key="npmi">%g</data></edge>`+"\n",
+			i, escape(e.Source), escape(e.Target), e.Count, e.PMI, e.NPMI)
+	}
+
+	buf.WriteString("</graph>\n</graphml>\n")
+
+	return buf.Flush()
+}
+
+// WriteGEXF writes g to w as a GEXF 1.3 document. The PMI of an edge is its
+// weight, its count and NPMI are attributes.
+func (g *Graph) WriteGEXF(w io.Writer) error {
+	buf := bufio.NewWriter(w)
+
+	buf.WriteString(xml.Header)
+	buf.WriteString(`<gexf xmlns="http://gexf.net/1.3" version="1.3">` + "\n")
+	buf.WriteString(`<graph mode="static" defaultedgetype="undirected">` + "\n")
+	buf.WriteString(`<attributes class="node"><attribute id="count" title="count" type="integer"/></attributes>` + "\n")
+	buf.WriteString(`<attributes class="edge"><attribute id="count" title="count" type="integer"/><attribute id="npmi" title="npmi" type="double"/></attributes>` + "\n")
+
+	buf.WriteString("<nodes>\n")
+	for _, n := range g.Nodes {
+		fmt.Fprintf(buf, `<node id="%s" label="%s"><attvalues><attvalue for="count" value="%d"/></attvalues></node>`+"\n",
+			escape(n.ID), escape(n.ID), n.Count)
+	}
+	buf.WriteString("</nodes>\n")
+
+	buf.WriteString("<edges>\n")
+	for i, e := range g.Edges {
+		fmt.Fprintf(buf, `<edge id="%d" source="%s" target="%s" weight="%g"><attvalues><attvalue for="count" value="%d"/><attvalue for="npmi" value="%g"/></attvalues></edge>`+"\n",
+			i, escape(e.Source), escape(e.Target), e.PMI, e.Count, e.NPMI)
+	}
+	buf.WriteString("</edges>\n")
+
+	buf.WriteString("</graph>\n</gexf>\n")
+
+	return buf.Flush()
+}
+
+func escape(s string) string {
+	var buf bytes.Buffer
+	_ = xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
@@ -0,0 +1,160 @@
+// Package language identifies the language of short texts from their
+// character trigrams, and provides the stop words and stemmer of each
+// language it identifies.
+package language
+
+import (
+	"embed"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Undetermined is the language of texts too short or too unlike every
+// profile to be identified.
+const Undetermined = "und"
+
+// Languages are the ISO 639-1 codes of the languages Detect identifies.
+var Languages = []string{"de", "en", "es", "fr", "it", "nl", "pt"}
+
+const (
+	// profileSize is the number of trigrams ranked in a profile.
+	profileSize = 300
+	// minLetters is the fewest letters a text needs to be identified.
+	minLetters = 20
+	// maxDistance is the largest normalized distance, from 0 for a text ranking
+	// its trigrams like a profile to 1 for a text sharing none, of an identified text.
+	maxDistance = 0.85
+)
+
+//go:embed samples/*.txt stopwords/*.txt
+var data embed.FS
+
+// profile ranks the most frequent trigrams of a language, 0 the most frequent.
+type profile map[string]int
+
+var profiles = make(map[string]profile, len(Languages))
+
+func init() {
+	for _, lang := range Languages {
+		sample, err := data.ReadFile("samples/" + lang + ".txt")
+		if err != nil {
+			panic(err)
+		}
+		// The stop words are the most frequent words of a language, and make
+		// up for the shortness of the sample.
+		words, err := data.ReadFile("stopwords/" + lang + ".txt")
+		if err != nil {
+			panic(err)
+		}
+		profiles[lang] = newProfile(string(sample) + " " + string(words))
+		stopWords[lang] = newStopWords(string(words))
+	}
+}
+
+func newProfile(text string) profile {
+	counts := trigrams(text)
+
+	sorted := make([]string, 0, len(counts))
+	for trigram := range counts {
+		sorted = append(sorted, trigram)
+	}
+
+	sort.Slice(sorted, func(i, j int) bool {
+		if counts[sorted[i]] != counts[sorted[j]] {
+			return counts[sorted[i]] > counts[sorted[j]]
+		}
+		return sorted[i] < sorted[j]
+	})
+
+	if len(sorted) > profileSize {
+		sorted = sorted[:profileSize]
+	}
+
+	p := make(profile, len(sorted))
+	for rank, trigram := range sorted {
+		p[trigram] = rank
+	}
+	return p
+}
+
+// trigrams counts the trigrams of the lower-cased words of text, each word
+// padded with a space on both sides so its first and last letters count.
+func trigrams(text string) map[string]int {
+	counts := make(map[string]int)
+
+	for _, word := range strings.FieldsFunc(strings.ToLower(withoutLinks(text)), func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '\''
+	}) {
+		runes := []rune(" " + strings.Trim(word, "'") + " ")
+		for i := 0; i+3 <= len(runes); i++ {
+			counts[string(runes[i:i+3])]++
+		}
+	}
+
+	return counts
+}
+
+// withoutLinks removes the URLs of text, whose trigrams belong to no language.
+func withoutLinks(text string) string {
+	fields := strings.Fields(text)
+	kept := fields[:0]
+	for _, field := range fields {
+		if !strings.Contains(field, "://") && !strings.HasPrefix(field, "www.") {
+			kept = append(kept, field)
+		}
+	}
+	return strings.Join(kept, " ")
+}
+
+func countLetters(text string) int {
+	n := 0
+	for _, r := range text {
+		if unicode.IsLetter(r) {
+			n++
+		}
+	}
+	return n
+}
+
+// Detect returns the language of text, or Undetermined. The profile of text
+// is compared with the profile of every language by the out-of-place
+// distance of Cavnar and Trenkle: the sum of the differences between the rank
+// of each trigram of text and its rank in the language, the size of a profile
+// for the trigrams the language does not rank.
+func Detect(text string) string {
+	if countLetters(withoutLinks(text)) < minLetters {
+		return Undetermined
+	}
+
+	p := newProfile(text)
+	best, bestDistance := Undetermined, len(p)*profileSize
+
+	for _, lang := range Languages {
+		distance := 0
+		for trigram, rank := range p {
+			if r, ok := profiles[lang][trigram]; ok {
+				distance += abs(rank - r)
+			} else {
+				distance += profileSize
+			}
+		}
+
+		if distance < bestDistance {
+			best, bestDistance = lang, distance
+		}
+	}
+
+	if float64(bestDistance) > maxDistance*float64(len(p)*profileSize) {
+		return Undetermined
+	}
+
+	return best
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
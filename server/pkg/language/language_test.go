@@ -0,0 +1,94 @@
+package language
+
+import "testing"
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		want string
+		text string
+	}{
+		{want: "en", text: "Honestly I think the new update made the game worse, the matchmaking takes forever and nobody is fixing the servers."},
+		{want: "en", text: "Does anyone know if this works with the older model? I bought mine last year and the seller said it should be fine."},
+		{want: "de", text: "Ich habe das Spiel gestern gekauft und bin ehrlich gesagt ziemlich enttäuscht, die Server sind ständig überlastet."},
+		{want: "de", text: "Weiß jemand, ob man das auch mit dem älteren Modell benutzen kann? Meins ist erst ein Jahr alt."},
+		{want: "es", text: "La verdad es que la actualización empeoró el juego, las partidas tardan muchísimo y nadie arregla los servidores."},
+		{want: "es", text: "¿Alguien sabe si esto funciona con el modelo anterior? Lo compré el año pasado y el vendedor dijo que sí."},
+		{want: "fr", text: "Franchement je trouve que la mise à jour a rendu le jeu pire, les parties sont trop longues et personne ne répare les serveurs."},
+		{want: "fr", text: "Quelqu'un sait si ça marche avec l'ancien modèle ? Je l'ai acheté l'année dernière et le vendeur m'a dit que oui."},
+		{want: "it", text: "Sinceramente penso che l'aggiornamento abbia peggiorato il gioco, le partite durano troppo e nessuno sistema i server."},
+		{want: "it", text: "Qualcuno sa se funziona anche con il modello vecchio? L'ho comprato l'anno scorso e il venditore mi ha detto di sì."},
+		{want: "nl", text: "Eerlijk gezegd vind ik dat de nieuwe update het spel slechter heeft gemaakt, het duurt eeuwig voordat je een potje vindt."},
+		{want: "nl", text: "Weet iemand of dit ook werkt met het oudere model? Ik heb de mijne vorig jaar gekocht en de verkoper zei dat het kon."},
+		{want: "pt", text: "Sinceramente acho que a atualização deixou o jogo pior, as partidas demoram muito e ninguém conserta os servidores."},
+		{want: "pt", text: "Alguém sabe se isso funciona com o modelo antigo? Comprei o meu no ano passado e o vendedor disse que sim."},
+		{want: Undetermined, text: "lol same"},
+		{want: Undetermined, text: "https://www.example.com/some/long/path/to/a/page"},
+		{want: Undetermined, text: "1234 5678 !!!! ???? 😂😂😂😂😂😂"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			if got := Detect(tt.text); got != tt.want {
+				t.Errorf("Detect() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStem(t *testing.T) {
+	tests := []struct {
+		lang string
+		word string
+		want string
+	}{
+		{lang: "en", word: "servers", want: "server"},
+		{lang: "en", word: "running", want: "run"},
+		{lang: "en", word: "stories", want: "story"},
+		{lang: "en", word: "classes", want: "class"},
+		{lang: "en", word: "bus", want: "bus"},
+		{lang: "en", word: "reddit's", want: "reddit"},
+		{lang: "en", word: "is", want: "is"},
+		{lang: "es", word: "canciones", want: "cancion"},
+		{lang: "es", word: "lápices", want: "lapiz"},
+		{lang: "pt", word: "canções", want: "cancao"},
+		{lang: "pt", word: "animais", want: "animal"},
+		{lang: "fr", word: "l'année", want: "anne"},
+		{lang: "fr", word: "chevaux", want: "cheval"},
+		{lang: "it", word: "amiche", want: "amic"},
+		{lang: "it", word: "dell'anno", want: "ann"},
+		{lang: "de", word: "häuser", want: "haus"},
+		{lang: "nl", word: "mogelijkheden", want: "mogelijkheid"},
+		{lang: "nl", word: "katten", want: "kat"},
+		{lang: Undetermined, word: "servers", want: "servers"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.lang+"/"+tt.word, func(t *testing.T) {
+			if got := Stem(tt.lang, tt.word); got != tt.want {
+				t.Errorf("Stem(%q, %q) = %q, want %q", tt.lang, tt.word, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsStopWord(t *testing.T) {
+	tests := []struct {
+		lang string
+		word string
+		want bool
+	}{
+		{lang: "en", word: "the", want: true},
+		{lang: "en", word: "server", want: false},
+		{lang: "de", word: "und", want: true},
+		{lang: "fr", word: "le", want: true},
+		{lang: Undetermined, word: "the", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.lang+"/"+tt.word, func(t *testing.T) {
+			if got := IsStopWord(tt.lang, tt.word); got != tt.want {
+				t.Errorf("IsStopWord(%q, %q) = %v, want %v", tt.lang, tt.word, got, tt.want)
+			}
+		})
+	}
+}
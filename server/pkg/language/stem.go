@@ -0,0 +1,140 @@
+package language
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// minStem is the fewest letters a stem keeps.
+const minStem = 3
+
+// suffix is replaced by replacement at the end of a word.
+type suffix struct {
+	suffix      string
+	replacement string
+}
+
+// stemmer is a light stemmer: it folds the inflections of a word, mostly
+// plurals and genders, rather than reducing it to its root, so stems stay
+// readable in a cloud.
+type stemmer struct {
+	// fold replaces the letters the language's inflections alter.
+	fold *strings.Replacer
+	// elisions are the prefixes elided in front of a word, such as l' in French.
+	elisions []string
+	// clitics are the endings attached to a word, such as 's in English.
+	clitics []string
+	// suffixes are tried in order, the first that matches is replaced. A
+	// suffix replaced by itself protects the words ending with it.
+	suffixes []suffix
+	// undouble drops the last letter of a stem ending with a double consonant.
+	undouble bool
+}
+
+var accents = strings.NewReplacer(
+	"á", "a", "à", "a", "â", "a", "ã", "a", "ä", "a",
+	"é", "e", "è", "e", "ê", "e", "ë", "e",
+	"í", "i", "ì", "i", "î", "i", "ï", "i",
+	"ó", "o", "ò", "o", "ô", "o", "õ", "o", "ö", "o",
+	"ú", "u", "ù", "u", "û", "u", "ü", "u",
+	"ç", "c", "ñ", "n", "ß", "ss",
+)
+
+var stemmers = map[string]stemmer{
+	"en": {
+		clitics: []string{"'s", "'"},
+		suffixes: []suffix{
+			{"ies", "y"}, {"sses", "ss"}, {"ing", ""}, {"edly", ""}, {"ed", ""}, {"ly", ""},
+			{"ss", "ss"}, {"us", "us"}, {"is", "is"}, {"s", ""},
+		},
+		undouble: true,
+	},
+	"es": {
+		fold:     accents,
+		suffixes: []suffix{{"iones", "ion"}, {"ces", "z"}, {"os", ""}, {"as", ""}, {"es", ""}, {"s", ""}, {"o", ""}, {"a", ""}, {"e", ""}},
+	},
+	"pt": {
+		fold: accents,
+		suffixes: []suffix{
+			{"oes", "ao"}, {"aes", "ao"}, {"ais", "al"}, {"eis", "el"}, {"ns", "m"},
+			{"os", ""}, {"as", ""}, {"es", ""}, {"s", ""}, {"o", ""}, {"a", ""}, {"e", ""},
+		},
+	},
+	"fr": {
+		fold:     accents,
+		elisions: []string{"l'", "d'", "j'", "m'", "n'", "s'", "t'", "c'", "qu'"},
+		suffixes: []suffix{{"eaux", "eau"}, {"aux", "al"}, {"euses", "eux"}, {"euse", "eux"}, {"es", ""}, {"s", ""}, {"x", ""}, {"e", ""}},
+	},
+	"it": {
+		fold:     accents,
+		elisions: []string{"l'", "un'", "dell'", "all'", "nell'", "sull'", "dall'", "quest'"},
+		suffixes: []suffix{{"ghi", "g"}, {"chi", "c"}, {"ghe", "g"}, {"che", "c"}, {"i", ""}, {"e", ""}, {"a", ""}, {"o", ""}},
+	},
+	"de": {
+		fold:     accents,
+		suffixes: []suffix{{"ern", ""}, {"em", ""}, {"en", ""}, {"er", ""}, {"es", ""}, {"e", ""}, {"s", ""}},
+	},
+	"nl": {
+		fold:     accents,
+		suffixes: []suffix{{"heden", "heid"}, {"en", ""}, {"s", ""}, {"e", ""}},
+		undouble: true,
+	},
+}
+
+// Stem returns the stem of word, lower-cased, in lang. Words of an unknown
+// language are returned unchanged.
+func Stem(lang string, word string) string {
+	s, ok := stemmers[lang]
+	if !ok {
+		return word
+	}
+
+	for _, elision := range s.elisions {
+		if rest := strings.TrimPrefix(word, elision); rest != word && rest != "" {
+			word = rest
+			break
+		}
+	}
+
+	for _, clitic := range s.clitics {
+		if rest := strings.TrimSuffix(word, clitic); rest != word && rest != "" {
+			word = rest
+			break
+		}
+	}
+
+	if s.fold != nil {
+		word = s.fold.Replace(word)
+	}
+
+	for _, sfx := range s.suffixes {
+		stem, ok := strings.CutSuffix(word, sfx.suffix)
+		if !ok {
+			continue
+		}
+		if sfx.replacement == sfx.suffix {
+			break
+		}
+		if utf8.RuneCountInString(stem) < minStem {
+			// A shorter suffix could still leave a long enough stem.
+			continue
+		}
+		word = stem + sfx.replacement
+		if s.undouble && sfx.replacement == "" {
+			word = undouble(word)
+		}
+		break
+	}
+
+	return word
+}
+
+// undouble drops the last letter of a word ending with a double consonant,
+// as in "running" stemmed to "runn". Double l, s and z are kept.
+func undouble(word string) string {
+	n := len(word)
+	if n < 2 || word[n-1] != word[n-2] || strings.IndexByte("aeiouylsz", word[n-1]) >= 0 {
+		return word
+	}
+	return word[:n-1]
+}
@@ -0,0 +1,20 @@
+package language
+
+import "strings"
+
+var stopWords = make(map[string]map[string]bool, len(Languages))
+
+func newStopWords(text string) map[string]bool {
+	words := make(map[string]bool)
+	for _, word := range strings.Fields(text) {
+		words[word] = true
+	}
+	return words
+}
+
+// IsStopWord reports whether word, lower-cased, is a stop word of lang: a word
+// too common in the language to tell anything about a text. No word is a stop
+// word of an unknown language.
+func IsStopWord(lang string, word string) bool {
+	return stopWords[lang][word]
+}
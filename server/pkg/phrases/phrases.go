@@ -0,0 +1,350 @@
+// Package phrases finds the terms of a text that span or keep the case of its
+// words: proper nouns and names, recognized from their capitalization away
+// from the start of a sentence, and collocations, pairs of words appearing
+// together far more often than chance.
+package phrases
+
+import (
+	"math"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type Options struct {
+	// MinCount is the fewest occurrences of a name of several words or of a collocation.
+	MinCount int
+	// MinNPMI is the lowest normalized pointwise mutual information of a
+	// collocation, between -1 and 1.
+	MinNPMI float64
+	// MaxLength is the most words of a name.
+	MaxLength int
+	// StopWord reports the words a collocation never starts or ends with.
+	StopWord func(word string) bool
+}
+
+// DefaultOptions returns the options used for the fields of Options left unset.
+func DefaultOptions() Options {
+	return Options{
+		MinCount:  3,
+		MinNPMI:   0.5,
+		MaxLength: 4,
+	}
+}
+
+func (opts Options) withDefaults() Options {
+	defaults := DefaultOptions()
+
+	if opts.MinCount <= 0 {
+		opts.MinCount = defaults.MinCount
+	}
+	if opts.MinNPMI <= 0 {
+		opts.MinNPMI = defaults.MinNPMI
+	}
+	if opts.MaxLength <= 1 {
+		opts.MaxLength = defaults.MaxLength
+	}
+	if opts.StopWord == nil {
+		opts.StopWord = func(string) bool { return false }
+	}
+
+	return opts
+}
+
+// Model holds the names and collocations of a corpus.
+type Model struct {
+	// casing is the most frequent capitalized form of every lower-cased word.
+	casing map[string]string
+	// proper are the lower-cased words capitalized in most of their occurrences
+	// away from the start of a sentence.
+	proper map[string]bool
+	// names are the names of several words, lower-cased, and their most frequent form.
+	names map[string]string
+	// collocations are the pairs of lower-cased words kept together.
+	collocations map[[2]string]bool
+	maxLength    int
+}
+
+// term is a term of a sentence spanning its words from start to end
+// excluded, plain if it is a lower-cased word that can be part of a collocation.
+type term struct {
+	text       string
+	plain      bool
+	start, end int
+}
+
+// Span is a term of a sentence and the words it spans, from Start to End excluded.
+type Span struct {
+	Term  string
+	Start int
+	End   int
+}
+
+// Snapshot is the state of a Model, to store it and Restore it.
+type Snapshot struct {
+	// Casing is the most frequent capitalized form of every lower-cased word.
+	Casing map[string]string
+	// Proper are the lower-cased words capitalized in most of their occurrences.
+	Proper []string
+	// Names are the names of several words, lower-cased, and their most frequent form.
+	Names map[string]string
+	// Collocations are the pairs of lower-cased words kept together.
+	Collocations [][2]string
+	MaxLength    int
+}
+
+// Snapshot returns the state of m.
+func (m *Model) Snapshot() Snapshot {
+	s := Snapshot{Casing: m.casing, Names: m.names, MaxLength: m.maxLength}
+	for word, proper := range m.proper {
+		if proper {
+			s.Proper = append(s.Proper, word)
+		}
+	}
+	for pair := range m.collocations {
+		s.Collocations = append(s.Collocations, pair)
+	}
+	return s
+}
+
+// Restore returns the Model of a Snapshot.
+func Restore(s Snapshot) *Model {
+	m := &Model{
+		casing:       s.Casing,
+		proper:       make(map[string]bool, len(s.Proper)),
+		names:        s.Names,
+		collocations: make(map[[2]string]bool, len(s.Collocations)),
+		maxLength:    s.MaxLength,
+	}
+	if m.casing == nil {
+		m.casing = make(map[string]string)
+	}
+	if m.names == nil {
+		m.names = make(map[string]string)
+	}
+	if m.maxLength <= 1 {
+		m.maxLength = DefaultOptions().MaxLength
+	}
+	for _, word := range s.Proper {
+		m.proper[word] = true
+	}
+	for _, pair := range s.Collocations {
+		m.collocations[pair] = true
+	}
+	return m
+}
+
+// Train learns the names and collocations of documents, made of sentences
+// of words in their original case.
+func Train(documents [][][]string, opts Options) *Model {
+	opts = opts.withDefaults()
+	m := &Model{
+		casing:       make(map[string]string),
+		proper:       make(map[string]bool),
+		names:        make(map[string]string),
+		collocations: make(map[[2]string]bool),
+		maxLength:    opts.MaxLength,
+	}
+
+	capitalized := make(map[string]int)
+	lower := make(map[string]int)
+	casings := make(map[string]map[string]int)
+	names := make(map[string]map[string]int)
+
+	for _, sentences := range documents {
+		for _, sentence := range sentences {
+			for i := 1; i < len(sentence); i++ {
+				word := sentence[i]
+				key := strings.ToLower(word)
+
+				if !isCapitalized(word) {
+					lower[key]++
+					continue
+				}
+
+				capitalized[key]++
+				count(casings, key, word)
+
+				// A name is a run of capitalized words, the first one not starting the sentence.
+				if isCapitalized(sentence[i-1]) && i > 1 {
+					continue
+				}
+				end := i + 1
+				for end < len(sentence) && isCapitalized(sentence[end]) {
+					end++
+				}
+				if n := end - i; n > 1 && n <= opts.MaxLength {
+					name := strings.Join(sentence[i:end], " ")
+					count(names, strings.ToLower(name), name)
+				}
+			}
+		}
+	}
+
+	for key, forms := range casings {
+		m.casing[key] = mostFrequent(forms)
+		m.proper[key] = capitalized[key] > lower[key]
+	}
+
+	for key, forms := range names {
+		if total(forms) >= opts.MinCount {
+			m.names[key] = mostFrequent(forms)
+		}
+	}
+
+	m.trainCollocations(documents, opts)
+
+	return m
+}
+
+// trainCollocations finds the adjacent plain words of documents with a high
+// normalized PMI, once names are recognized.
+func (m *Model) trainCollocations(documents [][][]string, opts Options) {
+	unigrams := make(map[string]int)
+	bigrams := make(map[[2]string]int)
+	n := 0
+
+	for _, sentences := range documents {
+		for _, sentence := range sentences {
+			terms := m.entities(sentence)
+			for i, t := range terms {
+				if !t.plain {
+					continue
+				}
+				unigrams[t.text]++
+				n++
+				if i+1 < len(terms) && terms[i+1].plain {
+					bigrams[[2]string{t.text, terms[i+1].text}]++
+				}
+			}
+		}
+	}
+
+	for pair, c := range bigrams {
+		if c < opts.MinCount || opts.StopWord(pair[0]) || opts.StopWord(pair[1]) {
+			continue
+		}
+
+		pxy := float64(c) / float64(n)
+		if pxy >= 1 {
+			continue
+		}
+
+		pmi := math.Log(pxy / (float64(unigrams[pair[0]]) / float64(n) * float64(unigrams[pair[1]]) / float64(n)))
+		if pmi/-math.Log(pxy) >= opts.MinNPMI {
+			m.collocations[pair] = true
+		}
+	}
+}
+
+// Apply returns the terms of sentences: names and proper nouns in their most
+// frequent form, collocations joined by a space and every other word lower-cased.
+func (m *Model) Apply(sentences [][]string) []string {
+	var out []string
+
+	for _, sentence := range sentences {
+		for _, span := range m.Spans(sentence) {
+			out = append(out, span.Term)
+		}
+	}
+
+	return out
+}
+
+// Spans returns the terms of a sentence, as Apply, with the words they span.
+func (m *Model) Spans(sentence []string) []Span {
+	terms := m.entities(sentence)
+	spans := make([]Span, 0, len(terms))
+
+	for i := 0; i < len(terms); i++ {
+		if i+1 < len(terms) && terms[i].plain && terms[i+1].plain && m.collocations[[2]string{terms[i].text, terms[i+1].text}] {
+			spans = append(spans, Span{Term: terms[i].text + " " + terms[i+1].text, Start: terms[i].start, End: terms[i+1].end})
+			i++
+			continue
+		}
+		spans = append(spans, Span{Term: terms[i].text, Start: terms[i].start, End: terms[i].end})
+	}
+
+	return spans
+}
+
+// entities returns the terms of a sentence once names and proper nouns are recognized.
+func (m *Model) entities(sentence []string) []term {
+	terms := make([]term, 0, len(sentence))
+
+	for i := 0; i < len(sentence); i++ {
+		word := sentence[i]
+		key := strings.ToLower(word)
+
+		if !isCapitalized(word) {
+			terms = append(terms, term{text: key, plain: true, start: i, end: i + 1})
+			continue
+		}
+
+		if name, n := m.name(sentence[i:]); n > 0 {
+			terms = append(terms, term{text: name, start: i, end: i + n})
+			i += n - 1
+			continue
+		}
+
+		// The first word of a sentence is capitalized whatever it is, it is a
+		// proper noun only if the word usually is.
+		if i == 0 && !m.proper[key] {
+			terms = append(terms, term{text: key, plain: true, start: i, end: i + 1})
+			continue
+		}
+
+		if form, ok := m.casing[key]; ok {
+			word = form
+		}
+		terms = append(terms, term{text: word, start: i, end: i + 1})
+	}
+
+	return terms
+}
+
+// name returns the longest known name words start with, and its length in words.
+func (m *Model) name(words []string) (string, int) {
+	for n := min(m.maxLength, len(words)); n > 1; n-- {
+		if !isCapitalized(words[n-1]) {
+			continue
+		}
+		if name, ok := m.names[strings.ToLower(strings.Join(words[:n], " "))]; ok {
+			return name, n
+		}
+	}
+	return "", 0
+}
+
+// isCapitalized reports whether word starts with an upper-case letter. A
+// single letter, such as the pronoun I, is not.
+func isCapitalized(word string) bool {
+	r, size := utf8.DecodeRuneInString(word)
+	return size < len(word) && unicode.IsUpper(r)
+}
+
+func count(counts map[string]map[string]int, key string, form string) {
+	if counts[key] == nil {
+		counts[key] = make(map[string]int)
+	}
+	counts[key][form]++
+}
+
+func total(forms map[string]int) int {
+	n := 0
+	for _, c := range forms {
+		n += c
+	}
+	return n
+}
+
+// mostFrequent returns the most frequent form, the first alphabetically on ties.
+func mostFrequent(forms map[string]int) string {
+	best, most := "", 0
+	for form, c := range forms {
+		if c > most || c == most && form < best {
+			best, most = form, c
+		}
+	}
+	return best
+}
@@ -0,0 +1,187 @@
+package phrases
+
+import (
+	"reflect"
+	"testing"
+)
+
+// corpus is a set of documents naming New York and Python and talking about
+// machine learning.
+var corpus = [][][]string{
+	{{"I", "moved", "to", "New", "York", "last", "year"}},
+	{{"She", "loves", "New", "York", "pizza"}},
+	{{"We", "visited", "New", "York", "again"}},
+	{{"I", "study", "machine", "learning", "daily"}},
+	{{"the", "machine", "learning", "course", "is", "hard"}},
+	{{"my", "machine", "learning", "project", "uses", "Python"}},
+	{{"Python", "is", "great"}, {"The", "cat", "sat"}},
+}
+
+func TestTrain(t *testing.T) {
+	tests := []struct {
+		name             string
+		documents        [][][]string
+		opts             Options
+		wantNames        map[string]string
+		wantCollocations map[[2]string]bool
+		wantProper       map[string]bool
+	}{
+		{
+			name:             "default options",
+			documents:        corpus,
+			wantNames:        map[string]string{"new york": "New York"},
+			wantCollocations: map[[2]string]bool{{"machine", "learning"}: true},
+			wantProper:       map[string]bool{"new": true, "york": true, "python": true},
+		},
+		{
+			name:             "min count",
+			documents:        corpus,
+			opts:             Options{MinCount: 4},
+			wantNames:        map[string]string{},
+			wantCollocations: map[[2]string]bool{},
+			wantProper:       map[string]bool{"new": true, "york": true, "python": true},
+		},
+		{
+			name:             "stop words",
+			documents:        corpus,
+			opts:             Options{StopWord: func(word string) bool { return word == "machine" }},
+			wantNames:        map[string]string{"new york": "New York"},
+			wantCollocations: map[[2]string]bool{},
+			wantProper:       map[string]bool{"new": true, "york": true, "python": true},
+		},
+		{
+			name: "name longer than max length",
+			documents: [][][]string{
+				{{"I", "saw", "The", "Lord", "Of", "Rings"}},
+				{{"I", "saw", "The", "Lord", "Of", "Rings"}},
+				{{"I", "saw", "The", "Lord", "Of", "Rings"}},
+			},
+			opts:             Options{MaxLength: 3},
+			wantNames:        map[string]string{},
+			wantCollocations: map[[2]string]bool{{"i", "saw"}: true},
+			wantProper:       map[string]bool{"the": true, "lord": true, "of": true, "rings": true},
+		},
+		{
+			name: "words capitalized at the start of sentences only",
+			documents: [][][]string{
+				{{"Cats", "are", "cute"}, {"I", "like", "cats"}},
+			},
+			wantNames:        map[string]string{},
+			wantCollocations: map[[2]string]bool{},
+			wantProper:       map[string]bool{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := Train(tt.documents, tt.opts)
+
+			if !reflect.DeepEqual(m.names, tt.wantNames) {
+				t.Errorf("names = %v, want %v", m.names, tt.wantNames)
+			}
+			if !reflect.DeepEqual(m.collocations, tt.wantCollocations) {
+				t.Errorf("collocations = %v, want %v", m.collocations, tt.wantCollocations)
+			}
+			proper := make(map[string]bool)
+			for word, ok := range m.proper {
+				if ok {
+					proper[word] = true
+				}
+			}
+			if !reflect.DeepEqual(proper, tt.wantProper) {
+				t.Errorf("proper = %v, want %v", proper, tt.wantProper)
+			}
+		})
+	}
+}
+
+func TestApply(t *testing.T) {
+	m := Train(corpus, Options{})
+
+	tests := []struct {
+		name     string
+		sentence []string
+		want     []string
+	}{
+		{
+			name:     "name",
+			sentence: []string{"I", "moved", "to", "New", "York"},
+			want:     []string{"i", "moved", "to", "New York"},
+		},
+		{
+			name:     "name starting a sentence",
+			sentence: []string{"New", "York", "is", "big"},
+			want:     []string{"New York", "is", "big"},
+		},
+		{
+			name:     "name in lower case",
+			sentence: []string{"i", "love", "new", "york"},
+			want:     []string{"i", "love", "new", "york"},
+		},
+		{
+			name:     "collocation starting a sentence",
+			sentence: []string{"Machine", "learning", "rocks"},
+			want:     []string{"machine learning", "rocks"},
+		},
+		{
+			name:     "proper noun starting a sentence",
+			sentence: []string{"Python", "rocks"},
+			want:     []string{"Python", "rocks"},
+		},
+		{
+			name:     "proper noun in another case",
+			sentence: []string{"I", "like", "PYTHON"},
+			want:     []string{"i", "like", "Python"},
+		},
+		{
+			name:     "common word starting a sentence",
+			sentence: []string{"The", "cat"},
+			want:     []string{"the", "cat"},
+		},
+		{
+			name:     "unknown capitalized word",
+			sentence: []string{"I", "like", "Rust"},
+			want:     []string{"i", "like", "Rust"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := m.Apply([][]string{tt.sentence}); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Apply() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSpans(t *testing.T) {
+	m := Train(corpus, Options{})
+
+	got := m.Spans([]string{"we", "love", "New", "York", "and", "machine", "learning"})
+	want := []Span{
+		{Term: "we", Start: 0, End: 1},
+		{Term: "love", Start: 1, End: 2},
+		{Term: "New York", Start: 2, End: 4},
+		{Term: "and", Start: 4, End: 5},
+		{Term: "machine learning", Start: 5, End: 7},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Spans() = %+v, want %+v", got, want)
+	}
+}
+
+func TestRestore(t *testing.T) {
+	m := Train(corpus, Options{})
+	restored := Restore(m.Snapshot())
+
+	for _, sentences := range corpus {
+		if got, want := restored.Apply(sentences), m.Apply(sentences); !reflect.DeepEqual(got, want) {
+			t.Errorf("Apply() of the restored model = %q, want %q", got, want)
+		}
+	}
+
+	if got := Restore(Snapshot{}).Apply([][]string{{"Hello", "World"}}); !reflect.DeepEqual(got, []string{"hello", "World"}) {
+		t.Errorf("Apply() of an empty model = %q, want [hello World]", got)
+	}
+}
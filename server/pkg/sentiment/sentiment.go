@@ -0,0 +1,188 @@
+// Package sentiment scores the sentiment of English text with a lexicon, in
+// the manner of VADER: word valences adjusted for negations, intensifiers,
+// capitalization, "but" clauses and exclamation marks. Its lexicon is
+// VADER's, under the MIT License of LICENSE.vader.
+package sentiment
+
+import (
+	_ "embed"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const (
+	// boosterIncrement is the change of valence a booster or dampener brings.
+	boosterIncrement = 0.293
+	// capsIncrement is the change of valence of a word in capitals in mixed-case text.
+	capsIncrement = 0.733
+	// negationScalar flips and dampens the valence of a negated word.
+	negationScalar = -0.74
+	// exclamationIncrement is the emphasis of each exclamation mark, up to maxExclamations.
+	exclamationIncrement = 0.292
+	maxExclamations      = 4
+	// normalizationAlpha approximates the maximum expected sum of valences.
+	normalizationAlpha = 15
+	// Neutral is the compound score under which, in absolute value, text is neutral.
+	Neutral = 0.05
+)
+
+//go:embed lexicon.txt
+var lexiconFile string
+
+var lexicon = parseLexicon(lexiconFile)
+
+var negations = map[string]bool{
+	"not": true, "no": true, "never": true, "none": true, "nobody": true, "nothing": true,
+	"neither": true, "nor": true, "without": true, "cannot": true, "cant": true, "can't": true,
+	"dont": true, "don't": true, "doesnt": true, "doesn't": true, "didnt": true, "didn't": true,
+	"isnt": true, "isn't": true, "wasnt": true, "wasn't": true, "arent": true, "aren't": true,
+	"wont": true, "won't": true, "wouldnt": true, "wouldn't": true, "shouldnt": true, "shouldn't": true,
+	"couldnt": true, "couldn't": true, "aint": true, "ain't": true, "hardly": true, "rarely": true,
+}
+
+// boosters intensify, or dampen when negative, the valence of the word they precede.
+var boosters = map[string]float64{
+	"absolutely": boosterIncrement, "amazingly": boosterIncrement, "completely": boosterIncrement,
+	"considerably": boosterIncrement, "deeply": boosterIncrement, "enormously": boosterIncrement,
+	"entirely": boosterIncrement, "especially": boosterIncrement, "extremely": boosterIncrement,
+	"fucking": boosterIncrement, "greatly": boosterIncrement, "highly": boosterIncrement,
+	"hugely": boosterIncrement, "incredibly": boosterIncrement, "insanely": boosterIncrement,
+	"most": boosterIncrement, "much": boosterIncrement, "really": boosterIncrement,
+	"so": boosterIncrement, "super": boosterIncrement, "too": boosterIncrement,
+	"totally": boosterIncrement, "truly": boosterIncrement, "very": boosterIncrement,
+	"almost": -boosterIncrement, "barely": -boosterIncrement, "kinda": -boosterIncrement,
+	"less": -boosterIncrement, "little": -boosterIncrement, "marginally": -boosterIncrement,
+	"partly": -boosterIncrement, "slightly": -boosterIncrement, "somewhat": -boosterIncrement,
+	"sorta": -boosterIncrement,
+}
+
+func parseLexicon(file string) map[string]float64 {
+	lex := make(map[string]float64)
+
+	for _, line := range strings.Split(file, "\n") {
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		word, valence, ok := strings.Cut(line, "\t")
+		if !ok {
+			panic("invalid lexicon line: " + line)
+		}
+		v, err := strconv.ParseFloat(valence, 64)
+		if err != nil {
+			panic("invalid lexicon line: " + line)
+		}
+		lex[word] = v
+	}
+
+	return lex
+}
+
+// Score returns the compound sentiment of text, from -1 (most negative) to 1
+// (most positive).
+func Score(text string) float64 {
+	tokens := strings.Fields(text)
+	words := make([]string, len(tokens))
+	lower := make([]string, len(tokens))
+
+	for i, token := range tokens {
+		words[i] = strings.TrimFunc(token, func(r rune) bool { return !unicode.IsLetter(r) && r != '\'' })
+		lower[i] = strings.ToLower(words[i])
+	}
+
+	mixedCase := isMixedCase(words)
+	valences := make([]float64, len(words))
+
+	for i, word := range lower {
+		if word == "" {
+			// Emoticons are rated as they are written.
+			word = strings.ToLower(tokens[i])
+		}
+
+		v, ok := lexicon[word]
+		if !ok || boosters[word] != 0 {
+			continue
+		}
+
+		// "no" only negates the rated word that follows it.
+		if word == "no" && i+1 < len(lower) && lexicon[lower[i+1]] != 0 {
+			continue
+		}
+
+		if mixedCase && isUpper(words[i]) {
+			v += math.Copysign(capsIncrement, v)
+		}
+
+		for distance := 1; distance <= 3 && i-distance >= 0; distance++ {
+			prev := lower[i-distance]
+			decay := 1 - 0.05*float64(distance-1)
+
+			if boost, ok := boosters[prev]; ok {
+				b := boost * decay
+				if mixedCase && isUpper(words[i-distance]) {
+					b += math.Copysign(capsIncrement, boost)
+				}
+				v += math.Copysign(1, v) * b
+			}
+
+			if negations[prev] || strings.HasSuffix(prev, "n't") {
+				v *= negationScalar
+			}
+		}
+
+		valences[i] = v
+	}
+
+	// The clause after "but" carries the sentiment of the text.
+	for i, word := range lower {
+		if word != "but" {
+			continue
+		}
+		for j := range valences {
+			if j < i {
+				valences[j] *= 0.5
+			} else if j > i {
+				valences[j] *= 1.5
+			}
+		}
+		break
+	}
+
+	sum := 0.0
+	for _, v := range valences {
+		sum += v
+	}
+
+	if sum != 0 {
+		exclamations := math.Min(float64(strings.Count(text, "!")), maxExclamations)
+		sum += math.Copysign(exclamations*exclamationIncrement, sum)
+	}
+
+	return normalize(sum)
+}
+
+func normalize(sum float64) float64 {
+	score := sum / math.Sqrt(sum*sum+normalizationAlpha)
+	return math.Max(-1, math.Min(1, score))
+}
+
+// isMixedCase reports whether some but not all of the words are in capitals,
+// when capitals mark emphasis rather than shouting.
+func isMixedCase(words []string) bool {
+	upper, cased := 0, 0
+	for _, word := range words {
+		if strings.IndexFunc(word, unicode.IsLetter) < 0 {
+			continue
+		}
+		cased++
+		if isUpper(word) {
+			upper++
+		}
+	}
+	return upper > 0 && upper < cased
+}
+
+func isUpper(word string) bool {
+	return len(word) > 1 && strings.ToUpper(word) == word && strings.ToLower(word) != word
+}
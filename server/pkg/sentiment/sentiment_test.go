@@ -0,0 +1,94 @@
+package sentiment
+
+import (
+	"math"
+	"testing"
+)
+
+func TestLexicon(t *testing.T) {
+	if len(lexicon) < 7500 {
+		t.Errorf("lexicon has %d words, want the whole VADER lexicon", len(lexicon))
+	}
+}
+
+func TestScore(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want float64
+	}{
+		{name: "neutral", text: "The table is made of wood", want: 0},
+		{name: "positive", text: "good", want: 0.4404},
+		{name: "negative", text: "horrible", want: -0.5423},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Score(tt.text); math.Abs(got-tt.want) > 1e-4 {
+				t.Errorf("Score(%q) = %.4f, want %.4f", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScoreRules(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		baseline string
+		// higher is whether text scores above baseline, rather than below.
+		higher bool
+	}{
+		{name: "negation flips", text: "The food is not good", baseline: "The food is good", higher: false},
+		{name: "contracted negation flips", text: "The food isn't good", baseline: "The food is good", higher: false},
+		{name: "negation of a negative word", text: "The food is not horrible", baseline: "The food is horrible", higher: true},
+		{name: "booster intensifies", text: "The food is very good", baseline: "The food is good", higher: true},
+		{name: "booster intensifies a negative word", text: "The food is very horrible", baseline: "The food is horrible", higher: false},
+		{name: "dampener weakens", text: "The food is slightly good", baseline: "The food is good", higher: false},
+		{name: "capitals emphasize", text: "The food is GOOD", baseline: "The food is good", higher: true},
+		{name: "clause after but dominates", text: "The food is good but the service is horrible", baseline: "The food is horrible but the service is good", higher: false},
+		{name: "exclamations emphasize", text: "The food is good!!!", baseline: "The food is good", higher: true},
+		{name: "emoticons are rated", text: "The food is good :)", baseline: "The food is good", higher: true},
+		{name: "no before a rated word", text: "no problem", baseline: "problem", higher: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, base := Score(tt.text), Score(tt.baseline)
+			if (got > base) != tt.higher || got == base {
+				t.Errorf("Score(%q) = %.4f, Score(%q) = %.4f, want it higher: %v", tt.text, got, tt.baseline, base, tt.higher)
+			}
+		})
+	}
+}
+
+func TestScoreShouting(t *testing.T) {
+	// Text all in capitals is shouted, its capitals do not single out a word.
+	if got, want := Score("THE FOOD IS GOOD"), Score("the food is good"); got != want {
+		t.Errorf("Score of shouted text = %.4f, want %.4f", got, want)
+	}
+}
+
+func TestScoreSign(t *testing.T) {
+	tests := []struct {
+		text string
+		want float64
+	}{
+		{text: "I love this community, everyone is so helpful", want: 1},
+		{text: "This update is terrible and the devs don't care", want: -1},
+		{text: "It's not bad at all", want: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			got := Score(tt.text)
+			sign := 0.0
+			if math.Abs(got) >= Neutral {
+				sign = math.Copysign(1, got)
+			}
+			if sign != tt.want {
+				t.Errorf("Score(%q) = %.4f, want sign %v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,303 @@
+// Package topics finds the topics of a set of documents with latent Dirichlet
+// allocation, fitted by collapsed Gibbs sampling.
+package topics
+
+import (
+	"context"
+	"math/rand"
+	"sort"
+)
+
+type Options struct {
+	// Topics is the number of topics, K.
+	Topics int
+	// Iterations is the number of sweeps of the sampler over every token.
+	Iterations int
+	// Alpha is the prior of the topics of a document, Beta of the words of a topic.
+	Alpha float64
+	Beta  float64
+	// Seed makes the model deterministic, the same documents and options always
+	// produce the same topics.
+	Seed int64
+	// TopWords is the number of words listed for each topic.
+	TopWords int
+	// MinDocuments is the fewest documents a word must appear in to be modeled.
+	MinDocuments int
+	// MaxVocabulary keeps the words appearing in the most documents.
+	MaxVocabulary int
+	// MaxDocuments and MaxTokens bound the work of a sweep: the documents past
+	// the first MaxDocuments, or past the first MaxTokens tokens, are not modeled.
+	MaxDocuments int
+	MaxTokens    int
+}
+
+// DefaultOptions returns the options used for the fields of Options left unset.
+func DefaultOptions() Options {
+	return Options{
+		Topics:        10,
+		Iterations:    200,
+		Alpha:         0.1,
+		Beta:          0.01,
+		TopWords:      15,
+		MinDocuments:  2,
+		MaxVocabulary: 5000,
+		MaxDocuments:  5000,
+		MaxTokens:     100000,
+	}
+}
+
+func (opts Options) withDefaults() Options {
+	defaults := DefaultOptions()
+
+	if opts.Topics <= 0 {
+		opts.Topics = defaults.Topics
+	}
+	if opts.Iterations <= 0 {
+		opts.Iterations = defaults.Iterations
+	}
+	if opts.Alpha <= 0 {
+		opts.Alpha = defaults.Alpha
+	}
+	if opts.Beta <= 0 {
+		opts.Beta = defaults.Beta
+	}
+	if opts.TopWords <= 0 {
+		opts.TopWords = defaults.TopWords
+	}
+	if opts.MinDocuments <= 0 {
+		opts.MinDocuments = defaults.MinDocuments
+	}
+	if opts.MaxVocabulary <= 0 {
+		opts.MaxVocabulary = defaults.MaxVocabulary
+	}
+	if opts.MaxDocuments <= 0 {
+		opts.MaxDocuments = defaults.MaxDocuments
+	}
+	if opts.MaxTokens <= 0 {
+		opts.MaxTokens = defaults.MaxTokens
+	}
+
+	return opts
+}
+
+type WordProbability struct {
+	Word        string  `json:"word"`
+	Probability float64 `json:"probability"`
+}
+
+type Topic struct {
+	ID int `json:"id"`
+	// Words are the most probable words of the topic.
+	Words []WordProbability `json:"words"`
+	// Counts are the occurrences of words assigned to the topic, a cloud of the topic.
+	Counts map[string]int `json:"counts"`
+	// Documents is the number of documents the topic is dominant in.
+	Documents int `json:"documents"`
+}
+
+// Assignment is the dominant topic of a document and its probability in the
+// document, Topic is -1 for documents without a modeled word and for the
+// documents past the caps of Options.
+type Assignment struct {
+	Topic       int     `json:"topic"`
+	Probability float64 `json:"probability"`
+}
+
+type Model struct {
+	Topics []Topic
+	// Documents are the dominant topics of the documents, in their order.
+	Documents []Assignment
+	// Vocabulary is the number of distinct words modeled.
+	Vocabulary int
+}
+
+// LDA fits a topic model to documents, bags of words with their counts.
+// Words appearing in fewer than opts.MinDocuments documents are left out.
+// The sampler stops with the error of ctx once it is done.
+func LDA(ctx context.Context, documents []map[string]int, opts Options) (*Model, error) {
+	opts = opts.withDefaults()
+	modeled := documents
+	if len(modeled) > opts.MaxDocuments {
+		modeled = modeled[:opts.MaxDocuments]
+	}
+	vocabulary := buildVocabulary(modeled, opts)
+	k, v := opts.Topics, len(vocabulary)
+
+	// The tokens of a document are laid out in the order of their words, so
+	// the sampler visits them in the same order on every run.
+	index := make(map[string]int, v)
+	for i, word := range vocabulary {
+		index[word] = i
+	}
+
+	tokens := make([][]int, len(documents))
+	total := 0
+	for d, doc := range modeled {
+		words := make([]int, 0, len(doc))
+		for word := range doc {
+			if i, ok := index[word]; ok {
+				words = append(words, i)
+			}
+		}
+		sort.Ints(words)
+
+		n := 0
+		for _, w := range words {
+			n += doc[vocabulary[w]]
+		}
+		if total+n > opts.MaxTokens {
+			break
+		}
+		total += n
+
+		for _, w := range words {
+			for n := 0; n < doc[vocabulary[w]]; n++ {
+				tokens[d] = append(tokens[d], w)
+			}
+		}
+	}
+
+	rng := rand.New(rand.NewSource(opts.Seed))
+	docTopic := make([][]int, len(documents))
+	topicWord := make([][]int, k)
+	topicTotal := make([]int, k)
+	assignments := make([][]int, len(documents))
+
+	for t := range topicWord {
+		topicWord[t] = make([]int, v)
+	}
+
+	for d := range tokens {
+		docTopic[d] = make([]int, k)
+		assignments[d] = make([]int, len(tokens[d]))
+		for i, w := range tokens[d] {
+			t := rng.Intn(k)
+			assignments[d][i] = t
+			docTopic[d][t]++
+			topicWord[t][w]++
+			topicTotal[t]++
+		}
+	}
+
+	vBeta := float64(v) * opts.Beta
+	p := make([]float64, k)
+
+	for iteration := 0; iteration < opts.Iterations; iteration++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		for d := range tokens {
+			for i, w := range tokens[d] {
+				t := assignments[d][i]
+				docTopic[d][t]--
+				topicWord[t][w]--
+				topicTotal[t]--
+
+				// p holds the cumulative conditional probabilities of the topics.
+				sum := 0.0
+				for j := range p {
+					sum += (float64(docTopic[d][j]) + opts.Alpha) * (float64(topicWord[j][w]) + opts.Beta) / (float64(topicTotal[j]) + vBeta)
+					p[j] = sum
+				}
+
+				u := rng.Float64() * sum
+				t = sort.SearchFloat64s(p, u)
+				if t == k {
+					t = k - 1
+				}
+
+				assignments[d][i] = t
+				docTopic[d][t]++
+				topicWord[t][w]++
+				topicTotal[t]++
+			}
+		}
+	}
+
+	m := &Model{Topics: make([]Topic, k), Documents: make([]Assignment, len(documents)), Vocabulary: v}
+
+	for t := range m.Topics {
+		m.Topics[t] = topic(t, topicWord[t], topicTotal[t], vocabulary, vBeta, opts)
+	}
+
+	kAlpha := float64(k) * opts.Alpha
+	for d := range tokens {
+		m.Documents[d] = Assignment{Topic: -1}
+		if len(tokens[d]) == 0 {
+			continue
+		}
+
+		for t, n := range docTopic[d] {
+			if theta := (float64(n) + opts.Alpha) / (float64(len(tokens[d])) + kAlpha); theta > m.Documents[d].Probability {
+				m.Documents[d] = Assignment{Topic: t, Probability: theta}
+			}
+		}
+		m.Topics[m.Documents[d].Topic].Documents++
+	}
+
+	return m, nil
+}
+
+// buildVocabulary returns the words modeled, sorted.
+func buildVocabulary(documents []map[string]int, opts Options) []string {
+	frequencies := make(map[string]int)
+	for _, doc := range documents {
+		for word := range doc {
+			frequencies[word]++
+		}
+	}
+
+	vocabulary := make([]string, 0, len(frequencies))
+	for word, df := range frequencies {
+		if df >= opts.MinDocuments {
+			vocabulary = append(vocabulary, word)
+		}
+	}
+
+	if len(vocabulary) > opts.MaxVocabulary {
+		sort.Slice(vocabulary, func(i, j int) bool {
+			if frequencies[vocabulary[i]] != frequencies[vocabulary[j]] {
+				return frequencies[vocabulary[i]] > frequencies[vocabulary[j]]
+			}
+			return vocabulary[i] < vocabulary[j]
+		})
+		vocabulary = vocabulary[:opts.MaxVocabulary]
+	}
+
+	sort.Strings(vocabulary)
+	return vocabulary
+}
+
+func topic(id int, counts []int, total int, vocabulary []string, vBeta float64, opts Options) Topic {
+	t := Topic{ID: id, Counts: make(map[string]int)}
+
+	order := make([]int, 0, len(counts))
+	for w, n := range counts {
+		if n > 0 {
+			t.Counts[vocabulary[w]] = n
+			order = append(order, w)
+		}
+	}
+
+	sort.Slice(order, func(i, j int) bool {
+		if counts[order[i]] != counts[order[j]] {
+			return counts[order[i]] > counts[order[j]]
+		}
+		return order[i] < order[j]
+	})
+
+	if len(order) > opts.TopWords {
+		order = order[:opts.TopWords]
+	}
+
+	t.Words = make([]WordProbability, len(order))
+	for i, w := range order {
+		t.Words[i] = WordProbability{
+			Word:        vocabulary[w],
+			Probability: (float64(counts[w]) + opts.Beta) / (float64(total) + vBeta),
+		}
+	}
+
+	return t
+}
@@ -0,0 +1,122 @@
+package topics
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// corpus returns n documents about pets followed by n about engines.
+func corpus(n int) []map[string]int {
+	var documents []map[string]int
+	for i := 0; i < n; i++ {
+		documents = append(documents, map[string]int{"cat": 2, "dog": 1, "leash": 1})
+	}
+	for i := 0; i < n; i++ {
+		documents = append(documents, map[string]int{"engine": 2, "piston": 1, "fuel": 1})
+	}
+	return documents
+}
+
+func TestLDA(t *testing.T) {
+	documents := corpus(10)
+	opts := Options{Topics: 2, Seed: 1}
+
+	m, err := LDA(context.Background(), documents, opts)
+	if err != nil {
+		t.Fatalf("LDA() error = %v", err)
+	}
+
+	if m.Vocabulary != 6 {
+		t.Errorf("Vocabulary = %d, want 6", m.Vocabulary)
+	}
+
+	pets, engines := m.Documents[0].Topic, m.Documents[10].Topic
+	if pets == engines {
+		t.Fatalf("pets and engines share topic %d", pets)
+	}
+	for d, assignment := range m.Documents {
+		want := pets
+		if d >= 10 {
+			want = engines
+		}
+		if assignment.Topic != want {
+			t.Errorf("Documents[%d].Topic = %d, want %d", d, assignment.Topic, want)
+		}
+	}
+	if got := m.Topics[pets].Words[0].Word; got != "cat" {
+		t.Errorf("most probable word of the pets topic = %q, want cat", got)
+	}
+	if m.Topics[pets].Documents != 10 || m.Topics[engines].Documents != 10 {
+		t.Errorf("topic documents = %d and %d, want 10 each", m.Topics[pets].Documents, m.Topics[engines].Documents)
+	}
+
+	again, err := LDA(context.Background(), documents, opts)
+	if err != nil {
+		t.Fatalf("LDA() error = %v", err)
+	}
+	if !reflect.DeepEqual(again, m) {
+		t.Errorf("LDA() with the same seed fitted a different model")
+	}
+}
+
+func TestLDAModeled(t *testing.T) {
+	tests := []struct {
+		name      string
+		documents []map[string]int
+		opts      Options
+		// unmodeled are the documents without a topic.
+		unmodeled []int
+	}{
+		{
+			name:      "words in a single document",
+			documents: append(corpus(2), map[string]int{"unique": 3}),
+			opts:      Options{Topics: 2},
+			unmodeled: []int{4},
+		},
+		{
+			name:      "max documents",
+			documents: corpus(3),
+			opts:      Options{Topics: 2, MaxDocuments: 5},
+			unmodeled: []int{5},
+		},
+		{
+			name:      "max tokens",
+			documents: corpus(2),
+			opts:      Options{Topics: 2, MaxTokens: 9},
+			unmodeled: []int{2, 3},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := LDA(context.Background(), tt.documents, tt.opts)
+			if err != nil {
+				t.Fatalf("LDA() error = %v", err)
+			}
+			if len(m.Documents) != len(tt.documents) {
+				t.Fatalf("Documents = %d, want %d", len(m.Documents), len(tt.documents))
+			}
+
+			var unmodeled []int
+			for d, assignment := range m.Documents {
+				if assignment.Topic == -1 {
+					unmodeled = append(unmodeled, d)
+				}
+			}
+			if !reflect.DeepEqual(unmodeled, tt.unmodeled) {
+				t.Errorf("unmodeled documents = %v, want %v", unmodeled, tt.unmodeled)
+			}
+		})
+	}
+}
+
+func TestLDACanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := LDA(ctx, corpus(10), Options{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("LDA() error = %v, want %v", err, context.Canceled)
+	}
+}
@@ -1,7 +1,5 @@
 package util
 
-import "time"
-
 func ChunkStringSlice(slice []string, chunkSize int) [][]string {
 	var chunks [][]string
 	for i := 0; i < len(slice); i += chunkSize {
@@ -19,17 +17,6 @@ func ChunkStringSlice(slice []string, chunkSize int) [][]string {
 	return chunks
 }
 
-func IsInLastWeek(t time.Time) bool {
-	// Get the current time
-	now := time.Now()
-
-	// Calculate the duration between now and the provided time
-	duration := now.Sub(t)
-
-	// Check if the duration is within the last week (7 days)
-	return duration.Seconds() <= 7*24*60*60
-}
-
 func CombineMaps(map1, map2 map[string]int) map[string]int {
 	result := make(map[string]int)
 
@@ -0,0 +1,61 @@
+package wordcloud
+
+import (
+	"encoding/base64"
+	"sync"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+)
+
+// measureSize is the size of the face text is measured with, measurements
+// scale linearly to other sizes.
+const measureSize = 100
+
+// regular is the embedded Go Regular font, used to measure and rasterize words
+// without depending on the fonts installed on the host.
+var regular = mustParseFont(goregular.TTF)
+
+// goFontName is the name SVG clouds declare the embedded font under, and
+// goFontFamily their default family, falling back to any sans-serif font.
+const (
+	goFontName   = "GoRegular"
+	goFontFamily = goFontName + ", sans-serif"
+)
+
+// goRegularURL is the embedded font as a data URL.
+var goRegularURL = "data:font/ttf;base64," + base64.StdEncoding.EncodeToString(goregular.TTF)
+
+// measureFaces are the faces text is measured with. Faces are not safe for
+// concurrent use, every layout takes one of its own.
+var measureFaces = sync.Pool{New: func() any { return mustNewFace(measureSize) }}
+
+func mustParseFont(ttf []byte) *opentype.Font {
+	f, err := opentype.Parse(ttf)
+	if err != nil {
+		panic(err)
+	}
+	return f
+}
+
+func mustNewFace(size float64) font.Face {
+	face, err := newFace(size)
+	if err != nil {
+		panic(err)
+	}
+	return face
+}
+
+func newFace(size float64) (font.Face, error) {
+	return opentype.NewFace(regular, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingNone})
+}
+
+// measure returns the width and height of text drawn at fontSize, measured with face.
+func measure(face font.Face, text string, fontSize float64) (float64, float64) {
+	advance := font.MeasureString(face, text)
+	metrics := face.Metrics()
+
+	scale := fontSize / measureSize
+	return float64(advance) / 64 * scale, float64(metrics.Ascent+metrics.Descent) / 64 * scale
+}
@@ -0,0 +1,150 @@
+package wordcloud
+
+import (
+	"image"
+	"image/color"
+	"math"
+
+	"golang.org/x/image/draw"
+)
+
+// mask is a shape scaled to the canvas, words are only placed where it is
+// filled. A pixel of a mask image is filled when it is mostly opaque and not
+// close to white, so both transparent and white backgrounds are left empty.
+type mask struct {
+	width, height int
+	// empty is the summed-area table of the empty pixels: empty[y*(width+1)+x]
+	// counts the empty pixels above and left of (x, y).
+	empty []int32
+}
+
+// newMask scales img to fit a canvas of width by height, centered and keeping
+// its aspect ratio. The canvas outside of the scaled image is empty.
+func newMask(img image.Image, width, height int) *mask {
+	bounds := img.Bounds()
+	scale := math.Min(float64(width)/float64(bounds.Dx()), float64(height)/float64(bounds.Dy()))
+	w, h := int(float64(bounds.Dx())*scale), int(float64(bounds.Dy())*scale)
+	offset := image.Pt((width-w)/2, (height-h)/2)
+
+	scaled := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.ApproxBiLinear.Scale(scaled, image.Rectangle{Min: offset, Max: offset.Add(image.Pt(w, h))}, img, bounds, draw.Src, nil)
+
+	m := &mask{width: width, height: height, empty: make([]int32, (width+1)*(height+1))}
+	stride := width + 1
+
+	for y := 0; y < height; y++ {
+		var row int32
+		for x := 0; x < width; x++ {
+			if !filled(scaled.NRGBAAt(x, y)) {
+				row++
+			}
+			m.empty[(y+1)*stride+x+1] = m.empty[y*stride+x+1] + row
+		}
+	}
+
+	return m
+}
+
+func filled(c color.NRGBA) bool {
+	luminance := (299*int(c.R) + 587*int(c.G) + 114*int(c.B)) / 1000
+	return c.A >= 128 && luminance < 230
+}
+
+// fits reports whether b only covers filled pixels.
+func (m *mask) fits(b box) bool {
+	x0, y0 := clamp(int(math.Floor(b.minX)), m.width), clamp(int(math.Floor(b.minY)), m.height)
+	x1, y1 := clamp(int(math.Ceil(b.maxX)), m.width), clamp(int(math.Ceil(b.maxY)), m.height)
+	stride := m.width + 1
+
+	empty := m.empty[y1*stride+x1] - m.empty[y0*stride+x1] - m.empty[y1*stride+x0] + m.empty[y0*stride+x0]
+	return empty == 0
+}
+
+// filled returns the number of filled pixels of the mask.
+func (m *mask) filled() int {
+	return m.width*m.height - int(m.empty[len(m.empty)-1])
+}
+
+func clamp(v, max int) int {
+	if v < 0 {
+		return 0
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
+
+// Shapes are the built-in mask shapes, by name.
+var Shapes = map[string]func() image.Image{
+	"snoo":   snoo,
+	"circle": circle,
+}
+
+const shapeSize = 512
+
+// shape draws the union of the filled minus the holes in black on a
+// transparent square, in coordinates relative to its side.
+func shape(filled []func(x, y float64) bool, holes []func(x, y float64) bool) image.Image {
+	img := image.NewNRGBA(image.Rect(0, 0, shapeSize, shapeSize))
+
+	for py := 0; py < shapeSize; py++ {
+		for px := 0; px < shapeSize; px++ {
+			x, y := (float64(px)+0.5)/shapeSize, (float64(py)+0.5)/shapeSize
+			if anyOf(filled, x, y) && !anyOf(holes, x, y) {
+				img.SetNRGBA(px, py, color.NRGBA{A: 255})
+			}
+		}
+	}
+
+	return img
+}
+
+func anyOf(fs []func(x, y float64) bool, x, y float64) bool {
+	for _, f := range fs {
+		if f(x, y) {
+			return true
+		}
+	}
+	return false
+}
+
+func ellipse(cx, cy, rx, ry float64) func(x, y float64) bool {
+	return func(x, y float64) bool {
+		dx, dy := (x-cx)/rx, (y-cy)/ry
+		return dx*dx+dy*dy <= 1
+	}
+}
+
+// segment is a line from (x0, y0) to (x1, y1) of the given half width.
+func segment(x0, y0, x1, y1, halfWidth float64) func(x, y float64) bool {
+	return func(x, y float64) bool {
+		dx, dy := x1-x0, y1-y0
+		t := math.Max(0, math.Min(1, ((x-x0)*dx+(y-y0)*dy)/(dx*dx+dy*dy)))
+		return math.Hypot(x-(x0+t*dx), y-(y0+t*dy)) <= halfWidth
+	}
+}
+
+// snoo is the silhouette of Reddit's mascot, its eyes left empty.
+func snoo() image.Image {
+	return shape([]func(x, y float64) bool{
+		ellipse(0.5, 0.40, 0.34, 0.22),    // head
+		ellipse(0.17, 0.29, 0.075, 0.075), // ears
+		ellipse(0.83, 0.29, 0.075, 0.075),
+		segment(0.5, 0.2, 0.58, 0.04, 0.012), // antenna
+		segment(0.58, 0.04, 0.7, 0.07, 0.012),
+		ellipse(0.74, 0.08, 0.06, 0.06),
+		ellipse(0.5, 0.77, 0.2, 0.18),   // body
+		ellipse(0.29, 0.73, 0.06, 0.12), // arms
+		ellipse(0.71, 0.73, 0.06, 0.12),
+		ellipse(0.4, 0.95, 0.1, 0.04), // feet
+		ellipse(0.6, 0.95, 0.1, 0.04),
+	}, []func(x, y float64) bool{
+		ellipse(0.38, 0.40, 0.055, 0.055), // eyes
+		ellipse(0.62, 0.40, 0.055, 0.055),
+	})
+}
+
+func circle() image.Image {
+	return shape([]func(x, y float64) bool{ellipse(0.5, 0.5, 0.5, 0.5)}, nil)
+}
@@ -0,0 +1,40 @@
+package wordcloud
+
+import (
+	"fmt"
+	"math"
+)
+
+// Palettes are the color palettes words can be colored from, by name.
+var Palettes = map[string][]string{
+	"reddit":     {"#ff4500", "#ff8717", "#0079d3", "#46d160", "#ffb000", "#7193ff", "#1a1a1b"},
+	"category10": {"#1f77b4", "#ff7f0e", "#2ca02c", "#d62728", "#9467bd", "#8c564b", "#e377c2", "#7f7f7f", "#bcbd22", "#17becf"},
+	"viridis":    {"#440154", "#482878", "#3e4a89", "#31688e", "#26828e", "#1f9e89", "#35b779", "#6dcd59", "#b4de2c"},
+	"pastel":     {"#8dd3c7", "#bebada", "#fb8072", "#80b1d3", "#fdb462", "#b3de69", "#fccde5", "#bc80bd"},
+	"monochrome": {"#111111", "#333333", "#555555", "#777777"},
+}
+
+// Endpoints of the diverging scale of DivergingColor.
+var (
+	divergingNegative = [3]float64{0xd7, 0x30, 0x27}
+	divergingNeutral  = [3]float64{0x99, 0x99, 0x99}
+	divergingPositive = [3]float64{0x1a, 0x98, 0x50}
+)
+
+// DivergingColor maps v, from -1 to 1, to a color from red through grey to green.
+func DivergingColor(v float64) string {
+	v = math.Max(-1, math.Min(1, v))
+
+	to := divergingPositive
+	if v < 0 {
+		to = divergingNegative
+	}
+
+	t := math.Abs(v)
+	var rgb [3]uint8
+	for i := range rgb {
+		rgb[i] = uint8(math.Round(divergingNeutral[i] + t*(to[i]-divergingNeutral[i])))
+	}
+
+	return fmt.Sprintf("#%02x%02x%02x", rgb[0], rgb[1], rgb[2])
+}
@@ -0,0 +1,103 @@
+package wordcloud
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/f64"
+	"golang.org/x/image/math/fixed"
+)
+
+// RenderPNG lays out words with opts and writes the cloud to w as a PNG image
+// of opts.Width by opts.Height pixels, with words rasterized from the embedded font.
+func RenderPNG(w io.Writer, words map[string]int, opts Options) error {
+	opts = opts.withDefaults()
+
+	background, err := parseColor(opts.Background)
+
+	if err != nil {
+		return err
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, opts.Width, opts.Height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(background), image.Point{}, draw.Src)
+
+	faces := make(map[float64]font.Face)
+
+	for _, p := range Layout(words, opts) {
+		face, ok := faces[p.FontSize]
+		if !ok {
+			if face, err = newFace(p.FontSize); err != nil {
+				return fmt.Errorf("could not create face of size %g: %w", p.FontSize, err)
+			}
+			faces[p.FontSize] = face
+		}
+
+		c, err := parseColor(p.Color)
+
+		if err != nil {
+			return err
+		}
+
+		drawWord(img, face, p, c)
+	}
+
+	return png.Encode(w, img)
+}
+
+// drawWord draws the word of p centered on its position and rotated around it.
+func drawWord(dst draw.Image, face font.Face, p Placement, c color.Color) {
+	metrics := face.Metrics()
+	width := font.MeasureString(face, p.Text).Ceil()
+	height := (metrics.Ascent + metrics.Descent).Ceil()
+
+	word := image.NewNRGBA(image.Rect(0, 0, width, height))
+	d := font.Drawer{Dst: word, Src: image.NewUniform(c), Face: face, Dot: fixed.Point26_6{Y: metrics.Ascent}}
+	d.DrawString(p.Text)
+
+	sin, cos := math.Sincos(p.Rotate * math.Pi / 180)
+	cx, cy := float64(width)/2, float64(height)/2
+	s2d := f64.Aff3{
+		cos, -sin, p.X - cos*cx + sin*cy,
+		sin, cos, p.Y - sin*cx - cos*cy,
+	}
+
+	draw.BiLinear.Transform(dst, s2d, word, word.Bounds(), draw.Over, nil)
+}
+
+// parseColor parses "transparent" or a hex color of the forms #rgb, #rgba,
+// #rrggbb and #rrggbbaa.
+func parseColor(s string) (color.NRGBA, error) {
+	if s == "transparent" {
+		return color.NRGBA{}, nil
+	}
+
+	hex := strings.TrimPrefix(s, "#")
+	if len(hex) == 3 || len(hex) == 4 {
+		var long strings.Builder
+		for _, r := range hex {
+			long.WriteRune(r)
+			long.WriteRune(r)
+		}
+		hex = long.String()
+	}
+	if len(hex) == 6 {
+		hex += "ff"
+	}
+
+	v, err := strconv.ParseUint(hex, 16, 32)
+
+	if !strings.HasPrefix(s, "#") || len(hex) != 8 || err != nil {
+		return color.NRGBA{}, fmt.Errorf("invalid color %q", s)
+	}
+
+	return color.NRGBA{R: uint8(v >> 24), G: uint8(v >> 16), B: uint8(v >> 8), A: uint8(v)}, nil
+}
@@ -0,0 +1,44 @@
+package wordcloud
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+)
+
+// RenderSVG lays out words with opts and writes the cloud to w as an SVG document.
+func RenderSVG(w io.Writer, words map[string]int, opts Options) error {
+	opts = opts.withDefaults()
+	placements := Layout(words, opts)
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`+"\n",
+		opts.Width, opts.Height, opts.Width, opts.Height)
+	// The font words are measured with is embedded, so they are drawn in the
+	// size they were laid out at whatever fonts the viewer has.
+	if opts.FontFamily == goFontFamily {
+		fmt.Fprintf(&buf, `<defs><style>@font-face{font-family:%s;src:url(%s) format("truetype");}</style></defs>`+"\n", goFontName, goRegularURL)
+	}
+	fmt.Fprintf(&buf, `<rect width="100%%" height="100%%" fill="%s"/>`+"\n", escape(opts.Background))
+	fmt.Fprintf(&buf, `<g font-family="%s" text-anchor="middle" dominant-baseline="central">`+"\n", escape(opts.FontFamily))
+
+	for _, p := range placements {
+		fmt.Fprintf(&buf, `<text x="%.1f" y="%.1f" font-size="%.1f" fill="%s"`, p.X, p.Y, p.FontSize, p.Color)
+		if p.Rotate != 0 {
+			fmt.Fprintf(&buf, ` transform="rotate(%g %.1f %.1f)"`, p.Rotate, p.X, p.Y)
+		}
+		fmt.Fprintf(&buf, `>%s</text>`+"\n", escape(p.Text))
+	}
+
+	buf.WriteString("</g>\n</svg>\n")
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func escape(s string) string {
+	var buf bytes.Buffer
+	_ = xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
@@ -0,0 +1,396 @@
+// Package wordcloud lays out and renders word clouds from word counts.
+package wordcloud
+
+import (
+	"image"
+	"math"
+	"math/rand"
+	"sort"
+
+	"golang.org/x/image/font"
+)
+
+// Scale maps the count of a word to its font size.
+type Scale string
+
+const (
+	ScaleLinear Scale = "linear"
+	ScaleLog    Scale = "log"
+	ScaleSqrt   Scale = "sqrt"
+)
+
+// Rotation selects the angles words are drawn at.
+type Rotation string
+
+const (
+	// RotationNone draws every word horizontally.
+	RotationNone Rotation = "none"
+	// RotationOrthogonal draws words horizontally or vertically.
+	RotationOrthogonal Rotation = "orthogonal"
+	// RotationMixed draws words at multiples of 30 degrees between -90 and 90.
+	RotationMixed Rotation = "mixed"
+)
+
+type Options struct {
+	Width       int
+	Height      int
+	MinFontSize float64
+	MaxFontSize float64
+	Scale       Scale
+	Rotation    Rotation
+	// Palette is the name of the palette words are colored from, see Palettes.
+	Palette string
+	// Seed makes the layout deterministic, the same words and options always
+	// produce the same cloud.
+	Seed int64
+	// MaxWords keeps the most frequent words.
+	MaxWords int
+	// FontFamily is the font family of SVG clouds. Words are measured with the
+	// embedded Go Regular font, the default family, which SVG clouds embed.
+	// Other families are drawn in fonts whose metrics may differ.
+	FontFamily string
+	// Background is the color of the canvas, a hex color or "transparent".
+	Background string
+	// Padding is the space kept around every word, in pixels. Unlike the other
+	// fields it is kept when 0, which packs words tightly.
+	Padding float64
+	// Mask restricts the cloud to a shape, see newMask. Nil fills the canvas.
+	Mask image.Image
+	// Colors are the colors of words, overriding the palette.
+	Colors map[string]string
+}
+
+// DefaultOptions returns the options used for the fields of Options left unset.
+func DefaultOptions() Options {
+	return Options{
+		Width:       800,
+		Height:      600,
+		MinFontSize: 10,
+		MaxFontSize: 80,
+		Scale:       ScaleSqrt,
+		Rotation:    RotationOrthogonal,
+		Palette:     "reddit",
+		MaxWords:    150,
+		FontFamily:  goFontFamily,
+		Background:  "#ffffff",
+		Padding:     2,
+	}
+}
+
+func (opts Options) withDefaults() Options {
+	defaults := DefaultOptions()
+
+	if opts.Width <= 0 {
+		opts.Width = defaults.Width
+	}
+	if opts.Height <= 0 {
+		opts.Height = defaults.Height
+	}
+	if opts.MinFontSize <= 0 {
+		opts.MinFontSize = defaults.MinFontSize
+	}
+	if opts.MaxFontSize <= 0 {
+		opts.MaxFontSize = defaults.MaxFontSize
+	}
+	if opts.MaxFontSize < opts.MinFontSize {
+		opts.MaxFontSize = opts.MinFontSize
+	}
+	if opts.Scale == "" {
+		opts.Scale = defaults.Scale
+	}
+	if opts.Rotation == "" {
+		opts.Rotation = defaults.Rotation
+	}
+	if _, ok := Palettes[opts.Palette]; !ok {
+		opts.Palette = defaults.Palette
+	}
+	if opts.MaxWords <= 0 {
+		opts.MaxWords = defaults.MaxWords
+	}
+	if opts.FontFamily == "" {
+		opts.FontFamily = defaults.FontFamily
+	}
+	if opts.Background == "" {
+		opts.Background = defaults.Background
+	}
+	if opts.Padding < 0 {
+		opts.Padding = 0
+	}
+
+	return opts
+}
+
+// Placement is a word placed in a cloud, centered on X and Y and rotated
+// clockwise by Rotate degrees.
+type Placement struct {
+	Text     string
+	Count    int
+	FontSize float64
+	X        float64
+	Y        float64
+	Rotate   float64
+	Color    string
+	box      box
+}
+
+// box is an axis-aligned rectangle, the bounds of a placed word.
+type box struct {
+	minX, minY, maxX, maxY float64
+}
+
+func (b box) intersects(o box) bool {
+	return b.minX < o.maxX && o.minX < b.maxX && b.minY < o.maxY && o.minY < b.maxY
+}
+
+const (
+	// spiralStep is the largest angle, in radians, between two positions tried
+	// on the spiral, and spiralStepLength the largest distance between them.
+	spiralStep       = 0.1
+	spiralStepLength = 4.0
+	// spiralGrowth is how far the spiral moves from the center per radian.
+	spiralGrowth = 1.0
+	// candidatesPerWord bounds the positions tried by a layout, on average per
+	// word, so that clouds of many words that do not fit end in bounded time.
+	candidatesPerWord = 20000
+)
+
+// Layout places the words of a cloud, from the most to the least frequent,
+// each on the first position of a spiral around the center of the canvas
+// where it overlaps no word placed before it, and is inside the mask if there
+// is one. Words that fit nowhere are left out.
+//
+// The spiral of a word ends where the word can no longer be inside the canvas,
+// words larger than the area left free are not tried, and a layout tries at
+// most candidatesPerWord positions per word, the words left when they are
+// spent are left out.
+func Layout(words map[string]int, opts Options) []Placement {
+	opts = opts.withDefaults()
+	sorted := sortWords(words, opts.MaxWords)
+
+	if len(sorted) == 0 {
+		return nil
+	}
+
+	face := measureFaces.Get().(font.Face)
+	defer measureFaces.Put(face)
+
+	rng := rand.New(rand.NewSource(opts.Seed))
+	palette := Palettes[opts.Palette]
+	minCount, maxCount := sorted[len(sorted)-1].count, sorted[0].count
+
+	width, height := float64(opts.Width), float64(opts.Height)
+	canvas := box{0, 0, width, height}
+	free := width * height
+
+	var m *mask
+	if opts.Mask != nil {
+		m = newMask(opts.Mask, opts.Width, opts.Height)
+		free = float64(m.filled())
+	}
+	// The spiral is stretched to the shape of the canvas.
+	aspect := width / height
+
+	placements := make([]Placement, 0, len(sorted))
+	index := newGrid(width, height)
+	budget := len(sorted) * candidatesPerWord
+	// lastHit is the placement the previous candidate collided with, likely
+	// to collide with the next one too.
+	lastHit := -1
+	// unplaced is the smallest word that did not fit.
+	unplaced := struct{ halfWidth, halfHeight float64 }{math.Inf(1), math.Inf(1)}
+
+	for _, w := range sorted {
+		if budget <= 0 {
+			break
+		}
+
+		p := Placement{
+			Text:     w.text,
+			Count:    w.count,
+			FontSize: fontSize(w.count, minCount, maxCount, opts),
+			Rotate:   rotation(rng, opts.Rotation),
+			Color:    palette[rng.Intn(len(palette))],
+		}
+
+		if c, ok := opts.Colors[w.text]; ok {
+			p.Color = c
+		}
+
+		halfWidth, halfHeight := rotatedSize(face, p.Text, p.FontSize, p.Rotate, opts.Padding)
+		phase := rng.Float64() * 2 * math.Pi
+
+		// A word at least as large as one that did not fit does not fit either.
+		if halfWidth >= unplaced.halfWidth && halfHeight >= unplaced.halfHeight {
+			continue
+		}
+
+		// Past maxRadius the spiral stretched to the canvas only holds positions
+		// where the word crosses its edges.
+		reachX, reachY := width/2-halfWidth, height/2-halfHeight
+		fits := reachX >= 0 && reachY >= 0 && 4*halfWidth*halfHeight <= free
+		maxRadius := math.Hypot(reachX/aspect, reachY)
+
+		placed := false
+		for angle := 0.0; fits && spiralGrowth*angle <= maxRadius && budget > 0; angle += math.Min(spiralStep, spiralStepLength/(spiralGrowth*angle)) {
+			budget--
+			radius := spiralGrowth * angle
+			p.X = width/2 + radius*math.Cos(angle+phase)*aspect
+			p.Y = height/2 + radius*math.Sin(angle+phase)
+			p.box = box{p.X - halfWidth, p.Y - halfHeight, p.X + halfWidth, p.Y + halfHeight}
+
+			if !canvas.contains(p.box) {
+				continue
+			}
+
+			if lastHit >= 0 && lastHit < len(placements) && placements[lastHit].box.intersects(p.box) {
+				continue
+			}
+
+			if hit := index.collision(placements, p.box); hit >= 0 {
+				lastHit = hit
+				continue
+			}
+
+			if m != nil && !m.fits(p.box) {
+				continue
+			}
+
+			index.add(len(placements), p.box)
+			placements = append(placements, p)
+			free -= 4 * halfWidth * halfHeight
+			placed = true
+			break
+		}
+
+		if !placed && halfWidth*halfHeight < unplaced.halfWidth*unplaced.halfHeight {
+			unplaced.halfWidth, unplaced.halfHeight = halfWidth, halfHeight
+		}
+	}
+
+	return placements
+}
+
+func (b box) contains(o box) bool {
+	return o.minX >= b.minX && o.minY >= b.minY && o.maxX <= b.maxX && o.maxY <= b.maxY
+}
+
+// gridCellSize is the side of the cells of a grid, in pixels.
+const gridCellSize = 32
+
+// grid indexes the boxes of placed words by the cells of the canvas they
+// cover, so a candidate is only checked against the words around it.
+type grid struct {
+	cols, rows int
+	// cells hold the indexes of the placements covering each cell, row by row.
+	cells [][]int
+}
+
+func newGrid(width, height float64) *grid {
+	cols, rows := int(math.Ceil(width/gridCellSize)), int(math.Ceil(height/gridCellSize))
+	return &grid{cols: cols, rows: rows, cells: make([][]int, cols*rows)}
+}
+
+// span returns the range of the cells covered by b, bounds included.
+func (g *grid) span(b box) (int, int, int, int) {
+	cell := func(v float64, n int) int {
+		return min(max(int(v/gridCellSize), 0), n-1)
+	}
+	return cell(b.minX, g.cols), cell(b.minY, g.rows), cell(b.maxX, g.cols), cell(b.maxY, g.rows)
+}
+
+// add indexes the box of placement i.
+func (g *grid) add(i int, b box) {
+	x0, y0, x1, y1 := g.span(b)
+	for y := y0; y <= y1; y++ {
+		for x := x0; x <= x1; x++ {
+			g.cells[y*g.cols+x] = append(g.cells[y*g.cols+x], i)
+		}
+	}
+}
+
+// collision returns the index of a placement overlapping b, or -1.
+func (g *grid) collision(placements []Placement, b box) int {
+	x0, y0, x1, y1 := g.span(b)
+	for y := y0; y <= y1; y++ {
+		for x := x0; x <= x1; x++ {
+			for _, i := range g.cells[y*g.cols+x] {
+				if placements[i].box.intersects(b) {
+					return i
+				}
+			}
+		}
+	}
+	return -1
+}
+
+type wordCount struct {
+	text  string
+	count int
+}
+
+// sortWords returns the words with a positive count from the most to the
+// least frequent, ties broken alphabetically so layouts are deterministic.
+func sortWords(words map[string]int, max int) []wordCount {
+	sorted := make([]wordCount, 0, len(words))
+	for text, count := range words {
+		if count > 0 && text != "" {
+			sorted = append(sorted, wordCount{text, count})
+		}
+	}
+
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].count != sorted[j].count {
+			return sorted[i].count > sorted[j].count
+		}
+		return sorted[i].text < sorted[j].text
+	})
+
+	if max > 0 && len(sorted) > max {
+		sorted = sorted[:max]
+	}
+
+	return sorted
+}
+
+// fontSize scales count between the font sizes of opts.
+func fontSize(count, minCount, maxCount int, opts Options) float64 {
+	if maxCount == minCount {
+		return opts.MaxFontSize
+	}
+
+	f := func(c int) float64 { return float64(c) }
+	switch opts.Scale {
+	case ScaleLog:
+		f = func(c int) float64 { return math.Log(float64(c)) }
+	case ScaleSqrt:
+		f = func(c int) float64 { return math.Sqrt(float64(c)) }
+	}
+
+	t := (f(count) - f(minCount)) / (f(maxCount) - f(minCount))
+	return opts.MinFontSize + t*(opts.MaxFontSize-opts.MinFontSize)
+}
+
+func rotation(rng *rand.Rand, r Rotation) float64 {
+	switch r {
+	case RotationOrthogonal:
+		if rng.Intn(3) == 0 {
+			return -90
+		}
+	case RotationMixed:
+		return float64(rng.Intn(7)*30 - 90)
+	}
+	return 0
+}
+
+// rotatedSize returns the half width and half height of the bounds of text
+// drawn at fontSize and rotated by degrees, padding included.
+func rotatedSize(face font.Face, text string, fontSize float64, degrees float64, padding float64) (float64, float64) {
+	w, h := measure(face, text, fontSize)
+	w += 2 * padding
+	h += 2 * padding
+
+	rad := degrees * math.Pi / 180
+	sin, cos := math.Abs(math.Sin(rad)), math.Abs(math.Cos(rad))
+
+	return (w*cos + h*sin) / 2, (w*sin + h*cos) / 2
+}
@@ -0,0 +1,146 @@
+package wordcloud
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testWords returns n words counted from n down to 1.
+func testWords(n int) map[string]int {
+	words := make(map[string]int, n)
+	for i := 0; i < n; i++ {
+		words[fmt.Sprintf("word%d", i)] = n - i
+	}
+	return words
+}
+
+func TestLayout(t *testing.T) {
+	tests := []struct {
+		name      string
+		words     map[string]int
+		opts      Options
+		wantCount int
+		// wantAtMost bounds the words placed when not all of them fit.
+		wantAtMost int
+	}{
+		{name: "no words", words: map[string]int{}, wantCount: 0},
+		{name: "non-positive counts are left out", words: map[string]int{"a": 0, "b": -1, "": 3}, wantCount: 0},
+		{name: "a few words", words: map[string]int{"reddit": 10, "word": 5, "cloud": 1}, wantCount: 3},
+		{name: "max words", words: testWords(50), opts: Options{MaxWords: 20}, wantCount: 20},
+		{
+			name:      "word larger than the canvas",
+			words:     map[string]int{strings.Repeat("w", 100): 10},
+			opts:      Options{Width: 100, Height: 100, MinFontSize: 80, MaxFontSize: 80},
+			wantCount: 0,
+		},
+		{
+			name:       "more words than fit",
+			words:      testWords(1000),
+			opts:       Options{Width: 200, Height: 100, MaxWords: 1000, MinFontSize: 20, MaxFontSize: 40},
+			wantAtMost: 999,
+		},
+		{name: "masked", words: testWords(100), opts: Options{Mask: Shapes["circle"](), MaxWords: 100}, wantAtMost: 100},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			placements := Layout(tt.words, tt.opts)
+
+			if tt.wantAtMost == 0 && len(placements) != tt.wantCount {
+				t.Errorf("placed %d words, want %d", len(placements), tt.wantCount)
+			}
+			if tt.wantAtMost != 0 && (len(placements) == 0 || len(placements) > tt.wantAtMost) {
+				t.Errorf("placed %d words, want between 1 and %d", len(placements), tt.wantAtMost)
+			}
+
+			opts := tt.opts.withDefaults()
+			canvas := box{0, 0, float64(opts.Width), float64(opts.Height)}
+			var m *mask
+			if opts.Mask != nil {
+				m = newMask(opts.Mask, opts.Width, opts.Height)
+			}
+
+			for i, p := range placements {
+				if !canvas.contains(p.box) {
+					t.Errorf("%s is outside the canvas: %+v", p.Text, p.box)
+				}
+				if m != nil && !m.fits(p.box) {
+					t.Errorf("%s is outside the mask: %+v", p.Text, p.box)
+				}
+				for _, q := range placements[:i] {
+					if p.box.intersects(q.box) {
+						t.Errorf("%s overlaps %s", p.Text, q.Text)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestLayoutIsDeterministic(t *testing.T) {
+	words := testWords(80)
+
+	first := Layout(words, Options{Seed: 42, Rotation: RotationMixed})
+	second := Layout(words, Options{Seed: 42, Rotation: RotationMixed})
+
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("layouts of the same seed differ")
+	}
+}
+
+func TestGridCollision(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	random := func() box {
+		x, y := rng.Float64()*800, rng.Float64()*600
+		return box{x, y, x + rng.Float64()*150, y + rng.Float64()*60}
+	}
+
+	g := newGrid(800, 600)
+	var placements []Placement
+	for i := 0; i < 200; i++ {
+		b := random()
+		if g.collision(placements, b) >= 0 {
+			continue
+		}
+		g.add(len(placements), b)
+		placements = append(placements, Placement{box: b})
+	}
+
+	for i := 0; i < 2000; i++ {
+		b := random()
+		want := false
+		for _, p := range placements {
+			want = want || p.box.intersects(b)
+		}
+		if got := g.collision(placements, b) >= 0; got != want {
+			t.Fatalf("collision(%+v) = %v, want %v", b, got, want)
+		}
+	}
+}
+
+func TestRenderSVGEmbedsTheMeasuredFont(t *testing.T) {
+	tests := []struct {
+		name       string
+		fontFamily string
+		want       bool
+	}{
+		{"default family", "", true},
+		{"other family", "Helvetica, Arial, sans-serif", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := RenderSVG(&buf, map[string]int{"word": 1}, Options{FontFamily: tt.fontFamily}); err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.Contains(buf.String(), "@font-face"); got != tt.want {
+				t.Errorf("embeds the font = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,9 +1,12 @@
 package router
 
 import (
+	"crypto/subtle"
+	"net/http"
 	"redditwordcloud/internal/health"
 	"redditwordcloud/internal/newrelic"
 	"redditwordcloud/internal/reddit"
+	"strings"
 	"time"
 
 	"github.com/gin-contrib/cors"
@@ -11,6 +14,7 @@ import (
 	"github.com/gin-gonic/gin/binding"
 	"github.com/go-playground/validator/v10"
 	"github.com/newrelic/go-agent/v3/integrations/nrgin"
+	"go.uber.org/zap"
 )
 
 var r *gin.Engine
@@ -18,19 +22,53 @@ var r *gin.Engine
 const (
 	HealthPath                     = "/health"
 	GetRedditThreadWordsByLinkPath = "/reddit/words/link"
+	GetRedditSubredditWordsPath    = "/reddit/words/subreddit"
+	GetRedditUserWordsPath         = "/reddit/words/user"
+	GetRedditSearchWordsPath       = "/reddit/words/search"
+	// Thread paths take the scid of the thread, path-escaped: r%2Fgolang%2Fcomments%2Fabc123
+	ReprocessRedditThreadPath       = "/reddit/threads/:scid/reprocess"
+	GetRedditThreadWordContextsPath = "/reddit/threads/:scid/words/:word/contexts"
+	GetRedditThreadCloudSVGPath     = "/reddit/threads/:scid/cloud.svg"
+	GetRedditThreadCloudPNGPath     = "/reddit/threads/:scid/cloud.png"
+	GetRedditThreadGraphPath        = "/reddit/threads/:scid/graph"
+	GetRedditSubredditGraphPath     = "/reddit/subreddits/:subreddit/graph"
+	GetRedditThreadTopicsPath       = "/reddit/threads/:scid/topics"
+	GetRedditSubthreadWordsPath     = "/reddit/threads/:scid/comments/:commentId/words"
+	GetRedditThreadBranchesPath     = "/reddit/threads/:scid/branches"
+	GetRedditThreadTimeseriesPath   = "/reddit/threads/:scid/timeseries"
+	RedditWatchesPath               = "/reddit/watches"
+	UnwatchRedditThreadPath         = "/reddit/watches/:scid"
 )
 
-func InitRouter(healthHandler *health.Handler, redditHandler *reddit.Handler, nrc *newrelic.NewRelicClient) {
+// requireAPIKey rejects the requests that do not carry apiKey as a bearer
+// token, every request when apiKey is empty.
+func requireAPIKey(apiKey string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+
+		if !ok || apiKey == "" || subtle.ConstantTimeCompare([]byte(token), []byte(apiKey)) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid API key"})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func InitRouter(healthHandler *health.Handler, redditHandler *reddit.Handler, nrc *newrelic.NewRelicClient, apiKey string) {
 
 	r = gin.Default()
+	// scids contain slashes, route on the escaped path and unescape the params.
+	r.UseRawPath = true
+	r.UnescapePathValues = true
 	// Add the nrgin middleware before other middlewares or routes:
 	r.Use(nrgin.Middleware(nrc.Client))
 
 	r.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"http://localhost:3000", "https://redditworldcloud-api.onrender.com"},
-		AllowMethods:     []string{"GET", "POST"},
-		AllowHeaders:     []string{"Content-Type"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowMethods:     []string{"GET", "POST", "DELETE"},
+		AllowHeaders:     []string{"Content-Type", "Authorization"},
+		ExposeHeaders:    []string{"Content-Length", "Content-Disposition"},
 		AllowCredentials: true,
 		AllowOriginFunc: func(origin string) bool {
 			return origin == "http://localhost:3000" || origin == "https://redditworldcloud-api.onrender.com"
@@ -40,11 +78,41 @@ func InitRouter(healthHandler *health.Handler, redditHandler *reddit.Handler, nr
 
 	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
 		_ = v.RegisterValidation("ValidateLink", reddit.ValidateLink)
+		_ = v.RegisterValidation("ValidateSubreddit", reddit.ValidateSubreddit)
+		_ = v.RegisterValidation("ValidateUsername", reddit.ValidateUsername)
+		_ = v.RegisterValidation("ValidateFilter", reddit.ValidateFilter)
+		_ = v.RegisterValidation("ValidateRegexp", reddit.ValidateRegexp)
+		_ = v.RegisterValidation("ValidateLanguage", reddit.ValidateLanguage)
+		_ = v.RegisterValidation("ValidateInterval", reddit.ValidateInterval)
 	}
 
 	r.GET(HealthPath, healthHandler.GetHealth)
 	// r.GET(GetRedditThreadWordsByThreadIDPath, redditHandler.GetRedditThreadWordsByThreadIDHandler)
 	r.POST(GetRedditThreadWordsByLinkPath, redditHandler.GetRedditThreadWordsByLinkHandler)
+	r.POST(GetRedditSubredditWordsPath, redditHandler.GetRedditSubredditWordsHandler)
+	r.POST(GetRedditUserWordsPath, redditHandler.GetRedditUserWordsHandler)
+	r.POST(GetRedditSearchWordsPath, redditHandler.GetRedditSearchWordsHandler)
+	r.GET(GetRedditThreadWordContextsPath, redditHandler.GetRedditThreadWordContextsHandler)
+	r.GET(GetRedditThreadCloudSVGPath, redditHandler.GetRedditThreadCloudSVGHandler)
+	r.GET(GetRedditThreadCloudPNGPath, redditHandler.GetRedditThreadCloudPNGHandler)
+	r.GET(GetRedditThreadGraphPath, redditHandler.GetRedditThreadGraphHandler)
+	r.GET(GetRedditSubredditGraphPath, redditHandler.GetRedditSubredditGraphHandler)
+	r.GET(GetRedditThreadTopicsPath, redditHandler.GetRedditThreadTopicsHandler)
+	r.GET(GetRedditSubthreadWordsPath, redditHandler.GetRedditSubthreadWordsHandler)
+	r.GET(GetRedditThreadBranchesPath, redditHandler.GetRedditThreadBranchesHandler)
+	r.GET(GetRedditThreadTimeseriesPath, redditHandler.GetRedditThreadTimeseriesHandler)
+
+	if apiKey == "" {
+		zap.S().Warn("No API key is set, the reprocess and watch routes reject every request.")
+	}
+
+	// Reprocesses rewrite every comment of a thread and watches poll reddit in
+	// the background for hours, only clients holding the API key start them.
+	authorized := r.Group("", requireAPIKey(apiKey))
+	authorized.POST(ReprocessRedditThreadPath, redditHandler.ReprocessRedditThreadHandler)
+	authorized.POST(RedditWatchesPath, redditHandler.WatchRedditThreadHandler)
+	authorized.GET(RedditWatchesPath, redditHandler.GetRedditWatchesHandler)
+	authorized.DELETE(UnwatchRedditThreadPath, redditHandler.UnwatchRedditThreadHandler)
 }
 
 func Start(addr string) error {
@@ -0,0 +1,46 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRequireAPIKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name          string
+		apiKey        string
+		authorization string
+		want          int
+	}{
+		{name: "valid key", apiKey: "secret", authorization: "Bearer secret", want: http.StatusOK},
+		{name: "wrong key", apiKey: "secret", authorization: "Bearer guess", want: http.StatusUnauthorized},
+		{name: "no bearer", apiKey: "secret", authorization: "secret", want: http.StatusUnauthorized},
+		{name: "no header", apiKey: "secret", want: http.StatusUnauthorized},
+		{name: "no key set", apiKey: "", authorization: "Bearer ", want: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine := gin.New()
+			engine.Group("", requireAPIKey(tt.apiKey)).GET(RedditWatchesPath, func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, RedditWatchesPath, nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, req)
+
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}